@@ -14,7 +14,28 @@ func configure() error {
 	cwd, _ := os.Getwd()
 	viper.SetDefault("ENV_FILE", path.Join(cwd, ".env"))
 	viper.SetDefault("WAQI_URL", waqi.DefaultURL)
+	viper.SetDefault("WAQI_REQUEST_TIMEOUT", waqi.DefaultRequestTimeout)
+	viper.SetDefault("WAQI_RETRY_ATTEMPTS", waqi.DefaultRetryAttempts)
+	viper.SetDefault("WAQI_CIRCUIT_BREAKER_THRESHOLD", waqi.DefaultCircuitBreakerThreshold)
+	viper.SetDefault("WAQI_CIRCUIT_BREAKER_COOLDOWN", waqi.DefaultCircuitBreakerCooldown)
+	// WAQI_CACHE_URL is a cache store URL, empty value means a leveldb store at WAQI_CACHE_PATH
+	viper.SetDefault("WAQI_CACHE_URL", "")
 	viper.SetDefault("WAQI_CACHE_DURATION", waqi.DefaultCacheDuration)
+	viper.SetDefault("WAQI_CACHE_MAX_AGE", waqi.DefaultCacheMaxAge)
+	viper.SetDefault("WAQI_CACHE_RETENTION", waqi.DefaultCacheRetention)
+	viper.SetDefault("WAQI_CACHE_MAX_ENTRIES", waqi.DefaultCacheMaxEntries)
+	viper.SetDefault("WAQI_CACHE_MAX_SIZE", waqi.DefaultCacheMaxSize)
+	viper.SetDefault("WAQI_CACHE_MEMORY_ENTRIES", waqi.DefaultCacheMemoryEntries)
+	viper.SetDefault("WAQI_CACHE_MEMORY_TTL", waqi.DefaultCacheMemoryTTL)
+	viper.SetDefault("WAQI_POLL_INTERVAL", waqi.DefaultPollInterval)
+	viper.SetDefault("WAQI_POLL_MIN_INTERVAL", waqi.DefaultMinPollInterval)
+	viper.SetDefault("WAQI_POLL_MAX_INTERVAL", waqi.DefaultMaxPollInterval)
+	viper.SetDefault("WAQI_FETCH_WORKERS", waqi.DefaultFetchWorkers)
+	viper.SetDefault("WAQI_FETCH_RATE", waqi.DefaultFetchRate)
+	viper.SetDefault("WAQI_FETCH_BURST", waqi.DefaultFetchBurst)
+	viper.SetDefault("WAQI_STALE_STATION_THRESHOLD", waqi.DefaultStaleStationThreshold)
+	viper.SetDefault("WAQI_HISTORY_RETENTION", waqi.DefaultHistoryRetention)
+	viper.SetDefault("WAQI_HISTORY_DOWNSAMPLE_AFTER", waqi.DefaultHistoryDownsampleAfter)
 	viper.SetDefault("LISTEN_ADDR", "0.0.0.0:8000")
 	viper.SetDefault("TELEGRAM_API_URL", telebot.DefaultApiURL)
 
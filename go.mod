@@ -3,15 +3,17 @@ module github.com/kapitanov/tg-waqi-bot
 go 1.16
 
 require (
-	github.com/enescakir/emoji v1.0.0 // indirect
+	github.com/enescakir/emoji v1.0.0
 	github.com/gin-gonic/gin v1.7.1
 	github.com/jinzhu/gorm v1.9.16 // indirect
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible // indirect
 	github.com/sirupsen/logrus v1.8.1 // indirect
 	github.com/spf13/viper v1.7.1
-	github.com/stretchr/testify v1.7.0 // indirect
-	github.com/syndtr/goleveldb v1.0.0 // indirect
-	gopkg.in/tucnak/telebot.v2 v2.3.5 // indirect
-	gorm.io/driver/sqlite v1.1.4 // indirect
-	gorm.io/gorm v1.21.9 // indirect
+	github.com/stretchr/testify v1.7.0
+	github.com/syndtr/goleveldb v1.0.0
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb
+	gopkg.in/tucnak/telebot.v2 v2.3.5
+	gorm.io/driver/sqlite v1.1.4
+	gorm.io/gorm v1.21.9
 )
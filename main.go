@@ -26,8 +26,28 @@ func main() {
 	waqiService, err := waqi.NewService(
 		waqi.URLOption(viper.GetString("WAQI_URL")),
 		waqi.TokenOption(viper.GetString("WAQI_TOKEN")),
+		waqi.RequestTimeoutOption(viper.GetDuration("WAQI_REQUEST_TIMEOUT")),
+		waqi.RetryAttemptsOption(viper.GetInt("WAQI_RETRY_ATTEMPTS")),
+		waqi.CircuitBreakerThresholdOption(viper.GetInt("WAQI_CIRCUIT_BREAKER_THRESHOLD")),
+		waqi.CircuitBreakerCooldownOption(viper.GetDuration("WAQI_CIRCUIT_BREAKER_COOLDOWN")),
 		waqi.CachePathOption(viper.GetString("WAQI_CACHE_PATH")),
+		waqi.CacheURLOption(viper.GetString("WAQI_CACHE_URL")),
 		waqi.CacheDurationOption(viper.GetDuration("WAQI_CACHE_DURATION")),
+		waqi.CacheMaxAgeOption(viper.GetDuration("WAQI_CACHE_MAX_AGE")),
+		waqi.CacheRetentionOption(viper.GetDuration("WAQI_CACHE_RETENTION")),
+		waqi.CacheMaxEntriesOption(viper.GetInt("WAQI_CACHE_MAX_ENTRIES")),
+		waqi.CacheMaxSizeOption(viper.GetInt64("WAQI_CACHE_MAX_SIZE")),
+		waqi.CacheMemoryEntriesOption(viper.GetInt("WAQI_CACHE_MEMORY_ENTRIES")),
+		waqi.CacheMemoryTTLOption(viper.GetDuration("WAQI_CACHE_MEMORY_TTL")),
+		waqi.PollIntervalOption(viper.GetDuration("WAQI_POLL_INTERVAL")),
+		waqi.PollMinIntervalOption(viper.GetDuration("WAQI_POLL_MIN_INTERVAL")),
+		waqi.PollMaxIntervalOption(viper.GetDuration("WAQI_POLL_MAX_INTERVAL")),
+		waqi.FetchWorkersOption(viper.GetInt("WAQI_FETCH_WORKERS")),
+		waqi.FetchRateOption(viper.GetFloat64("WAQI_FETCH_RATE"), viper.GetInt("WAQI_FETCH_BURST")),
+		waqi.StaleStationThresholdOption(viper.GetDuration("WAQI_STALE_STATION_THRESHOLD")),
+		waqi.HistoryPathOption(viper.GetString("WAQI_HISTORY_PATH")),
+		waqi.HistoryRetentionOption(viper.GetDuration("WAQI_HISTORY_RETENTION")),
+		waqi.HistoryDownsampleAfterOption(viper.GetDuration("WAQI_HISTORY_DOWNSAMPLE_AFTER")),
 		waqi.LoggerOption(log.New(log.Writer(), "waqi: ", log.Flags())))
 	if err != nil {
 		panic(err)
@@ -69,7 +89,7 @@ func main() {
 	}
 
 	// Wait for SIGINT
-	interrupt := make(chan os.Signal)
+	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
 	<-interrupt
 
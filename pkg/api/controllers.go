@@ -2,6 +2,7 @@ package api
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -17,7 +18,13 @@ type getByGeoQuery struct {
 	Lat float32 `form:"lat"`
 }
 
-// GetByGeo handles request /api/status/geo?lon=123&lat=456
+type getHistoryQuery struct {
+	From   time.Time     `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time     `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Period time.Duration `form:"period"`
+}
+
+// GetByGeo handles request /api/status/geo?lon=123&lat=456&scale=eu_caqi
 func (ctrl *restController) GetByGeo(c *gin.Context) {
 	var query getByGeoQuery
 	err := c.BindQuery(&query)
@@ -25,37 +32,129 @@ func (ctrl *restController) GetByGeo(c *gin.Context) {
 		panic(err)
 	}
 
-	resp, err := ctrl.service.GetByGeo(query.Lat, query.Lon)
+	resp, err := ctrl.service.GetByGeo(c.Request.Context(), query.Lat, query.Lon)
 	if err != nil {
 		panic(err)
 	}
 
-	c.JSON(200, resp)
+	c.JSON(200, getStandard(c).Convert(resp))
 }
 
-// GetByCity handles request GET /api/status/city/:city
+// GetByCity handles request GET /api/status/city/:city?scale=eu_caqi
 func (ctrl *restController) GetByCity(c *gin.Context) {
 	city := c.Param("city")
 
-	resp, err := ctrl.service.GetByCity(city)
+	resp, err := ctrl.service.GetByCity(c.Request.Context(), city)
 	if err != nil {
 		panic(err)
 	}
 
-	c.JSON(200, resp)
+	c.JSON(200, getStandard(c).Convert(resp))
 }
 
-// GetByStation handles request GET /api/status/station/:id
+// GetByStation handles request GET /api/status/station/:id?scale=eu_caqi
 func (ctrl *restController) GetByStation(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		panic(err)
 	}
 
-	resp, err := ctrl.service.GetByStation(id)
+	resp, err := ctrl.service.GetByStation(c.Request.Context(), id)
+	if err != nil {
+		panic(err)
+	}
+
+	c.JSON(200, getStandard(c).Convert(resp))
+}
+
+// GetForecast handles request GET /api/forecast/station/:id?scale=eu_caqi
+func (ctrl *restController) GetForecast(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		panic(err)
+	}
+
+	status, err := ctrl.service.GetByStation(c.Request.Context(), id)
+	if err != nil {
+		panic(err)
+	}
+
+	resp := getStandard(c).Convert(status).Forecast
+	if resp == nil {
+		resp = &waqi.Forecast{Days: make([]*waqi.ForecastDay, 0)}
+	}
+
+	c.JSON(200, resp)
+}
+
+// GetHistory handles request GET /api/history/station/:id?period=24h or GET /api/history/station/:id?from=...&to=...
+// Both forms accept an optional scale, e.g. &scale=eu_caqi
+func (ctrl *restController) GetHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		panic(err)
+	}
+
+	var query getHistoryQuery
+	err = c.BindQuery(&query)
+	if err != nil {
+		panic(err)
+	}
+
+	to := query.To
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+
+	from := query.From
+	if from.IsZero() {
+		period := query.Period
+		if period <= 0 {
+			period = 24 * time.Hour
+		}
+		from = to.Add(-period)
+	}
+
+	resp, err := ctrl.service.GetHistory(id, from, to)
+	if err != nil {
+		panic(err)
+	}
+
+	standard := getStandard(c)
+	for i, status := range resp {
+		resp[i] = standard.Convert(status)
+	}
+
+	c.JSON(200, resp)
+}
+
+// GetUpdateReport handles request GET /api/updates/report
+func (ctrl *restController) GetUpdateReport(c *gin.Context) {
+	c.JSON(200, ctrl.service.GetUpdateReport())
+}
+
+// GetCacheStats handles request GET /api/cache/stats
+func (ctrl *restController) GetCacheStats(c *gin.Context) {
+	resp, err := ctrl.service.GetCacheStats()
 	if err != nil {
 		panic(err)
 	}
 
 	c.JSON(200, resp)
 }
+
+// getStandard returns an AQI standard requested by "scale" query parameter
+// Falls back to US EPA if parameter is omitted.
+func getStandard(c *gin.Context) waqi.Standard {
+	scale := c.Query("scale")
+	if scale == "" {
+		return waqi.USEPAStandard
+	}
+
+	standard, err := waqi.ParseStandard(scale)
+	if err != nil {
+		panic(err)
+	}
+
+	return standard
+}
@@ -36,6 +36,10 @@ func NewServer(service waqi.Service, listenAddr string, logger *log.Logger) (Ser
 	router.GET("/api/status/geo", controller.GetByGeo)
 	router.GET("/api/status/city/:city", controller.GetByCity)
 	router.GET("/api/status/station/:id", controller.GetByStation)
+	router.GET("/api/forecast/station/:id", controller.GetForecast)
+	router.GET("/api/history/station/:id", controller.GetHistory)
+	router.GET("/api/cache/stats", controller.GetCacheStats)
+	router.GET("/api/updates/report", controller.GetUpdateReport)
 
 	// Static files
 	err := mime.AddExtensionType(".js", "application/javascript")
@@ -1,15 +1,25 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"gopkg.in/tucnak/telebot.v2"
 
 	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
 )
 
+const (
+	// nearbyStationsCount is a count of nearby stations offered for a location
+	nearbyStationsCount = 5
+
+	// handlerTimeout is max duration of a single telegram event handler
+	handlerTimeout = 30 * time.Second
+)
+
 type botService struct {
 	Bot                *telebot.Bot
 	DB                 DB
@@ -20,6 +30,10 @@ type botService struct {
 	Subscriptions      map[int]int
 	Logger             *log.Logger
 	Screens            *botScreens
+	HeldUpdatesTicker  *time.Ticker
+	HeldUpdatesDone    chan bool
+	Context            context.Context
+	Cancel             context.CancelFunc
 }
 
 // Start starts Bot
@@ -35,11 +49,20 @@ func (s *botService) Start() error {
 
 	// Configure bot
 	s.Bot.Handle("/start", s.onStart)
+	s.Bot.Handle("/settings", s.onSettings)
+	s.Bot.Handle("/history", s.onHistory)
+	s.Bot.Handle("/forecast", s.onForecast)
+	s.registerCommands()
+	s.Bot.Handle(telebot.OnText, s.onText)
 	s.Bot.Handle(telebot.OnLocation, s.onLocation)
 	s.Bot.Handle(telebot.OnCallback, s.onCallback)
 
 	go s.Bot.Start()
 
+	// Deliver updates held during quiet hours
+	s.HeldUpdatesTicker = time.NewTicker(heldUpdatesCheckPeriod)
+	go s.HeldUpdatesLoop(s.HeldUpdatesTicker, s.HeldUpdatesDone)
+
 	// Restore subscriptions
 	m, err := s.DB.GetSubscribedStationIDs()
 	if err != nil {
@@ -54,12 +77,34 @@ func (s *botService) Start() error {
 		s.Logger.Printf("subscribed to station #%d", stationID)
 	}
 
+	// Restore daily digests
+	err = s.restoreDigests()
+	if err != nil {
+		return err
+	}
+
+	// Restore evening forecast alerts
+	err = s.restoreForecastAlerts()
+	if err != nil {
+		return err
+	}
+
 	s.Logger.Printf("bot is up and running")
 	return nil
 }
 
 // Close shuts down Bot
 func (s *botService) Close() {
+	// Abort pending handlers
+	s.Cancel()
+
+	if s.HeldUpdatesTicker != nil {
+		s.HeldUpdatesTicker.Stop()
+		s.HeldUpdatesTicker = nil
+
+		s.HeldUpdatesDone <- true
+	}
+
 	if s.Bot != nil {
 		s.Bot.Stop()
 		s.Bot = nil
@@ -75,11 +120,10 @@ func (s *botService) onStart(m *telebot.Message) {
 }
 
 // onStartCore handles "/start" command (without error handling)
-func (s *botService) onStartCore(arg interface{}, chat *chatEntity) error {
+func (s *botService) onStartCore(_ context.Context, arg interface{}, chat *chatEntity) error {
 	m := arg.(*telebot.Message)
 
-	chat.SetStateNotSubscribed()
-
+	chat.Input = ""
 	err := s.DB.Update(chat)
 	if err != nil {
 		return err
@@ -90,6 +134,18 @@ func (s *botService) onStartCore(arg interface{}, chat *chatEntity) error {
 		return err
 	}
 
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) > 0 {
+		err = s.Screens.SubscriptionsScreen(m.Chat, subscriptions, nil)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -100,16 +156,49 @@ func (s *botService) onLocation(m *telebot.Message) {
 }
 
 // onLocationCore handles location message (without error handling)
-func (s *botService) onLocationCore(arg interface{}, _ *chatEntity) error {
+func (s *botService) onLocationCore(ctx context.Context, arg interface{}, chat *chatEntity) error {
 	m := arg.(*telebot.Message)
 
-	status, err := s.WAQI.GetByGeo(m.Location.Lat, m.Location.Lng)
+	status, err := s.WAQI.GetByGeo(ctx, m.Location.Lat, m.Location.Lng)
 	if err != nil {
 		s.Logger.Printf("unable to query status for location (%f, %f)", m.Location.Lat, m.Location.Lng)
 		return s.Screens.ErrorScreen(m.Chat)
 	}
 
-	return s.Screens.LocationScreen(m.Chat, status, nil)
+	err = s.Screens.LocationScreen(chat, status, nil)
+	if err != nil {
+		return err
+	}
+
+	// Offer nearby stations since WAQI might have picked a station that is not the closest one
+	nearby, err := s.getNearbyStations(ctx, m.Location.Lat, m.Location.Lng, status.Station.ID)
+	if err != nil {
+		s.Logger.Printf("unable to query nearby stations for location (%f, %f): %s", m.Location.Lat, m.Location.Lng, err)
+		return nil
+	}
+
+	if len(nearby) == 0 {
+		return nil
+	}
+
+	return s.Screens.NearbyStationsScreen(chat, nearby)
+}
+
+// getNearbyStations returns stations nearest to specified geo coordinates except for specified station
+func (s *botService) getNearbyStations(ctx context.Context, lat, lon float32, stationID int) ([]*waqi.NearbyStation, error) {
+	stations, err := s.WAQI.GetNearby(ctx, lat, lon, nearbyStationsCount+1)
+	if err != nil {
+		return nil, err
+	}
+
+	nearby := make([]*waqi.NearbyStation, 0, len(stations))
+	for _, station := range stations {
+		if station.Station.ID != stationID && len(nearby) < nearbyStationsCount {
+			nearby = append(nearby, station)
+		}
+	}
+
+	return nearby, nil
 }
 
 // onCallback handles callbacks
@@ -119,7 +208,7 @@ func (s *botService) onCallback(c *telebot.Callback) {
 }
 
 // onCallbackCore handles callbacks
-func (s *botService) onCallbackCore(arg interface{}, chat *chatEntity) error {
+func (s *botService) onCallbackCore(ctx context.Context, arg interface{}, chat *chatEntity) error {
 	c := arg.(*telebot.Callback)
 	callback, err := parseCallbackJSON(c.Data)
 	if err != nil {
@@ -128,13 +217,67 @@ func (s *botService) onCallbackCore(arg interface{}, chat *chatEntity) error {
 
 	switch callback.Type {
 	case callbackTypeSubscribe:
-		err = s.onCallbackSubscribe(c, callback, c.Sender, chat)
+		err = s.onCallbackSubscribe(ctx, c, callback, c.Sender, chat)
 		break
 	case callbackTypeUnsubscribe:
-		err = s.onCallbackUnsubscribe(c, callback, c.Sender, chat)
+		err = s.onCallbackUnsubscribe(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeListUnsubscribe:
+		err = s.onCallbackListUnsubscribe(ctx, c, callback, c.Sender, chat)
 		break
 	case callbackTypeRefresh:
-		err = s.onCallbackRefresh(c, callback, c.Sender, chat)
+		err = s.onCallbackRefresh(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeAlertRules:
+		err = s.onCallbackAlertRules(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeSetAlertRule:
+		err = s.onCallbackSetAlertRule(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeSettings:
+		err = s.onCallbackSettings(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeTimeZone:
+		err = s.onCallbackTimeZone(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeSetTimeZone:
+		err = s.onCallbackSetTimeZone(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeQuietHours:
+		err = s.onCallbackQuietHours(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeSetQuietHours:
+		err = s.onCallbackSetQuietHours(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeScale:
+		err = s.onCallbackScale(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeSetScale:
+		err = s.onCallbackSetScale(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeProfile:
+		err = s.onCallbackProfile(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeSetProfile:
+		err = s.onCallbackSetProfile(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeHistory:
+		err = s.onCallbackHistory(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeDigest:
+		err = s.onCallbackDigest(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeSetDigest:
+		err = s.onCallbackSetDigest(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeStation:
+		err = s.onCallbackStation(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeForecast:
+		err = s.onCallbackForecast(ctx, c, callback, c.Sender, chat)
+		break
+	case callbackTypeSetForecastAlert:
+		err = s.onCallbackSetForecastAlert(ctx, c, callback, c.Sender, chat)
 		break
 	default:
 		err = fmt.Errorf("unknown callback data: \"%s\"", c.Data)
@@ -148,71 +291,68 @@ func (s *botService) onCallbackCore(arg interface{}, chat *chatEntity) error {
 }
 
 // onCallbackSubscribe handles "subscribe" callbacks
-func (s *botService) onCallbackSubscribe(c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
+func (s *botService) onCallbackSubscribe(ctx context.Context, c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
 	// Set current air quality for specified station
 	// We expected that this value is currently cached
-	status, err := s.WAQI.GetByStation(d.StationID)
+	status, err := s.WAQI.GetByStation(ctx, d.StationID)
 	if err != nil {
 		return err
 	}
 
 	// Store subscription into DB
-	chat.SetStateSubscribed(status.Station.ID)
-	err = s.DB.Update(chat)
+	subscription, err := s.subscribe(chat, status)
 	if err != nil {
 		return err
 	}
 
-	// Add in-memory subscription
-	s.SubscriptionsMutex.Lock()
-	defer s.SubscriptionsMutex.Unlock()
-	counter, exists := s.Subscriptions[d.StationID]
-	if !exists {
-		counter = 0
-		s.WAQI.Subscribe(d.StationID, s)
-		s.Logger.Printf("subscribed to station #%d", d.StationID)
-	}
-	s.Subscriptions[d.StationID] = counter + 1
-
 	// Show notification
-	err = s.Screens.SubscribedScreen(to, status, c.Message)
+	err = s.Screens.SubscribedScreen(chat, status, subscription, c.Message)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return s.notifyIfOffline(status)
 }
 
 // onCallbackUnsubscribe handles "unsubscribe" callbacks
-func (s *botService) onCallbackUnsubscribe(c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
+func (s *botService) onCallbackUnsubscribe(ctx context.Context, c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
 	// Set current air quality for specified station
 	// We expected that this value is currently cached
-	status, err := s.WAQI.GetByStation(d.StationID)
+	status, err := s.WAQI.GetByStation(ctx, d.StationID)
 	if err != nil {
 		return err
 	}
 
-	// Store subscription into DB
-	chat.SetStateNotSubscribed()
-	err = s.DB.Update(chat)
+	// Remove subscription from DB
+	err = s.unsubscribe(chat, d.StationID)
 	if err != nil {
 		return err
 	}
 
-	// Remove in-memory subscription
-	s.SubscriptionsMutex.Lock()
-	defer s.SubscriptionsMutex.Unlock()
-	counter, exists := s.Subscriptions[d.StationID]
-	if exists && counter == 1 {
-		delete(s.Subscriptions, d.StationID)
-		s.WAQI.Unsubscribe(d.StationID, s)
-		s.Logger.Printf("unsubscribed from station #%d", d.StationID)
-	} else {
-		s.Subscriptions[d.StationID] = counter - 1
+	// Show notification
+	err = s.Screens.LocationScreen(chat, status, c.Message)
+	if err != nil {
+		return err
 	}
 
-	// Show notification
-	err = s.Screens.LocationScreen(to, status, c.Message)
+	return nil
+}
+
+// onCallbackListUnsubscribe handles "unsubscribe" callbacks from subscription list
+func (s *botService) onCallbackListUnsubscribe(_ context.Context, c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
+	// Remove subscription from DB
+	err := s.unsubscribe(chat, d.StationID)
+	if err != nil {
+		return err
+	}
+
+	// Show updated subscription list
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	err = s.Screens.SubscriptionsScreen(chat, subscriptions, c.Message)
 	if err != nil {
 		return err
 	}
@@ -221,28 +361,150 @@ func (s *botService) onCallbackUnsubscribe(c *telebot.Callback, d *callbackJSON,
 }
 
 // onCallbackRefresh handles "refresh" callbacks
-func (s *botService) onCallbackRefresh(c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
+func (s *botService) onCallbackRefresh(ctx context.Context, c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
 	// Set current air quality for specified station
-	status, err := s.WAQI.GetByStation(d.StationID)
+	status, err := s.WAQI.GetByStation(ctx, d.StationID)
 	if err != nil {
 		return err
 	}
 
 	// Show notification
-	if chat.State == StateSubscribed && chat.SubscribedToStationID == d.StationID {
-		err = s.Screens.SubscribedScreen(to, status, c.Message)
-	} else {
-		err = s.Screens.LocationScreen(to, status, c.Message)
+	return s.showStatus(chat, status, c.Message)
+}
+
+// onCallbackAlertRules handles "rules" callbacks
+func (s *botService) onCallbackAlertRules(ctx context.Context, c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
+	status, err := s.WAQI.GetByStation(ctx, d.StationID)
+	if err != nil {
+		return err
 	}
+
+	subscription, err := s.DB.GetSubscription(chat.ChatID, d.StationID)
 	if err != nil {
 		return err
 	}
 
+	// Subscription might have been removed in the meantime
+	if subscription == nil {
+		return s.Screens.LocationScreen(chat, status, c.Message)
+	}
+
+	return s.Screens.AlertRulesScreen(chat, status, subscription, c.Message)
+}
+
+// onCallbackSetAlertRule handles "rule" callbacks
+func (s *botService) onCallbackSetAlertRule(ctx context.Context, c *telebot.Callback, d *callbackJSON, to telebot.Recipient, chat *chatEntity) error {
+	rule, err := waqi.ParseAlertRule(d.Arg)
+	if err != nil {
+		return err
+	}
+
+	status, err := s.WAQI.GetByStation(ctx, d.StationID)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.DB.GetSubscription(chat.ChatID, d.StationID)
+	if err != nil {
+		return err
+	}
+
+	// Subscription might have been removed in the meantime
+	if subscription == nil {
+		return s.Screens.LocationScreen(chat, status, c.Message)
+	}
+
+	// Store new rule and capture its initial state (in chat's AQI standard, as Update evaluates it)
+	subscription.Rule = rule.String()
+	_, subscription.RuleState = rule.Evaluate(chat.Standard().Convert(status), "")
+	err = s.DB.UpdateRule(subscription)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.SubscribedScreen(chat, status, subscription, c.Message)
+}
+
+// subscribe stores a subscription into DB and adds an in-memory subscription if necessary
+func (s *botService) subscribe(chat *chatEntity, status *waqi.Status) (*subscriptionEntity, error) {
+	created, err := s.DB.Subscribe(chat.ChatID, status.Station.ID, status.Station.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.DB.GetSubscription(chat.ChatID, status.Station.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !created {
+		return subscription, nil
+	}
+
+	// Capture initial state of alert rule, it is evaluated in chat's AQI standard
+	_, subscription.RuleState = subscription.AlertRule().Evaluate(chat.Standard().Convert(status), "")
+	err = s.DB.UpdateRuleState(subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	s.SubscriptionsMutex.Lock()
+	defer s.SubscriptionsMutex.Unlock()
+	counter, exists := s.Subscriptions[status.Station.ID]
+	if !exists {
+		counter = 0
+		s.WAQI.Subscribe(status.Station.ID, s)
+		s.Logger.Printf("subscribed to station #%d", status.Station.ID)
+	}
+	s.Subscriptions[status.Station.ID] = counter + 1
+
+	return subscription, nil
+}
+
+// notifyIfOffline notifies new subscribers of a station which is already offline
+// Station availability is only pushed when it changes, so they wouldn't be notified otherwise
+func (s *botService) notifyIfOffline(status *waqi.Status) error {
+	if !s.WAQI.IsOffline(status.Station.ID) {
+		return nil
+	}
+
+	return s.StationOffline(status, status.Time)
+}
+
+// unsubscribe removes a subscription from DB and removes an in-memory subscription if necessary
+func (s *botService) unsubscribe(chat *chatEntity, stationID int) error {
+	deleted, err := s.DB.Unsubscribe(chat.ChatID, stationID)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return nil
+	}
+
+	s.WAQI.UnscheduleDigest(subscriptionEntity{ChatID: chat.ChatID, StationID: stationID}.DigestKey())
+	s.WAQI.UnscheduleDigest(subscriptionEntity{ChatID: chat.ChatID, StationID: stationID}.ForecastAlertKey())
+
+	s.SubscriptionsMutex.Lock()
+	defer s.SubscriptionsMutex.Unlock()
+	counter, exists := s.Subscriptions[stationID]
+	if !exists {
+		return nil
+	}
+
+	if counter <= 1 {
+		delete(s.Subscriptions, stationID)
+		s.WAQI.Unsubscribe(stationID, s)
+		s.Logger.Printf("unsubscribed from station #%d", stationID)
+	} else {
+		s.Subscriptions[stationID] = counter - 1
+	}
+
 	return nil
 }
 
 // handle implements unified telegram event handler (with error handling)
-func (s *botService) handle(arg interface{}, c *telebot.Chat, u *telebot.User, f func(interface{}, *chatEntity) error) {
+func (s *botService) handle(arg interface{}, c *telebot.Chat, u *telebot.User, f func(context.Context, interface{}, *chatEntity) error) {
 	err := s.handleCore(arg, c, u, f)
 	if err != nil {
 		switch m := arg.(type) {
@@ -265,7 +527,8 @@ func (s *botService) handle(arg interface{}, c *telebot.Chat, u *telebot.User, f
 }
 
 // handleCore implements unified telegram event handler (without error handling)
-func (s *botService) handleCore(arg interface{}, c *telebot.Chat, u *telebot.User, f func(interface{}, *chatEntity) error) error {
+// Handler is cancelled if it takes longer than handlerTimeout or if bot is shut down
+func (s *botService) handleCore(arg interface{}, c *telebot.Chat, u *telebot.User, f func(context.Context, interface{}, *chatEntity) error) error {
 	_, userIDAllowed := s.AllowedUserIDs[u.ID]
 	_, usernameAllowed := s.AllowedUsernames[u.Username]
 
@@ -283,7 +546,10 @@ func (s *botService) handleCore(arg interface{}, c *telebot.Chat, u *telebot.Use
 		return err
 	}
 
-	err = f(arg, chat)
+	ctx, cancel := context.WithTimeout(s.Context, handlerTimeout)
+	defer cancel()
+
+	err = f(ctx, arg, chat)
 	if err != nil {
 		return err
 	}
@@ -297,16 +563,146 @@ func (s *botService) handleCore(arg interface{}, c *telebot.Chat, u *telebot.Use
 func (s *botService) Update(status *waqi.Status, prevStatus *waqi.Status) error {
 	s.Logger.Printf("UPDATE: was %s, became %s", status, prevStatus)
 
-	chats, err := s.DB.GetSubscribedChats(status.Station.ID)
+	subscriptions, err := s.DB.GetStationSubscriptions(status.Station.ID)
 	if err != nil {
 		return err
 	}
 
-	for _, chat := range chats {
-		err = s.Screens.UpdatedScreen(chat, status, prevStatus, nil)
+	for _, subscription := range subscriptions {
+		chat, err := s.DB.GetChat(subscription.ChatID)
 		if err != nil {
 			return err
 		}
+		if chat == nil {
+			continue
+		}
+
+		// Alert rules are evaluated in chat's AQI standard
+		chatStatus := chat.Standard().Convert(status)
+		rule := subscription.AlertRule()
+		event, state := rule.Evaluate(chatStatus, subscription.RuleState)
+		if state != subscription.RuleState {
+			subscription.RuleState = state
+			err = s.DB.UpdateRuleState(subscription)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Subscribers in daily digest mode are not notified about changes
+		if event == waqi.NoAlertEvent || subscription.IsDigest() {
+			continue
+		}
+
+		// Hold updates during quiet hours
+		now := time.Now()
+		if chat.IsQuietTime(now) {
+			text := fmt.Sprintf("<code>%s</code> %s",
+				now.In(chat.Location()).Format("15:04"),
+				s.Screens.generateAlertText(rule, event, chatStatus))
+			err = s.DB.HoldUpdate(chat.ChatID, status.Station.ID, status.Station.Name, text)
+			if err != nil {
+				return err
+			}
+
+			s.Logger.Printf("held update for %d until quiet hours end", chat.ChatID)
+			continue
+		}
+
+		err = s.Screens.UpdatedScreen(chat, status, prevStatus, subscription, event, nil)
+		if err != nil {
+			s.Logger.Printf("unable to send update to %d: %s", chat.ChatID, err)
+		}
+	}
+
+	return nil
+}
+
+// StationOffline handles a station which hasn't reported new data since specified time
+func (s *botService) StationOffline(status *waqi.Status, since time.Time) error {
+	ctx, cancel := context.WithTimeout(s.Context, handlerTimeout)
+	defer cancel()
+
+	// Alternatives are only queried if there is someone to notify
+	var alternatives []*waqi.NearbyStation
+	return s.notifyAvailability(status, true, func(chat *chatEntity) error {
+		if alternatives == nil {
+			nearby, err := s.getNearbyStations(ctx, status.Station.Lat, status.Station.Lon, status.Station.ID)
+			if err != nil {
+				s.Logger.Printf("unable to query alternatives for station #%d: %s", status.Station.ID, err)
+			}
+
+			// Stations without recent data are not an alternative
+			alternatives = make([]*waqi.NearbyStation, 0, len(nearby))
+			for _, station := range nearby {
+				if station.AQI != nil {
+					alternatives = append(alternatives, station)
+				}
+			}
+		}
+
+		return s.Screens.StationOfflineScreen(chat, status, since, alternatives)
+	}, func(chat *chatEntity) string {
+		return s.Screens.generateStationOfflineText(since, chat.Location())
+	})
+}
+
+// StationOnline handles a station which has resumed reporting data after being offline since specified time
+func (s *botService) StationOnline(status *waqi.Status, since time.Time) error {
+	return s.notifyAvailability(status, false, func(chat *chatEntity) error {
+		return s.Screens.StationOnlineScreen(chat, status, since)
+	}, func(chat *chatEntity) string {
+		return s.Screens.generateStationOnlineText(since, chat.Location())
+	})
+}
+
+// notifyAvailability notifies station subscribers about station going offline or coming back online
+// Each subscriber is notified once, and only those who knew station was offline are notified of its recovery
+func (s *botService) notifyAvailability(status *waqi.Status, offline bool, send func(chat *chatEntity) error, hold func(chat *chatEntity) string) error {
+	subscriptions, err := s.DB.GetStationSubscriptions(status.Station.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription.Offline == offline {
+			continue
+		}
+
+		subscription.Offline = offline
+		changed, err := s.DB.SetOffline(subscription)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+
+		chat, err := s.DB.GetChat(subscription.ChatID)
+		if err != nil {
+			return err
+		}
+		if chat == nil {
+			continue
+		}
+
+		// Hold notifications during quiet hours
+		now := time.Now()
+		if chat.IsQuietTime(now) {
+			text := fmt.Sprintf("<code>%s</code> %s", now.In(chat.Location()).Format("15:04"), hold(chat))
+			err = s.DB.HoldUpdate(chat.ChatID, status.Station.ID, status.Station.Name, text)
+			if err != nil {
+				return err
+			}
+
+			s.Logger.Printf("held availability update for %d until quiet hours end", chat.ChatID)
+			continue
+		}
+
+		err = send(chat)
+		if err != nil {
+			s.Logger.Printf("unable to send availability update to %d: %s", chat.ChatID, err)
+		}
 	}
 
 	return nil
@@ -8,12 +8,32 @@ const (
 	callbackTypeSubscribe   callbackType = "subscribe"
 	callbackTypeRefresh     callbackType = "refresh"
 	callbackTypeUnsubscribe callbackType = "unsubscribe"
+
+	callbackTypeListUnsubscribe  callbackType = "list_unsub"
+	callbackTypeAlertRules       callbackType = "rules"
+	callbackTypeSetAlertRule     callbackType = "rule"
+	callbackTypeSettings         callbackType = "settings"
+	callbackTypeTimeZone         callbackType = "tz"
+	callbackTypeSetTimeZone      callbackType = "set_tz"
+	callbackTypeQuietHours       callbackType = "quiet"
+	callbackTypeSetQuietHours    callbackType = "set_quiet"
+	callbackTypeScale            callbackType = "scale"
+	callbackTypeSetScale         callbackType = "set_scale"
+	callbackTypeProfile          callbackType = "profile"
+	callbackTypeSetProfile       callbackType = "set_profile"
+	callbackTypeHistory          callbackType = "history"
+	callbackTypeDigest           callbackType = "digest"
+	callbackTypeSetDigest        callbackType = "set_digest"
+	callbackTypeStation          callbackType = "station"
+	callbackTypeForecast         callbackType = "forecast"
+	callbackTypeSetForecastAlert callbackType = "set_forecast"
 )
 
 type callbackJSON struct {
 	Type      callbackType `json:"type"`
 	StationID int          `json:"station_id"`
-	UID       string       `json:"uid"`
+	UID       string       `json:"uid,omitempty"`
+	Arg       string       `json:"arg,omitempty"`
 }
 
 func (c callbackJSON) String() string {
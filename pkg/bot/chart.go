@@ -0,0 +1,316 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+const (
+	chartWidth        = 800
+	chartHeight       = 480
+	chartMarginLeft   = 48
+	chartMarginRight  = 16
+	chartMarginTop    = 32
+	chartMarginBottom = 64
+
+	// chartMaxGap is a max gap between measurements that are connected with a line
+	chartMaxGap = 3 * time.Hour
+)
+
+var (
+	chartBackgroundColor = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	chartTextColor       = color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+	chartGridColor       = color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0x30}
+
+	chartSeriesColors = map[waqi.Parameter]color.RGBA{
+		waqi.AQIParameter:  {R: 0x00, G: 0x00, B: 0x00, A: 0xff},
+		waqi.PM25Parameter: {R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+		waqi.PM10Parameter: {R: 0x8c, G: 0x56, B: 0x4b, A: 0xff},
+		waqi.O3Parameter:   {R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+		waqi.NO2Parameter:  {R: 0x94, G: 0x67, B: 0xbd, A: 0xff},
+		waqi.SO2Parameter:  {R: 0xbc, G: 0xbd, B: 0x22, A: 0xff},
+		waqi.COParameter:   {R: 0x7f, G: 0x7f, B: 0x7f, A: 0xff},
+	}
+)
+
+// historyChart renders a chart of historical measurements into PNG image
+type historyChart struct {
+	img      *image.RGBA
+	plot     image.Rectangle
+	from     time.Time
+	to       time.Time
+	maxValue float32
+	standard waqi.Standard
+	loc      *time.Location
+}
+
+// renderHistoryChart renders AQI and pollutants of specified measurements into PNG image
+// Measurements are expected to be expressed in specified standard, chart background is coloured according to its categories
+func renderHistoryChart(title string, statuses []*waqi.Status, standard waqi.Standard, from, to time.Time, loc *time.Location) ([]byte, error) {
+	c := &historyChart{
+		img: image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight)),
+		plot: image.Rect(
+			chartMarginLeft,
+			chartMarginTop,
+			chartWidth-chartMarginRight,
+			chartHeight-chartMarginBottom),
+		from:     from,
+		to:       to,
+		maxValue: calcChartMaxValue(statuses),
+		standard: standard.OrDefault(),
+		loc:      loc,
+	}
+
+	draw.Draw(c.img, c.img.Bounds(), image.NewUniform(chartBackgroundColor), image.Point{}, draw.Src)
+	c.DrawLevelBands()
+	c.DrawGrid()
+	c.DrawText(chartMarginLeft, chartMarginTop-12, title, chartTextColor)
+
+	// Series are drawn in reverse order so AQI line appears on top
+	visible := make(map[waqi.Parameter]bool)
+	for i := len(waqi.Parameters) - 1; i >= 0; i-- {
+		parameter := waqi.Parameters[i]
+		thickness := 2
+		if parameter == waqi.AQIParameter {
+			thickness = 3
+		}
+
+		visible[parameter] = c.DrawSeries(statuses, parameter, chartSeriesColors[parameter], thickness)
+	}
+
+	legendX := chartMarginLeft
+	for _, parameter := range waqi.Parameters {
+		if visible[parameter] {
+			c.DrawLegendItem(legendX, parameter)
+			legendX += 80
+		}
+	}
+
+	var buffer bytes.Buffer
+	err := png.Encode(&buffer, c.img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// DrawLevelBands fills plot background with colours of air quality categories
+func (c *historyChart) DrawLevelBands() {
+	for y := c.plot.Min.Y; y < c.plot.Max.Y; y++ {
+		category := c.standard.Category(c.YToValue(y))
+		band := image.Rect(c.plot.Min.X, y, c.plot.Max.X, y+1)
+		draw.Draw(c.img, band, image.NewUniform(getCategoryBackgroundColor(category)), image.Point{}, draw.Src)
+	}
+}
+
+// DrawGrid draws axis labels and grid lines
+func (c *historyChart) DrawGrid() {
+	// Horizontal lines
+	step := float32(50)
+	if c.maxValue > 300 {
+		step = 100
+	}
+	for value := float32(0); value <= c.maxValue; value += step {
+		y := c.ValueToY(value)
+		c.DrawHorizontalLine(y, chartGridColor)
+		label := fmt.Sprintf("%0.0f", value)
+		c.DrawText(c.plot.Min.X-8-7*len(label), y+4, label, chartTextColor)
+	}
+
+	// Vertical lines
+	period := c.to.Sub(c.from)
+	tickStep := 3 * time.Hour
+	format := "15:04"
+	if period > 48*time.Hour {
+		tickStep = 24 * time.Hour
+		format = "Jan 2"
+	}
+	if period > 10*24*time.Hour {
+		tickStep = 5 * 24 * time.Hour
+	}
+
+	from := c.from.In(c.loc)
+	tick := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, c.loc)
+	for tick.Before(c.to) {
+		if !tick.Before(c.from) {
+			x := c.TimeToX(tick)
+			c.DrawVerticalLine(x, chartGridColor)
+			label := tick.Format(format)
+			c.DrawText(x-7*len(label)/2, c.plot.Max.Y+16, label, chartTextColor)
+		}
+		tick = tick.Add(tickStep)
+	}
+}
+
+// DrawSeries draws a line of specified parameter
+// Returns false if there are no measurements of this parameter
+func (c *historyChart) DrawSeries(statuses []*waqi.Status, parameter waqi.Parameter, clr color.RGBA, thickness int) bool {
+	hasValues := false
+	var prevX, prevY int
+	var prevTime time.Time
+	for _, status := range statuses {
+		value := status.Value(parameter)
+		if value == nil {
+			continue
+		}
+
+		x := c.TimeToX(status.Time)
+		y := c.ValueToY(*value)
+		if hasValues && status.Time.Sub(prevTime) <= chartMaxGap {
+			c.DrawLine(prevX, prevY, x, y, clr, thickness)
+		} else {
+			c.DrawLine(x, y, x, y, clr, thickness)
+		}
+
+		hasValues = true
+		prevX, prevY, prevTime = x, y, status.Time
+	}
+
+	return hasValues
+}
+
+// DrawLegendItem draws a legend item for a parameter
+func (c *historyChart) DrawLegendItem(x int, parameter waqi.Parameter) {
+	y := chartHeight - 20
+	square := image.Rect(x, y-9, x+10, y+1)
+	draw.Draw(c.img, square, image.NewUniform(chartSeriesColors[parameter]), image.Point{}, draw.Src)
+	c.DrawText(x+14, y, parameter.String(), chartTextColor)
+}
+
+// DrawLine draws a line using Bresenham's algorithm
+func (c *historyChart) DrawLine(x0, y0, x1, y1 int, clr color.RGBA, thickness int) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	e := dx + dy
+	for {
+		c.DrawDot(x0, y0, clr, thickness)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// DrawDot draws a square dot clipped by plot area
+func (c *historyChart) DrawDot(x, y int, clr color.RGBA, size int) {
+	dot := image.Rect(x-size/2, y-size/2, x-size/2+size, y-size/2+size).Intersect(c.plot)
+	draw.Draw(c.img, dot, image.NewUniform(clr), image.Point{}, draw.Src)
+}
+
+// DrawHorizontalLine draws a horizontal line across plot area
+func (c *historyChart) DrawHorizontalLine(y int, clr color.RGBA) {
+	line := image.Rect(c.plot.Min.X, y, c.plot.Max.X, y+1).Intersect(c.plot)
+	draw.Draw(c.img, line, image.NewUniform(clr), image.Point{}, draw.Over)
+}
+
+// DrawVerticalLine draws a vertical line across plot area
+func (c *historyChart) DrawVerticalLine(x int, clr color.RGBA) {
+	line := image.Rect(x, c.plot.Min.Y, x+1, c.plot.Max.Y).Intersect(c.plot)
+	draw.Draw(c.img, line, image.NewUniform(clr), image.Point{}, draw.Over)
+}
+
+// DrawText draws a text with its baseline at specified point
+func (c *historyChart) DrawText(x, y int, text string, clr color.RGBA) {
+	d := &font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(clr),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// TimeToX converts time into X coordinate
+func (c *historyChart) TimeToX(t time.Time) int {
+	total := c.to.Sub(c.from).Seconds()
+	if total <= 0 {
+		return c.plot.Min.X
+	}
+
+	ratio := t.Sub(c.from).Seconds() / total
+	return c.plot.Min.X + int(math.Round(ratio*float64(c.plot.Dx()-1)))
+}
+
+// ValueToY converts value into Y coordinate
+func (c *historyChart) ValueToY(value float32) int {
+	ratio := float64(value / c.maxValue)
+	return c.plot.Max.Y - 1 - int(math.Round(ratio*float64(c.plot.Dy()-1)))
+}
+
+// YToValue converts Y coordinate into value
+func (c *historyChart) YToValue(y int) float32 {
+	ratio := float32(c.plot.Max.Y-1-y) / float32(c.plot.Dy()-1)
+	return ratio * c.maxValue
+}
+
+// calcChartMaxValue returns an upper bound of chart values rounded up to 50
+func calcChartMaxValue(statuses []*waqi.Status) float32 {
+	maxValue := float32(100)
+	for _, status := range statuses {
+		for _, parameter := range waqi.Parameters {
+			value := status.Value(parameter)
+			if value != nil && *value*1.1 > maxValue {
+				maxValue = *value * 1.1
+			}
+		}
+	}
+
+	return float32(math.Ceil(float64(maxValue)/50) * 50)
+}
+
+// getCategoryBackgroundColor returns a pale colour of air quality category
+func getCategoryBackgroundColor(category *waqi.Category) color.RGBA {
+	if category == nil {
+		return chartBackgroundColor
+	}
+
+	clr := color.RGBA{A: 0xff}
+	_, err := fmt.Sscanf(category.Color, "#%02x%02x%02x", &clr.R, &clr.G, &clr.B)
+	if err != nil {
+		return chartBackgroundColor
+	}
+
+	// Blend with white
+	const alpha = 0.25
+	blend := func(c uint8) uint8 {
+		return uint8(float64(c)*alpha + 0xff*(1-alpha))
+	}
+	return color.RGBA{R: blend(clr.R), G: blend(clr.G), B: blend(clr.B), A: 0xff}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
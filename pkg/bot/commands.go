@@ -0,0 +1,307 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+// botCommands contains commands that are shown in Telegram client menu
+var botCommands = []telebot.Command{
+	{Text: "status", Description: "Show air quality at subscribed stations"},
+	{Text: "list", Description: "List subscriptions"},
+	{Text: "city", Description: "Show air quality in a city, e.g. /city paris"},
+	{Text: "station", Description: "Show air quality at a station, e.g. /station 1234"},
+	{Text: "subscribe", Description: "Subscribe to a station or a city, e.g. /subscribe 1234"},
+	{Text: "unsubscribe", Description: "Unsubscribe from a station"},
+	{Text: "history", Description: "Show air quality history chart"},
+	{Text: "forecast", Description: "Show air quality forecast"},
+	{Text: "settings", Description: "Change time zone, quiet hours, AQI scale and health profile"},
+	{Text: "help", Description: "Show available commands"},
+}
+
+// registerCommands registers command handlers and publishes command list to Telegram
+func (s *botService) registerCommands() {
+	s.Bot.Handle("/status", s.onStatus)
+	s.Bot.Handle("/list", s.onList)
+	s.Bot.Handle("/city", s.onCity)
+	s.Bot.Handle("/station", s.onStation)
+	s.Bot.Handle("/subscribe", s.onSubscribe)
+	s.Bot.Handle("/unsubscribe", s.onUnsubscribe)
+	s.Bot.Handle("/help", s.onHelp)
+
+	err := s.Bot.SetCommands(botCommands)
+	if err != nil {
+		s.Logger.Printf("unable to register bot commands: %s", err)
+	}
+}
+
+// onStatus handles "/status" command
+func (s *botService) onStatus(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onStatusCore)
+}
+
+// onList handles "/list" command
+func (s *botService) onList(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onListCore)
+}
+
+// onCity handles "/city" command
+func (s *botService) onCity(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onCityCore)
+}
+
+// onStation handles "/station" command
+func (s *botService) onStation(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onStationCore)
+}
+
+// onSubscribe handles "/subscribe" command
+func (s *botService) onSubscribe(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onSubscribeCore)
+}
+
+// onUnsubscribe handles "/unsubscribe" command
+func (s *botService) onUnsubscribe(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onUnsubscribeCore)
+}
+
+// onHelp handles "/help" command
+func (s *botService) onHelp(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onHelpCore)
+}
+
+// resetInput cancels pending text input since any command interrupts it
+func (s *botService) resetInput(chat *chatEntity) error {
+	if chat.Input == "" {
+		return nil
+	}
+
+	chat.Input = ""
+	return s.DB.Update(chat)
+}
+
+// onStatusCore handles "/status" command (without error handling)
+func (s *botService) onStatusCore(ctx context.Context, arg interface{}, chat *chatEntity) error {
+	m := arg.(*telebot.Message)
+	err := s.resetInput(chat)
+	if err != nil {
+		return err
+	}
+
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) == 0 {
+		return s.Screens.SubscriptionsScreen(chat, subscriptions, nil)
+	}
+
+	for _, subscription := range subscriptions {
+		status, err := s.WAQI.GetByStation(ctx, subscription.StationID)
+		if err != nil {
+			s.Logger.Printf("unable to query status for station #%d: %s", subscription.StationID, err)
+			return s.Screens.ErrorScreen(m.Chat)
+		}
+
+		err = s.Screens.SubscribedScreen(chat, status, subscription, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// onListCore handles "/list" command (without error handling)
+func (s *botService) onListCore(_ context.Context, _ interface{}, chat *chatEntity) error {
+	err := s.resetInput(chat)
+	if err != nil {
+		return err
+	}
+
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.SubscriptionsScreen(chat, subscriptions, nil)
+}
+
+// onCityCore handles "/city <name>" command (without error handling)
+func (s *botService) onCityCore(ctx context.Context, arg interface{}, chat *chatEntity) error {
+	m := arg.(*telebot.Message)
+	err := s.resetInput(chat)
+	if err != nil {
+		return err
+	}
+
+	city := strings.TrimSpace(m.Payload)
+	if city == "" {
+		return s.Screens.InvalidInputScreen(chat, "Send me a city name, e.g. <code>/city paris</code>.")
+	}
+
+	status, err := s.WAQI.GetByCity(ctx, city)
+	if err != nil {
+		return s.onLookupError(chat, err)
+	}
+
+	return s.showStatus(chat, status, nil)
+}
+
+// onStationCore handles "/station <id>" command (without error handling)
+func (s *botService) onStationCore(ctx context.Context, arg interface{}, chat *chatEntity) error {
+	m := arg.(*telebot.Message)
+	err := s.resetInput(chat)
+	if err != nil {
+		return err
+	}
+
+	stationID, ok := parseStationID(m.Payload)
+	if !ok {
+		return s.Screens.InvalidInputScreen(chat, "Send me a station ID, e.g. <code>/station 1234</code>.")
+	}
+
+	status, err := s.WAQI.GetByStation(ctx, stationID)
+	if err != nil {
+		return s.onLookupError(chat, err)
+	}
+
+	return s.showStatus(chat, status, nil)
+}
+
+// onSubscribeCore handles "/subscribe <station>" command (without error handling)
+// Station can be specified either by its ID or by a city name
+func (s *botService) onSubscribeCore(ctx context.Context, arg interface{}, chat *chatEntity) error {
+	m := arg.(*telebot.Message)
+	err := s.resetInput(chat)
+	if err != nil {
+		return err
+	}
+
+	query := strings.TrimSpace(m.Payload)
+	if query == "" {
+		return s.Screens.InvalidInputScreen(chat,
+			"Send me a station ID or a city name, e.g. <code>/subscribe 1234</code> or <code>/subscribe paris</code>.")
+	}
+
+	var status *waqi.Status
+	if stationID, ok := parseStationID(query); ok {
+		status, err = s.WAQI.GetByStation(ctx, stationID)
+	} else {
+		status, err = s.WAQI.GetByCity(ctx, query)
+	}
+	if err != nil {
+		return s.onLookupError(chat, err)
+	}
+
+	subscription, err := s.subscribe(chat, status)
+	if err != nil {
+		return err
+	}
+
+	err = s.Screens.SubscribedScreen(chat, status, subscription, nil)
+	if err != nil {
+		return err
+	}
+
+	return s.notifyIfOffline(status)
+}
+
+// onUnsubscribeCore handles "/unsubscribe [station]" command (without error handling)
+// If station is not specified and there are several subscriptions, subscription list is shown
+func (s *botService) onUnsubscribeCore(_ context.Context, arg interface{}, chat *chatEntity) error {
+	m := arg.(*telebot.Message)
+	err := s.resetInput(chat)
+	if err != nil {
+		return err
+	}
+
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	stationID, ok := parseStationID(m.Payload)
+	if !ok {
+		if strings.TrimSpace(m.Payload) != "" {
+			return s.Screens.InvalidInputScreen(chat, "Send me a station ID, e.g. <code>/unsubscribe 1234</code>.")
+		}
+
+		if len(subscriptions) != 1 {
+			return s.Screens.SubscriptionsScreen(chat, subscriptions, nil)
+		}
+
+		stationID = subscriptions[0].StationID
+	}
+
+	err = s.unsubscribe(chat, stationID)
+	if err != nil {
+		return err
+	}
+
+	subscriptions, err = s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.SubscriptionsScreen(chat, subscriptions, nil)
+}
+
+// onHelpCore handles "/help" command (without error handling)
+func (s *botService) onHelpCore(_ context.Context, _ interface{}, chat *chatEntity) error {
+	err := s.resetInput(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.HelpScreen(chat, botCommands)
+}
+
+// onLookupError shows a "not found" message if WAQI service has rejected a query
+// Other errors are returned as is
+func (s *botService) onLookupError(chat *chatEntity, err error) error {
+	if _, ok := err.(waqi.Error); ok {
+		return s.Screens.InvalidInputScreen(chat, "Sorry, I couldn't find this station. Check its name or ID and try again.")
+	}
+
+	return err
+}
+
+// showStatus shows current air quality at a station
+// Subscription details are shown if chat is subscribed to this station
+func (s *botService) showStatus(chat *chatEntity, status *waqi.Status, message telebot.Editable) error {
+	subscription, err := s.DB.GetSubscription(chat.ChatID, status.Station.ID)
+	if err != nil {
+		return err
+	}
+
+	if subscription != nil {
+		return s.Screens.SubscribedScreen(chat, status, subscription, message)
+	}
+
+	return s.Screens.LocationScreen(chat, status, message)
+}
+
+// parseStationID parses a station ID (optionally prefixed with "@")
+func parseStationID(str string) (int, bool) {
+	str = strings.TrimPrefix(strings.TrimSpace(str), "@")
+	stationID, err := strconv.Atoi(str)
+	if err != nil || stationID <= 0 {
+		return 0, false
+	}
+
+	return stationID, true
+}
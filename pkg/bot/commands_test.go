@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+func TestParseStationID(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		ok       bool
+	}{
+		{"1234", 1234, true},
+		{" 1234 ", 1234, true},
+		{"@1234", 1234, true},
+		{"", 0, false},
+		{"@", 0, false},
+		{"0", 0, false},
+		{"-5", 0, false},
+		{"12ab", 0, false},
+		{"paris", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			a := assert.New(t)
+			stationID, ok := parseStationID(test.input)
+			a.Equal(test.ok, ok)
+			a.Equal(test.expected, stationID)
+		})
+	}
+}
+
+// commandHandler is a command handler without error handling
+type commandHandler func(ctx context.Context, arg interface{}, chat *chatEntity) error
+
+func TestCommands(t *testing.T) {
+	service := &fakeWAQI{
+		statuses: map[int]*waqi.Status{
+			123: screenStatus(time.Now().UTC(), 42),
+		},
+	}
+
+	tests := []struct {
+		name     string
+		handler  func(s *botService) commandHandler
+		payload  string
+		expected string
+	}{
+		{"station without id", func(s *botService) commandHandler { return s.onStationCore }, "", "Send me a station ID"},
+		{"station with malformed id", func(s *botService) commandHandler { return s.onStationCore }, "paris", "Send me a station ID"},
+		{"unknown station", func(s *botService) commandHandler { return s.onStationCore }, "999", "couldn't find this station"},
+		{"known station", func(s *botService) commandHandler { return s.onStationCore }, "@123", "Station #123"},
+		{"city without name", func(s *botService) commandHandler { return s.onCityCore }, " ", "Send me a city name"},
+		{"subscribe without station", func(s *botService) commandHandler { return s.onSubscribeCore }, "", "Send me a station ID or a city name"},
+		{"subscribe to unknown station", func(s *botService) commandHandler { return s.onSubscribeCore }, "999", "couldn't find this station"},
+		{"unsubscribe with malformed id", func(s *botService) commandHandler { return s.onUnsubscribeCore }, "home", "Send me a station ID"},
+		{"unsubscribe without subscription", func(s *botService) commandHandler { return s.onUnsubscribeCore }, "123", "You have no subscriptions"},
+		{"status without subscription", func(s *botService) commandHandler { return s.onStatusCore }, "", "You have no subscriptions"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := assert.New(t)
+			s, telegram := newTestBotService(t, service)
+			chat, err := s.DB.GetOrCreate(1234, 465, "username")
+			a.Nil(err)
+
+			m := &telebot.Message{Chat: &telebot.Chat{ID: 1234}, Payload: test.payload}
+			err = test.handler(s)(context.Background(), m, chat)
+			a.Nil(err)
+
+			messages := telegram.Messages()
+			a.Len(messages, 1)
+			a.Contains(messages[0], test.expected)
+		})
+	}
+}
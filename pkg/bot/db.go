@@ -10,21 +10,34 @@ import (
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	gormLogger "gorm.io/gorm/logger"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
 )
 
 const (
-	StateNotSubscribed = "not_subscribed"
-	StateSubscribed    = "subscribed"
+	// legacyStateSubscribed is a chat state used before subscriptions were moved into a separate table
+	legacyStateSubscribed = "subscribed"
+
+	// subscriptionModeAlerts means that subscriber is notified when alert rule is triggered
+	subscriptionModeAlerts = "alerts"
+
+	// subscriptionModeDigest means that subscriber receives a daily digest at scheduled time
+	subscriptionModeDigest = "digest"
 )
 
 type chatEntity struct {
-	ChatID                int64     `gorm:"column:id;unique_index;primary_key"`
-	UserID                int       `gorm:"column:user_id;unique_index"`
-	UserName              string    `gorm:"column:user_name"`
-	State                 string    `gorm:"column:state;index"`
-	SubscribedToStationID int       `gorm:"column:station_id;index"`
-	Updated               time.Time `gorm:"column:updated"`
+	ChatID         int64     `gorm:"column:id;unique_index;primary_key"`
+	UserID         int       `gorm:"column:user_id;unique_index"`
+	UserName       string    `gorm:"column:user_name"`
+	TimeZone       string    `gorm:"column:time_zone"`
+	QuietHoursFrom int       `gorm:"column:quiet_from"`
+	QuietHoursTo   int       `gorm:"column:quiet_to"`
+	Scale          string    `gorm:"column:scale"`
+	Profile        string    `gorm:"column:profile"`
+	Input          string    `gorm:"column:input"`
+	Updated        time.Time `gorm:"column:updated"`
 }
 
 // TableName overrides the table name for chatEntity
@@ -37,16 +50,120 @@ func (e chatEntity) Recipient() string {
 	return fmt.Sprintf("%d", e.ChatID)
 }
 
-// SetStateNotSubscribed moves entity into "not_subscribed" state
-func (e *chatEntity) SetStateNotSubscribed() {
-	e.State = StateNotSubscribed
-	e.SubscribedToStationID = 0
+// Location returns chat's time zone
+// Falls back to UTC if time zone is not set or malformed
+func (e chatEntity) Location() *time.Location {
+	loc, _, err := parseTimeZone(e.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// HasQuietHours returns true if quiet hours are enabled for chat
+func (e chatEntity) HasQuietHours() bool {
+	return e.QuietHoursFrom != e.QuietHoursTo
+}
+
+// IsQuietTime checks whether specified moment falls into chat's quiet hours
+func (e chatEntity) IsQuietTime(t time.Time) bool {
+	if !e.HasQuietHours() {
+		return false
+	}
+
+	local := t.In(e.Location())
+	minutes := local.Hour()*60 + local.Minute()
+	if e.QuietHoursFrom < e.QuietHoursTo {
+		return minutes >= e.QuietHoursFrom && minutes < e.QuietHoursTo
+	}
+
+	return minutes >= e.QuietHoursFrom || minutes < e.QuietHoursTo
+}
+
+// Standard returns chat's AQI standard
+// Falls back to US EPA if scale is not set or unknown
+func (e chatEntity) Standard() waqi.Standard {
+	standard, err := waqi.ParseStandard(e.Scale)
+	if err != nil {
+		return waqi.USEPAStandard
+	}
+
+	return standard
+}
+
+// Audience returns chat's health advice audience
+// Falls back to general public if profile is not set or unknown
+func (e chatEntity) Audience() waqi.Audience {
+	audience, err := waqi.ParseAudience(e.Profile)
+	if err != nil {
+		return waqi.GeneralAudience
+	}
+
+	return audience
+}
+
+type subscriptionEntity struct {
+	ChatID        int64     `gorm:"column:chat_id;primary_key;auto_increment:false"`
+	StationID     int       `gorm:"column:station_id;primary_key;auto_increment:false;index"`
+	StationName   string    `gorm:"column:station_name"`
+	Rule          string    `gorm:"column:rule"`
+	RuleState     string    `gorm:"column:rule_state"`
+	Mode          string    `gorm:"column:mode"`
+	DigestTime    int       `gorm:"column:digest_time"`
+	Offline       bool      `gorm:"column:offline"`
+	ForecastAlert bool      `gorm:"column:forecast_alert"`
+	Created       time.Time `gorm:"column:created"`
+}
+
+// TableName overrides the table name for subscriptionEntity
+func (subscriptionEntity) TableName() string {
+	return "subscriptions"
+}
+
+// Recipient returns legit Telegram chat_id or username
+func (e subscriptionEntity) Recipient() string {
+	return fmt.Sprintf("%d", e.ChatID)
+}
+
+// AlertRule returns parsed alert rule of subscription
+// Falls back to default alert rule if stored rule is malformed
+func (e subscriptionEntity) AlertRule() *waqi.AlertRule {
+	rule, err := waqi.ParseAlertRule(e.Rule)
+	if err != nil {
+		return waqi.DefaultAlertRule()
+	}
+
+	return rule
+}
+
+// IsDigest returns true if subscriber receives a daily digest instead of alerts
+func (e subscriptionEntity) IsDigest() bool {
+	return e.Mode == subscriptionModeDigest
+}
+
+// DigestKey returns a key of subscription's daily digest schedule
+func (e subscriptionEntity) DigestKey() string {
+	return fmt.Sprintf("%d/%d", e.ChatID, e.StationID)
+}
+
+// ForecastAlertKey returns a key of subscription's evening forecast alert schedule
+func (e subscriptionEntity) ForecastAlertKey() string {
+	return fmt.Sprintf("%s%d/%d", forecastAlertKeyPrefix, e.ChatID, e.StationID)
 }
 
-// SetStateSubscribed moves entity into "subscribed" state
-func (e *chatEntity) SetStateSubscribed(stationID int) {
-	e.State = StateSubscribed
-	e.SubscribedToStationID = stationID
+type heldUpdateEntity struct {
+	ChatID      int64     `gorm:"column:chat_id;primary_key;auto_increment:false"`
+	StationID   int       `gorm:"column:station_id;primary_key;auto_increment:false"`
+	StationName string    `gorm:"column:station_name"`
+	Text        string    `gorm:"column:text"`
+	Created     time.Time `gorm:"column:created"`
+	Updated     time.Time `gorm:"column:updated"`
+}
+
+// TableName overrides the table name for heldUpdateEntity
+func (heldUpdateEntity) TableName() string {
+	return "held_updates"
 }
 
 type DB interface {
@@ -54,15 +171,70 @@ type DB interface {
 	// If chat is not registered yet, it will be created
 	GetOrCreate(chatID int64, userID int, username string) (*chatEntity, error)
 
+	// GetChat fetches a chat state from DB
+	// Returns nil if chat is not registered
+	GetChat(chatID int64) (*chatEntity, error)
+
 	// Update stores chat state into DB
 	Update(chat *chatEntity) error
 
+	// Subscribe subscribes a chat to a station
+	// Returns false if chat has been already subscribed to this station
+	Subscribe(chatID int64, stationID int, stationName string) (bool, error)
+
+	// Unsubscribe unsubscribes a chat from a station
+	// Returns false if chat has not been subscribed to this station
+	Unsubscribe(chatID int64, stationID int) (bool, error)
+
+	// GetSubscription returns a subscription of a chat to a station
+	// Returns nil if chat is not subscribed to this station
+	GetSubscription(chatID int64, stationID int) (*subscriptionEntity, error)
+
+	// UpdateRule stores subscription alert rule and its state into DB
+	UpdateRule(subscription *subscriptionEntity) error
+
+	// UpdateRuleState stores state of subscription alert rule into DB
+	UpdateRuleState(subscription *subscriptionEntity) error
+
+	// UpdateDigest stores subscription mode and daily digest time into DB
+	UpdateDigest(subscription *subscriptionEntity) error
+
+	// UpdateForecastAlert stores subscription evening forecast alert setting into DB
+	UpdateForecastAlert(subscription *subscriptionEntity) error
+
+	// SetOffline stores station availability known to subscriber into DB
+	// Returns false if it has been already stored (e.g. by a concurrent notification)
+	SetOffline(subscription *subscriptionEntity) (bool, error)
+
+	// GetSubscriptions returns all subscriptions of specified chat
+	GetSubscriptions(chatID int64) ([]*subscriptionEntity, error)
+
+	// GetStationSubscriptions returns all subscriptions to specified station
+	GetStationSubscriptions(stationID int) ([]*subscriptionEntity, error)
+
+	// GetDigestSubscriptions returns all subscriptions in daily digest mode
+	GetDigestSubscriptions() ([]*subscriptionEntity, error)
+
+	// GetForecastAlertSubscriptions returns all subscriptions with evening forecast alerts turned on
+	GetForecastAlertSubscriptions() ([]*subscriptionEntity, error)
+
 	// GetSubscribedStationIDs returns map of stations with subscription
 	// Map key is station ID and value is count of active subscriptions
 	GetSubscribedStationIDs() (map[int]int, error)
 
-	// GetSubscribedChats returns map of chats subscribed to specified station
-	GetSubscribedChats(stationID int) ([]*chatEntity, error)
+	// HoldUpdate stores an update that should be delivered after chat's quiet hours
+	// Only the latest update is kept for each station
+	HoldUpdate(chatID int64, stationID int, stationName, text string) error
+
+	// GetHeldUpdates returns all held updates of specified chat
+	GetHeldUpdates(chatID int64) ([]*heldUpdateEntity, error)
+
+	// DeleteHeldUpdates removes specified held updates
+	// Updates which have been replaced since they were fetched are kept
+	DeleteHeldUpdates(updates []*heldUpdateEntity) error
+
+	// GetChatsWithHeldUpdates returns all chats that have held updates
+	GetChatsWithHeldUpdates() ([]*chatEntity, error)
 
 	// Close shuts down DB
 	Close()
@@ -92,15 +264,41 @@ func NewDB(filepath string, logger *log.Logger) (DB, error) {
 		return nil, err
 	}
 
-	err = db.AutoMigrate(&chatEntity{})
+	err = db.AutoMigrate(&chatEntity{}, &subscriptionEntity{}, &heldUpdateEntity{})
 	if err != nil {
 		logger.Printf("unable to migrate database \"%s\": %v", filepath, err)
 		return nil, err
 	}
 
+	err = migrateLegacySubscriptions(db)
+	if err != nil {
+		logger.Printf("unable to migrate subscriptions in database \"%s\": %v", filepath, err)
+		return nil, err
+	}
+
 	return &database{context: db}, nil
 }
 
+// migrateLegacySubscriptions moves subscriptions from "chats.station_id" column into "subscriptions" table
+func migrateLegacySubscriptions(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&chatEntity{}, "station_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(
+			"INSERT OR IGNORE INTO subscriptions (chat_id, station_id, station_name, created)\n"+
+				"SELECT id, station_id, '', updated FROM chats\n"+
+				"WHERE state = ? AND station_id <> 0",
+			legacyStateSubscribed).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec("UPDATE chats SET station_id = 0 WHERE station_id <> 0").Error
+	})
+}
+
 // GetOrCreate fetches a chat state from DB
 // If chat is not registered yet, it will be created
 func (db *database) GetOrCreate(chatID int64, userID int, username string) (*chatEntity, error) {
@@ -112,12 +310,10 @@ func (db *database) GetOrCreate(chatID int64, userID int, username string) (*cha
 		}
 
 		e = chatEntity{
-			ChatID:                chatID,
-			UserID:                userID,
-			UserName:              username,
-			State:                 StateNotSubscribed,
-			SubscribedToStationID: 0,
-			Updated:               time.Now().UTC(),
+			ChatID:   chatID,
+			UserID:   userID,
+			UserName: username,
+			Updated:  time.Now().UTC(),
 		}
 		result = db.context.Create(&e)
 		if result.Error != nil {
@@ -128,32 +324,213 @@ func (db *database) GetOrCreate(chatID int64, userID int, username string) (*cha
 	return &e, nil
 }
 
+// GetChat fetches a chat state from DB
+// Returns nil if chat is not registered
+func (db *database) GetChat(chatID int64) (*chatEntity, error) {
+	var e chatEntity
+	result := db.context.Where("id = ?", chatID).First(&e)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, result.Error
+	}
+
+	return &e, nil
+}
+
 // Update stores chat state into DB
 func (db *database) Update(chat *chatEntity) error {
 	chat.Updated = time.Now().UTC()
 	upd := map[string]interface{}{
 		"user_name":  chat.UserName,
-		"state":      chat.State,
-		"station_id": chat.SubscribedToStationID,
+		"time_zone":  chat.TimeZone,
+		"quiet_from": chat.QuietHoursFrom,
+		"quiet_to":   chat.QuietHoursTo,
+		"scale":      chat.Scale,
+		"profile":    chat.Profile,
+		"input":      chat.Input,
 		"updated":    chat.Updated,
 	}
 	result := db.context.Model(chat).Updates(upd)
 	return result.Error
 }
 
+// Subscribe subscribes a chat to a station
+// Returns false if chat has been already subscribed to this station
+func (db *database) Subscribe(chatID int64, stationID int, stationName string) (bool, error) {
+	e := subscriptionEntity{
+		ChatID:      chatID,
+		StationID:   stationID,
+		StationName: stationName,
+		Created:     time.Now().UTC(),
+	}
+	result := db.context.Clauses(clause.OnConflict{DoNothing: true}).Create(&e)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		// Keep station name up to date
+		result = db.context.Model(&subscriptionEntity{}).
+			Where("chat_id = ? AND station_id = ?", chatID, stationID).
+			Update("station_name", stationName)
+		return false, result.Error
+	}
+
+	return true, nil
+}
+
+// Unsubscribe unsubscribes a chat from a station
+// Returns false if chat has not been subscribed to this station
+func (db *database) Unsubscribe(chatID int64, stationID int) (bool, error) {
+	result := db.context.
+		Where("chat_id = ? AND station_id = ?", chatID, stationID).
+		Delete(&subscriptionEntity{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// GetSubscription returns a subscription of a chat to a station
+// Returns nil if chat is not subscribed to this station
+func (db *database) GetSubscription(chatID int64, stationID int) (*subscriptionEntity, error) {
+	var e subscriptionEntity
+	result := db.context.Where("chat_id = ? AND station_id = ?", chatID, stationID).First(&e)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, result.Error
+	}
+
+	return &e, nil
+}
+
+// UpdateRule stores subscription alert rule and its state into DB
+func (db *database) UpdateRule(subscription *subscriptionEntity) error {
+	return db.updateSubscription(subscription, map[string]interface{}{
+		"rule":       subscription.Rule,
+		"rule_state": subscription.RuleState,
+	})
+}
+
+// UpdateRuleState stores state of subscription alert rule into DB
+func (db *database) UpdateRuleState(subscription *subscriptionEntity) error {
+	return db.updateSubscription(subscription, map[string]interface{}{
+		"rule_state": subscription.RuleState,
+	})
+}
+
+// UpdateDigest stores subscription mode and daily digest time into DB
+func (db *database) UpdateDigest(subscription *subscriptionEntity) error {
+	return db.updateSubscription(subscription, map[string]interface{}{
+		"mode":        subscription.Mode,
+		"digest_time": subscription.DigestTime,
+	})
+}
+
+// UpdateForecastAlert stores subscription evening forecast alert setting into DB
+func (db *database) UpdateForecastAlert(subscription *subscriptionEntity) error {
+	return db.updateSubscription(subscription, map[string]interface{}{
+		"forecast_alert": subscription.ForecastAlert,
+	})
+}
+
+// SetOffline stores station availability known to subscriber into DB
+// Returns false if it has been already stored (e.g. by a concurrent notification)
+func (db *database) SetOffline(subscription *subscriptionEntity) (bool, error) {
+	result := db.context.Model(&subscriptionEntity{}).
+		Where("chat_id = ? AND station_id = ? AND offline <> ?", subscription.ChatID, subscription.StationID, subscription.Offline).
+		Update("offline", subscription.Offline)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// updateSubscription stores specified columns of a subscription into DB
+// Subscriptions are updated both by users and in background, so each caller stores only the columns it owns
+func (db *database) updateSubscription(subscription *subscriptionEntity, upd map[string]interface{}) error {
+	result := db.context.Model(&subscriptionEntity{}).
+		Where("chat_id = ? AND station_id = ?", subscription.ChatID, subscription.StationID).
+		Updates(upd)
+	return result.Error
+}
+
+// GetSubscriptions returns all subscriptions of specified chat
+func (db *database) GetSubscriptions(chatID int64) ([]*subscriptionEntity, error) {
+	var entities []*subscriptionEntity
+	err := db.context.
+		Where("chat_id = ?", chatID).
+		Order("created, station_id").
+		Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// GetStationSubscriptions returns all subscriptions to specified station
+func (db *database) GetStationSubscriptions(stationID int) ([]*subscriptionEntity, error) {
+	var entities []*subscriptionEntity
+	err := db.context.
+		Where("station_id = ?", stationID).
+		Order("chat_id").
+		Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// GetDigestSubscriptions returns all subscriptions in daily digest mode
+func (db *database) GetDigestSubscriptions() ([]*subscriptionEntity, error) {
+	var entities []*subscriptionEntity
+	err := db.context.
+		Where("mode = ?", subscriptionModeDigest).
+		Order("chat_id, station_id").
+		Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// GetForecastAlertSubscriptions returns all subscriptions with evening forecast alerts turned on
+func (db *database) GetForecastAlertSubscriptions() ([]*subscriptionEntity, error) {
+	var entities []*subscriptionEntity
+	err := db.context.
+		Where("forecast_alert = ?", true).
+		Order("chat_id, station_id").
+		Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
 // GetSubscribedStationIDs returns map of stations with subscription
 // Map key is station ID and value is count of active subscriptions
 func (db *database) GetSubscribedStationIDs() (map[int]int, error) {
 	sqlQuery :=
-		"SELECT station_id, count(*) as count FROM chats\n" +
-			"WHERE state = ?\n" +
+		"SELECT station_id, count(*) as count FROM subscriptions\n" +
 			"GROUP BY station_id\n" +
 			"ORDER BY station_id"
 	var entities []struct {
 		StationID int `gorm:"column:station_id"`
 		Count     int `gorm:"column:count"`
 	}
-	err := db.context.Raw(sqlQuery, StateSubscribed).Scan(&entities).Error
+	err := db.context.Raw(sqlQuery).Scan(&entities).Error
 	if err != nil {
 		return nil, err
 	}
@@ -166,11 +543,78 @@ func (db *database) GetSubscribedStationIDs() (map[int]int, error) {
 	return m, nil
 }
 
-// GetSubscribedChats returns map of chats subscribed to specified station
-func (db *database) GetSubscribedChats(stationID int) ([]*chatEntity, error) {
+// HoldUpdate stores an update that should be delivered after chat's quiet hours
+// Only the latest update is kept for each station
+func (db *database) HoldUpdate(chatID int64, stationID int, stationName, text string) error {
+	now := time.Now().UTC()
+	return db.context.Transaction(func(tx *gorm.DB) error {
+		var e heldUpdateEntity
+		result := tx.Where("chat_id = ? AND station_id = ?", chatID, stationID).First(&e)
+		if result.Error != nil {
+			if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return result.Error
+			}
+
+			e = heldUpdateEntity{
+				ChatID:      chatID,
+				StationID:   stationID,
+				StationName: stationName,
+				Text:        text,
+				Created:     now,
+				Updated:     now,
+			}
+			return tx.Create(&e).Error
+		}
+
+		// Held updates are delivered along with current station status, so older ones are of no use
+		upd := map[string]interface{}{
+			"station_name": stationName,
+			"text":         text,
+			"updated":      now,
+		}
+		return tx.Model(&heldUpdateEntity{}).
+			Where("chat_id = ? AND station_id = ?", chatID, stationID).
+			Updates(upd).Error
+	})
+}
+
+// GetHeldUpdates returns all held updates of specified chat
+func (db *database) GetHeldUpdates(chatID int64) ([]*heldUpdateEntity, error) {
+	var entities []*heldUpdateEntity
+	err := db.context.
+		Where("chat_id = ?", chatID).
+		Order("created, station_id").
+		Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// DeleteHeldUpdates removes specified held updates
+// Updates which have been replaced since they were fetched are kept
+func (db *database) DeleteHeldUpdates(updates []*heldUpdateEntity) error {
+	return db.context.Transaction(func(tx *gorm.DB) error {
+		for _, e := range updates {
+			err := tx.
+				Where("chat_id = ? AND station_id = ? AND updated = ?", e.ChatID, e.StationID, e.Updated).
+				Delete(&heldUpdateEntity{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetChatsWithHeldUpdates returns all chats that have held updates
+func (db *database) GetChatsWithHeldUpdates() ([]*chatEntity, error) {
 	var entities []*chatEntity
 	err := db.context.Model(&chatEntity{}).
-		Where("state = ? AND station_id = ?", StateSubscribed, stationID).
+		Where("id IN (SELECT chat_id FROM held_updates)").
+		Order("id").
 		Scan(&entities).Error
 	if err != nil {
 		return nil, err
@@ -5,10 +5,14 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 
 	"github.com/kapitanov/tg-waqi-bot/pkg/bot"
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
 )
 
 func TestGetOrCreate(t *testing.T) {
@@ -35,8 +39,6 @@ func TestGetOrCreate(t *testing.T) {
 	a.Equal(chatID, e1.ChatID)
 	a.Equal(userID, e1.UserID)
 	a.Equal(username, e1.UserName)
-	a.Equal(bot.StateNotSubscribed, e1.State)
-	a.Equal(0, e1.SubscribedToStationID)
 
 	// At subsequent calls an entity should be fetched
 	e2, err := db.GetOrCreate(chatID, userID, username)
@@ -44,8 +46,6 @@ func TestGetOrCreate(t *testing.T) {
 	a.Equal(e1.ChatID, e2.ChatID)
 	a.Equal(e1.UserID, e2.UserID)
 	a.Equal(e1.UserName, e2.UserName)
-	a.Equal(e1.State, e2.State)
-	a.Equal(e1.SubscribedToStationID, e2.SubscribedToStationID)
 	a.Equal(e1.Updated, e2.Updated)
 }
 
@@ -73,11 +73,9 @@ func TestUpdate(t *testing.T) {
 	a.Equal(chatID, e1.ChatID)
 	a.Equal(userID, e1.UserID)
 	a.Equal(username, e1.UserName)
-	a.Equal(bot.StateNotSubscribed, e1.State)
-	a.Equal(0, e1.SubscribedToStationID)
 
 	// Then an existing entity is updated
-	e1.SetStateSubscribed(123)
+	e1.UserName = "new_username"
 	err = db.Update(e1)
 	a.Nil(err)
 
@@ -87,11 +85,353 @@ func TestUpdate(t *testing.T) {
 	a.Equal(e1.ChatID, e2.ChatID)
 	a.Equal(e1.UserID, e2.UserID)
 	a.Equal(e1.UserName, e2.UserName)
-	a.Equal(e1.State, e2.State)
-	a.Equal(e1.SubscribedToStationID, e2.SubscribedToStationID)
 	a.Equal(e1.Updated, e2.Updated)
 }
 
+func TestUpdateSettings(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+
+	filepath := path.Join(dir, "temp.dat")
+	defer func() {
+		_ = os.Remove(filepath)
+	}()
+
+	db, err := bot.NewDB(filepath, log.Default())
+	a.Nil(err)
+	defer db.Close()
+
+	// New chat should have no quiet hours, UTC time zone, US EPA scale and general health profile
+	e1, err := db.GetOrCreate(1234, 465, "username")
+	a.Nil(err)
+	a.False(e1.HasQuietHours())
+	a.Equal(time.UTC, e1.Location())
+	a.Equal(waqi.USEPAStandard, e1.Standard())
+	a.Equal(waqi.GeneralAudience, e1.Audience())
+
+	// Settings should be persisted
+	e1.TimeZone = "Europe/Berlin"
+	e1.QuietHoursFrom = 22 * 60
+	e1.QuietHoursTo = 7 * 60
+	e1.Scale = string(waqi.EUCAQIStandard)
+	e1.Profile = string(waqi.AsthmaAudience)
+	err = db.Update(e1)
+	a.Nil(err)
+
+	e2, err := db.GetChat(1234)
+	a.Nil(err)
+	a.Equal("Europe/Berlin", e2.TimeZone)
+	a.Equal("Europe/Berlin", e2.Location().String())
+	a.True(e2.HasQuietHours())
+	a.Equal(waqi.EUCAQIStandard, e2.Standard())
+	a.Equal(waqi.AsthmaAudience, e2.Audience())
+
+	// Quiet hours should be checked in chat's time zone
+	a.True(e2.IsQuietTime(time.Date(2021, 1, 10, 21, 30, 0, 0, time.UTC)))
+	a.True(e2.IsQuietTime(time.Date(2021, 1, 11, 5, 59, 0, 0, time.UTC)))
+	a.False(e2.IsQuietTime(time.Date(2021, 1, 11, 6, 0, 0, 0, time.UTC)))
+	a.False(e2.IsQuietTime(time.Date(2021, 1, 10, 20, 59, 0, 0, time.UTC)))
+
+	// Unknown chats should not be returned
+	e3, err := db.GetChat(1235)
+	a.Nil(err)
+	a.Nil(e3)
+}
+
+func TestHoldUpdate(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+
+	filepath := path.Join(dir, "temp.dat")
+	defer func() {
+		_ = os.Remove(filepath)
+	}()
+
+	db, err := bot.NewDB(filepath, log.Default())
+	a.Nil(err)
+	defer db.Close()
+
+	_, err = db.GetOrCreate(1234, 465, "username1")
+	a.Nil(err)
+	_, err = db.GetOrCreate(1235, 466, "username2")
+	a.Nil(err)
+
+	// Only the latest update for a station should be kept
+	err = db.HoldUpdate(1234, 123, "Home", "first")
+	a.Nil(err)
+	err = db.HoldUpdate(1234, 123, "Home", "second")
+	a.Nil(err)
+	err = db.HoldUpdate(1234, 124, "Office", "third")
+	a.Nil(err)
+
+	chats, err := db.GetChatsWithHeldUpdates()
+	a.Nil(err)
+	a.Len(chats, 1)
+	a.Equal(int64(1234), chats[0].ChatID)
+
+	updates, err := db.GetHeldUpdates(1234)
+	a.Nil(err)
+	a.Len(updates, 2)
+	a.Equal(123, updates[0].StationID)
+	a.Equal("second", updates[0].Text)
+	a.Equal(124, updates[1].StationID)
+	a.Equal("third", updates[1].Text)
+
+	// Updates held after delivered ones have been fetched should be kept
+	err = db.HoldUpdate(1234, 124, "Office", "fourth")
+	a.Nil(err)
+	err = db.HoldUpdate(1234, 125, "School", "fifth")
+	a.Nil(err)
+
+	err = db.DeleteHeldUpdates(updates)
+	a.Nil(err)
+
+	updates, err = db.GetHeldUpdates(1234)
+	a.Nil(err)
+	a.Len(updates, 2)
+	a.Equal("fourth", updates[0].Text)
+	a.Equal("fifth", updates[1].Text)
+
+	// Delivered updates should be removed
+	err = db.DeleteHeldUpdates(updates)
+	a.Nil(err)
+
+	updates, err = db.GetHeldUpdates(1234)
+	a.Nil(err)
+	a.Len(updates, 0)
+
+	chats, err = db.GetChatsWithHeldUpdates()
+	a.Nil(err)
+	a.Len(chats, 0)
+}
+
+func TestSubscribe(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+
+	filepath := path.Join(dir, "temp.dat")
+	defer func() {
+		_ = os.Remove(filepath)
+	}()
+
+	db, err := bot.NewDB(filepath, log.Default())
+	a.Nil(err)
+	defer db.Close()
+
+	e, err := db.GetOrCreate(1234, 465, "username")
+	a.Nil(err)
+
+	// A chat may be subscribed to multiple stations
+	created, err := db.Subscribe(e.ChatID, 123, "Home")
+	a.Nil(err)
+	a.True(created)
+	created, err = db.Subscribe(e.ChatID, 124, "Office")
+	a.Nil(err)
+	a.True(created)
+	created, err = db.Subscribe(e.ChatID, 125, "School")
+	a.Nil(err)
+	a.True(created)
+
+	// Subscribing twice to the same station should not create a duplicate
+	created, err = db.Subscribe(e.ChatID, 124, "Office")
+	a.Nil(err)
+	a.False(created)
+
+	subscriptions, err := db.GetSubscriptions(e.ChatID)
+	a.Nil(err)
+	a.Len(subscriptions, 3)
+	a.Equal(123, subscriptions[0].StationID)
+	a.Equal("Home", subscriptions[0].StationName)
+	a.Equal(124, subscriptions[1].StationID)
+	a.Equal("Office", subscriptions[1].StationName)
+	a.Equal(125, subscriptions[2].StationID)
+	a.Equal("School", subscriptions[2].StationName)
+
+	subscription, err := db.GetSubscription(e.ChatID, 124)
+	a.Nil(err)
+	a.NotNil(subscription)
+	a.Equal("Office", subscription.StationName)
+
+	// Unsubscribing from one station should keep other subscriptions
+	deleted, err := db.Unsubscribe(e.ChatID, 124)
+	a.Nil(err)
+	a.True(deleted)
+	deleted, err = db.Unsubscribe(e.ChatID, 124)
+	a.Nil(err)
+	a.False(deleted)
+
+	subscription, err = db.GetSubscription(e.ChatID, 124)
+	a.Nil(err)
+	a.Nil(subscription)
+
+	subscriptions, err = db.GetSubscriptions(e.ChatID)
+	a.Nil(err)
+	a.Len(subscriptions, 2)
+	a.Equal(123, subscriptions[0].StationID)
+	a.Equal(125, subscriptions[1].StationID)
+}
+
+func TestUpdateSubscription(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+
+	filepath := path.Join(dir, "temp.dat")
+	defer func() {
+		_ = os.Remove(filepath)
+	}()
+
+	db, err := bot.NewDB(filepath, log.Default())
+	a.Nil(err)
+	defer db.Close()
+
+	_, err = db.Subscribe(1234, 123, "Home")
+	a.Nil(err)
+	_, err = db.Subscribe(1235, 123, "Home")
+	a.Nil(err)
+
+	// New subscription should have a default alert rule
+	s1, err := db.GetSubscription(1234, 123)
+	a.Nil(err)
+	a.Equal("", s1.Rule)
+	a.Equal("level", s1.AlertRule().String())
+
+	// Alert rule and its state should be persisted
+	s1.Rule = "aqi<>100"
+	s1.RuleState = "above"
+	err = db.UpdateRule(s1)
+	a.Nil(err)
+
+	// Station availability should be stored once
+	s1.Offline = true
+	changed, err := db.SetOffline(s1)
+	a.Nil(err)
+	a.True(changed)
+	changed, err = db.SetOffline(s1)
+	a.Nil(err)
+	a.False(changed)
+
+	s2, err := db.GetSubscription(1234, 123)
+	a.Nil(err)
+	a.Equal("aqi<>100", s2.Rule)
+	a.Equal("above", s2.RuleState)
+	a.True(s2.Offline)
+
+	// Rule state updates should not revert settings changed in the meantime
+	s2.Mode = "digest"
+	err = db.UpdateDigest(s2)
+	a.Nil(err)
+	s1.Rule = "level"
+	s1.RuleState = "below"
+	err = db.UpdateRuleState(s1)
+	a.Nil(err)
+
+	s3, err := db.GetSubscription(1234, 123)
+	a.Nil(err)
+	a.Equal("aqi<>100", s3.Rule)
+	a.Equal("below", s3.RuleState)
+	a.True(s3.IsDigest())
+
+	// Other subscriptions to the same station should not be affected
+	subscriptions, err := db.GetStationSubscriptions(123)
+	a.Nil(err)
+	a.Len(subscriptions, 2)
+	a.Equal(int64(1234), subscriptions[0].ChatID)
+	a.Equal("aqi<>100", subscriptions[0].Rule)
+	a.Equal(int64(1235), subscriptions[1].ChatID)
+	a.Equal("", subscriptions[1].Rule)
+}
+
+func TestGetDigestSubscriptions(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+
+	filepath := path.Join(dir, "temp.dat")
+	defer func() {
+		_ = os.Remove(filepath)
+	}()
+
+	db, err := bot.NewDB(filepath, log.Default())
+	a.Nil(err)
+	defer db.Close()
+
+	_, err = db.Subscribe(1234, 123, "Home")
+	a.Nil(err)
+	_, err = db.Subscribe(1234, 124, "Work")
+	a.Nil(err)
+	_, err = db.Subscribe(1235, 123, "Home")
+	a.Nil(err)
+
+	// New subscriptions should not be in digest mode
+	subscriptions, err := db.GetDigestSubscriptions()
+	a.Nil(err)
+	a.Len(subscriptions, 0)
+
+	s, err := db.GetSubscription(1234, 124)
+	a.Nil(err)
+	a.False(s.IsDigest())
+	a.Equal("1234/124", s.DigestKey())
+
+	// Digest settings should be persisted
+	s.Mode = "digest"
+	s.DigestTime = 8 * 60
+	err = db.UpdateDigest(s)
+	a.Nil(err)
+
+	subscriptions, err = db.GetDigestSubscriptions()
+	a.Nil(err)
+	a.Len(subscriptions, 1)
+	a.Equal(int64(1234), subscriptions[0].ChatID)
+	a.Equal(124, subscriptions[0].StationID)
+	a.Equal(8*60, subscriptions[0].DigestTime)
+	a.True(subscriptions[0].IsDigest())
+}
+
+func TestGetForecastAlertSubscriptions(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+
+	filepath := path.Join(dir, "temp.dat")
+	defer func() {
+		_ = os.Remove(filepath)
+	}()
+
+	db, err := bot.NewDB(filepath, log.Default())
+	a.Nil(err)
+	defer db.Close()
+
+	_, err = db.Subscribe(1234, 123, "Home")
+	a.Nil(err)
+	_, err = db.Subscribe(1234, 124, "Work")
+	a.Nil(err)
+
+	// Forecast alerts should be turned off by default
+	subscriptions, err := db.GetForecastAlertSubscriptions()
+	a.Nil(err)
+	a.Len(subscriptions, 0)
+
+	s, err := db.GetSubscription(1234, 123)
+	a.Nil(err)
+	a.False(s.ForecastAlert)
+	a.Equal("forecast/1234/123", s.ForecastAlertKey())
+
+	// Forecast alert setting should be persisted
+	s.ForecastAlert = true
+	err = db.UpdateForecastAlert(s)
+	a.Nil(err)
+
+	subscriptions, err = db.GetForecastAlertSubscriptions()
+	a.Nil(err)
+	a.Len(subscriptions, 1)
+	a.Equal(123, subscriptions[0].StationID)
+	a.True(subscriptions[0].ForecastAlert)
+}
+
 func TestGetSubscribedStationIDs(t *testing.T) {
 	a := assert.New(t)
 	dir, err := os.MkdirTemp(os.TempDir(), "*")
@@ -112,14 +452,13 @@ func TestGetSubscribedStationIDs(t *testing.T) {
 	username1 := "username1"
 	e1, err := db.GetOrCreate(chatID1, userID1, username1)
 	a.Nil(err)
-	e1.SetStateSubscribed(123)
-	err = db.Update(e1)
+	_, err = db.Subscribe(e1.ChatID, 123, "")
 	a.Nil(err)
 
 	ids, err := db.GetSubscribedStationIDs()
 	a.Nil(err)
 	a.Len(ids, 1)
-	a.Equal(1, ids[e1.SubscribedToStationID])
+	a.Equal(1, ids[123])
 
 	// Insert 2nd entity
 	chatID2 := int64(1235)
@@ -127,28 +466,28 @@ func TestGetSubscribedStationIDs(t *testing.T) {
 	username2 := "username2"
 	e2, err := db.GetOrCreate(chatID2, userID2, username2)
 	a.Nil(err)
-	e2.SetStateSubscribed(124)
-	err = db.Update(e2)
+	_, err = db.Subscribe(e2.ChatID, 123, "")
+	a.Nil(err)
+	_, err = db.Subscribe(e2.ChatID, 124, "")
 	a.Nil(err)
 
 	ids, err = db.GetSubscribedStationIDs()
 	a.Nil(err)
 	a.Len(ids, 2)
-	a.Equal(1, ids[e1.SubscribedToStationID])
-	a.Equal(1, ids[e2.SubscribedToStationID])
+	a.Equal(2, ids[123])
+	a.Equal(1, ids[124])
 
 	// Update 2nd entity
-	e2.SetStateNotSubscribed()
-	err = db.Update(e2)
+	_, err = db.Unsubscribe(e2.ChatID, 124)
 	a.Nil(err)
 
 	ids, err = db.GetSubscribedStationIDs()
 	a.Nil(err)
 	a.Len(ids, 1)
-	a.Equal(1, ids[e1.SubscribedToStationID])
+	a.Equal(2, ids[123])
 }
 
-func TestGetSubscribedChats(t *testing.T) {
+func TestGetStationSubscriptions(t *testing.T) {
 	a := assert.New(t)
 	dir, err := os.MkdirTemp(os.TempDir(), "*")
 	a.Nil(err)
@@ -168,14 +507,13 @@ func TestGetSubscribedChats(t *testing.T) {
 	username1 := "username1"
 	e1, err := db.GetOrCreate(chatID1, userID1, username1)
 	a.Nil(err)
-	e1.SetStateSubscribed(123)
-	err = db.Update(e1)
+	_, err = db.Subscribe(e1.ChatID, 123, "")
 	a.Nil(err)
 
-	chats, err := db.GetSubscribedChats(e1.SubscribedToStationID)
+	subscriptions, err := db.GetStationSubscriptions(123)
 	a.Nil(err)
-	a.Len(chats, 1)
-	a.Equal(e1.ChatID, chats[0].ChatID)
+	a.Len(subscriptions, 1)
+	a.Equal(e1.ChatID, subscriptions[0].ChatID)
 
 	// Insert 2nd entity
 	chatID2 := int64(1235)
@@ -183,33 +521,76 @@ func TestGetSubscribedChats(t *testing.T) {
 	username2 := "username2"
 	e2, err := db.GetOrCreate(chatID2, userID2, username2)
 	a.Nil(err)
-	e2.SetStateSubscribed(123)
-	err = db.Update(e2)
+	_, err = db.Subscribe(e2.ChatID, 123, "")
 	a.Nil(err)
-	e1.SetStateSubscribed(123)
-	err = db.Update(e1)
+	_, err = db.Subscribe(e1.ChatID, 124, "")
 	a.Nil(err)
 
-	chats, err = db.GetSubscribedChats(123)
+	subscriptions, err = db.GetStationSubscriptions(123)
 	a.Nil(err)
-	a.Len(chats, 2)
-	a.Equal(e1.ChatID, chats[0].ChatID)
-	a.Equal(e2.ChatID, chats[1].ChatID)
+	a.Len(subscriptions, 2)
+	a.Equal(e1.ChatID, subscriptions[0].ChatID)
+	a.Equal(e2.ChatID, subscriptions[1].ChatID)
 
-	// Update 2nd entity
-	e2.SetStateNotSubscribed()
-	err = db.Update(e2)
+	subscriptions, err = db.GetStationSubscriptions(124)
 	a.Nil(err)
-	e1.SetStateSubscribed(124)
-	err = db.Update(e1)
+	a.Len(subscriptions, 1)
+	a.Equal(e1.ChatID, subscriptions[0].ChatID)
+
+	// Update both entities
+	_, err = db.Unsubscribe(e1.ChatID, 123)
+	a.Nil(err)
+	_, err = db.Unsubscribe(e2.ChatID, 123)
 	a.Nil(err)
 
-	chats, err = db.GetSubscribedChats(123)
+	subscriptions, err = db.GetStationSubscriptions(123)
 	a.Nil(err)
-	a.Len(chats, 0)
+	a.Len(subscriptions, 0)
 
-	chats, err = db.GetSubscribedChats(124)
+	subscriptions, err = db.GetStationSubscriptions(124)
 	a.Nil(err)
-	a.Len(chats, 1)
-	a.Equal(e1.ChatID, chats[0].ChatID)
+	a.Len(subscriptions, 1)
+	a.Equal(e1.ChatID, subscriptions[0].ChatID)
+}
+
+func TestMigrateLegacySubscriptions(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+
+	filepath := path.Join(dir, "temp.dat")
+	defer func() {
+		_ = os.Remove(filepath)
+	}()
+
+	// Create a DB with legacy schema
+	legacyDB, err := gorm.Open(sqlite.Open(filepath), &gorm.Config{})
+	a.Nil(err)
+	err = legacyDB.Exec(
+		"CREATE TABLE chats (id integer PRIMARY KEY, user_id integer, user_name text, " +
+			"state text, station_id integer, updated datetime)").Error
+	a.Nil(err)
+	err = legacyDB.Exec(
+		"INSERT INTO chats (id, user_id, user_name, state, station_id, updated) VALUES " +
+			"(1234, 465, 'username1', 'subscribed', 123, CURRENT_TIMESTAMP), " +
+			"(1235, 466, 'username2', 'not_subscribed', 0, CURRENT_TIMESTAMP)").Error
+	a.Nil(err)
+	sqlDB, err := legacyDB.DB()
+	a.Nil(err)
+	a.Nil(sqlDB.Close())
+
+	// Legacy subscriptions should be moved into a new table
+	db, err := bot.NewDB(filepath, log.Default())
+	a.Nil(err)
+	defer db.Close()
+
+	ids, err := db.GetSubscribedStationIDs()
+	a.Nil(err)
+	a.Len(ids, 1)
+	a.Equal(1, ids[123])
+
+	subscriptions, err := db.GetStationSubscriptions(123)
+	a.Nil(err)
+	a.Len(subscriptions, 1)
+	a.Equal(int64(1234), subscriptions[0].ChatID)
 }
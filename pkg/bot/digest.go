@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+const (
+	// inputDigestTime is followed by a station ID, e.g. "digest_time:1234"
+	inputDigestTime = "digest_time"
+
+	digestOff = "off"
+)
+
+// digestTimePresets contains daily digest times that can be chosen by users
+var digestTimePresets = []string{
+	"07:00",
+	"08:00",
+	"09:00",
+	"18:00",
+	"20:00",
+	"21:00",
+}
+
+var digestTimeRegexp = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?$`)
+
+// onCallbackDigest handles "digest" callbacks
+func (s *botService) onCallbackDigest(ctx context.Context, c *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	status, err := s.WAQI.GetByStation(ctx, d.StationID)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.DB.GetSubscription(chat.ChatID, d.StationID)
+	if err != nil {
+		return err
+	}
+
+	// Subscription might have been removed in the meantime
+	if subscription == nil {
+		return s.Screens.LocationScreen(chat, status, c.Message)
+	}
+
+	chat.Input = fmt.Sprintf("%s:%d", inputDigestTime, d.StationID)
+	err = s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.DigestSettingsScreen(chat, status, subscription, c.Message)
+}
+
+// onCallbackSetDigest handles "set_digest" callbacks
+func (s *botService) onCallbackSetDigest(ctx context.Context, c *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	return s.setDigestTime(ctx, chat, d.StationID, d.Arg, c.Message)
+}
+
+// onDigestTimeInput handles daily digest time sent as a text message
+func (s *botService) onDigestTimeInput(ctx context.Context, chat *chatEntity, text string) error {
+	stationID, err := strconv.Atoi(strings.TrimPrefix(chat.Input, inputDigestTime+":"))
+	if err != nil {
+		return err
+	}
+
+	return s.setDigestTime(ctx, chat, stationID, text, nil)
+}
+
+// setDigestTime parses and stores daily digest time of a subscription
+// Digest is turned off if "off" is specified
+func (s *botService) setDigestTime(ctx context.Context, chat *chatEntity, stationID int, str string, message telebot.Editable) error {
+	str = strings.TrimSpace(strings.ToLower(str))
+
+	timeOfDay := 0
+	mode := subscriptionModeDigest
+	if str == digestOff {
+		mode = subscriptionModeAlerts
+	} else {
+		match := digestTimeRegexp.FindStringSubmatch(str)
+		if match == nil {
+			return s.Screens.InvalidInputScreen(chat,
+				"Malformed time. Send me a time of day as <code>HH:MM</code> (e.g. <code>08:30</code>).")
+		}
+
+		var err error
+		timeOfDay, err = parseTimeOfDay(match[1], match[2])
+		if err != nil {
+			return s.Screens.InvalidInputScreen(chat,
+				"Malformed time. Send me a time of day as <code>HH:MM</code> (e.g. <code>08:30</code>).")
+		}
+	}
+
+	chat.Input = ""
+	err := s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	status, err := s.WAQI.GetByStation(ctx, stationID)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.DB.GetSubscription(chat.ChatID, stationID)
+	if err != nil {
+		return err
+	}
+
+	// Subscription might have been removed in the meantime
+	if subscription == nil {
+		return s.Screens.LocationScreen(chat, status, message)
+	}
+
+	subscription.Mode = mode
+	subscription.DigestTime = timeOfDay
+	err = s.DB.UpdateDigest(subscription)
+	if err != nil {
+		return err
+	}
+
+	s.scheduleDigest(chat, subscription)
+	return s.Screens.SubscribedScreen(chat, status, subscription, message)
+}
+
+// restoreDigests restores daily digest schedules from DB
+func (s *botService) restoreDigests() error {
+	subscriptions, err := s.DB.GetDigestSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		chat, err := s.DB.GetChat(subscription.ChatID)
+		if err != nil {
+			return err
+		}
+		if chat == nil {
+			continue
+		}
+
+		s.scheduleDigest(chat, subscription)
+	}
+
+	s.Logger.Printf("got %d daily digests", len(subscriptions))
+	return nil
+}
+
+// rescheduleDigests updates daily digest and forecast alert schedules of a chat (e.g. when its time zone changes)
+func (s *botService) rescheduleDigests(chat *chatEntity) error {
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		s.scheduleDigest(chat, subscription)
+		s.scheduleForecastAlert(chat, subscription)
+	}
+
+	return nil
+}
+
+// scheduleDigest adds or removes a daily digest schedule of a subscription according to its mode
+func (s *botService) scheduleDigest(chat *chatEntity, subscription *subscriptionEntity) {
+	if subscription.IsDigest() {
+		s.WAQI.ScheduleDigest(subscription.DigestKey(), subscription.StationID, subscription.DigestTime, chat.Location(), s)
+	} else {
+		s.WAQI.UnscheduleDigest(subscription.DigestKey())
+	}
+}
+
+// Digest handles a scheduled daily digest
+// Evening forecast alerts are scheduled as digests too, so they are dispatched by key
+func (s *botService) Digest(key string, digest *waqi.Digest) error {
+	if strings.HasPrefix(key, forecastAlertKeyPrefix) {
+		return s.forecastAlert(key, digest.Status)
+	}
+
+	var chatID int64
+	var stationID int
+	_, err := fmt.Sscanf(key, "%d/%d", &chatID, &stationID)
+	if err != nil {
+		return fmt.Errorf("malformed digest key \"%s\": %s", key, err)
+	}
+
+	chat, err := s.DB.GetChat(chatID)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.DB.GetSubscription(chatID, stationID)
+	if err != nil {
+		return err
+	}
+
+	// Subscription might have been removed or switched to alerts in the meantime
+	if chat == nil || subscription == nil || !subscription.IsDigest() {
+		s.WAQI.UnscheduleDigest(key)
+		return nil
+	}
+
+	return s.Screens.DigestScreen(chat, digest, subscription)
+}
+
+// formatTimeOfDay converts minutes since midnight into "HH:MM" format
+func formatTimeOfDay(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+const (
+	// forecastAlertKeyPrefix is a prefix of forecast alert schedule keys, e.g. "forecast/1234/5678"
+	forecastAlertKeyPrefix = "forecast/"
+
+	// forecastAlertTime is a time of day (in minutes since midnight) when evening forecast alerts are sent
+	forecastAlertTime = 20 * 60
+
+	forecastAlertOn  = "on"
+	forecastAlertOff = "off"
+)
+
+// onForecast handles "/forecast" command
+func (s *botService) onForecast(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onForecastCore)
+}
+
+// onForecastCore handles "/forecast" command (without error handling)
+// Command accepts an optional station ID, otherwise a subscribed station is used
+func (s *botService) onForecastCore(ctx context.Context, arg interface{}, chat *chatEntity) error {
+	m := arg.(*telebot.Message)
+
+	if strings.TrimSpace(m.Payload) != "" {
+		stationID, ok := parseStationID(m.Payload)
+		if !ok {
+			return s.Screens.InvalidInputScreen(chat, "Malformed station ID. Use <code>/forecast</code> or <code>/forecast &lt;station ID&gt;</code>.")
+		}
+
+		return s.showForecast(ctx, chat, stationID, nil)
+	}
+
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) == 1 {
+		return s.showForecast(ctx, chat, subscriptions[0].StationID, nil)
+	}
+
+	return s.Screens.ForecastStationsScreen(chat, subscriptions)
+}
+
+// onCallbackForecast handles "forecast" callbacks
+func (s *botService) onCallbackForecast(ctx context.Context, _ *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	// Forecast buttons are attached to status messages, so a new message should be sent
+	return s.showForecast(ctx, chat, d.StationID, nil)
+}
+
+// onCallbackSetForecastAlert handles "set_forecast" callbacks
+func (s *botService) onCallbackSetForecastAlert(ctx context.Context, c *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	subscription, err := s.DB.GetSubscription(chat.ChatID, d.StationID)
+	if err != nil {
+		return err
+	}
+
+	// Subscription might have been removed in the meantime
+	if subscription != nil {
+		subscription.ForecastAlert = d.Arg == forecastAlertOn
+		err = s.DB.UpdateForecastAlert(subscription)
+		if err != nil {
+			return err
+		}
+
+		s.scheduleForecastAlert(chat, subscription)
+	}
+
+	return s.showForecast(ctx, chat, d.StationID, c.Message)
+}
+
+// showForecast renders and sends a daily forecast of a station
+func (s *botService) showForecast(ctx context.Context, chat *chatEntity, stationID int, message telebot.Editable) error {
+	status, err := s.WAQI.GetByStation(ctx, stationID)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.DB.GetSubscription(chat.ChatID, stationID)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.ForecastScreen(chat, status, subscription, time.Now(), message)
+}
+
+// restoreForecastAlerts restores evening forecast alert schedules from DB
+func (s *botService) restoreForecastAlerts() error {
+	subscriptions, err := s.DB.GetForecastAlertSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		chat, err := s.DB.GetChat(subscription.ChatID)
+		if err != nil {
+			return err
+		}
+		if chat == nil {
+			continue
+		}
+
+		s.scheduleForecastAlert(chat, subscription)
+	}
+
+	s.Logger.Printf("got %d forecast alerts", len(subscriptions))
+	return nil
+}
+
+// scheduleForecastAlert adds or removes an evening forecast alert schedule of a subscription
+// Forecast alerts are delivered by the daily digest scheduler
+func (s *botService) scheduleForecastAlert(chat *chatEntity, subscription *subscriptionEntity) {
+	if subscription.ForecastAlert {
+		s.WAQI.ScheduleDigest(subscription.ForecastAlertKey(), subscription.StationID, forecastAlertTime, chat.Location(), s)
+	} else {
+		s.WAQI.UnscheduleDigest(subscription.ForecastAlertKey())
+	}
+}
+
+// forecastAlert handles a scheduled evening forecast alert
+// Subscriber is notified only if tomorrow's forecast crosses subscription's alert rule
+func (s *botService) forecastAlert(key string, status *waqi.Status) error {
+	var chatID int64
+	var stationID int
+	_, err := fmt.Sscanf(strings.TrimPrefix(key, forecastAlertKeyPrefix), "%d/%d", &chatID, &stationID)
+	if err != nil {
+		return fmt.Errorf("malformed forecast alert key \"%s\": %s", key, err)
+	}
+
+	chat, err := s.DB.GetChat(chatID)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.DB.GetSubscription(chatID, stationID)
+	if err != nil {
+		return err
+	}
+
+	// Subscription might have been removed or forecast alert turned off in the meantime
+	if chat == nil || subscription == nil || !subscription.ForecastAlert {
+		s.WAQI.UnscheduleDigest(key)
+		return nil
+	}
+
+	// Forecast is evaluated in chat's AQI standard
+	status = chat.Standard().Convert(status)
+	now := time.Now()
+	tomorrow := status.Forecast.Tomorrow(now, chat.Location())
+	if !subscription.AlertRule().EvaluateForecast(status, tomorrow) {
+		return nil
+	}
+
+	// Hold alerts during quiet hours, tomorrow's forecast is still useful in the morning
+	if chat.IsQuietTime(now) {
+		text := fmt.Sprintf("<code>%s</code> %s", now.In(chat.Location()).Format("15:04"), s.Screens.generateForecastAlertText(status.Standard, tomorrow))
+		err = s.DB.HoldUpdate(chat.ChatID, status.Station.ID, status.Station.Name, text)
+		if err != nil {
+			return err
+		}
+
+		s.Logger.Printf("held forecast alert for %d until quiet hours end", chat.ChatID)
+		return nil
+	}
+
+	return s.Screens.ForecastAlertScreen(chat, status, tomorrow)
+}
@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+// historyPeriod is a time range which can be chosen for history charts
+type historyPeriod struct {
+	Name        string
+	Duration    time.Duration
+	Description string
+}
+
+// historyPeriods contains history periods that can be chosen by users
+var historyPeriods = []historyPeriod{
+	{Name: "24h", Duration: 24 * time.Hour, Description: "24 hours"},
+	{Name: "7d", Duration: 7 * 24 * time.Hour, Description: "7 days"},
+	{Name: "30d", Duration: 30 * 24 * time.Hour, Description: "30 days"},
+}
+
+// getHistoryPeriod returns a history period by its name
+// Returns a default period if name is unknown
+func getHistoryPeriod(name string) historyPeriod {
+	for _, period := range historyPeriods {
+		if period.Name == name {
+			return period
+		}
+	}
+
+	return historyPeriods[0]
+}
+
+// onHistory handles "/history" command
+func (s *botService) onHistory(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onHistoryCore)
+}
+
+// onHistoryCore handles "/history" command (without error handling)
+// Command accepts an optional station ID, otherwise a subscribed station is used
+func (s *botService) onHistoryCore(_ context.Context, arg interface{}, chat *chatEntity) error {
+	m := arg.(*telebot.Message)
+
+	if strings.TrimSpace(m.Payload) != "" {
+		stationID, ok := parseStationID(m.Payload)
+		if !ok {
+			return s.Screens.InvalidInputScreen(chat, "Malformed station ID. Use <code>/history</code> or <code>/history &lt;station ID&gt;</code>.")
+		}
+
+		return s.showHistory(chat, stationID, historyPeriods[0], nil)
+	}
+
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) == 1 {
+		return s.showHistory(chat, subscriptions[0].StationID, historyPeriods[0], nil)
+	}
+
+	return s.Screens.HistoryStationsScreen(chat, subscriptions)
+}
+
+// onCallbackHistory handles "history" callbacks
+func (s *botService) onCallbackHistory(_ context.Context, c *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	// Period buttons are attached to a chart itself, so it should be replaced
+	// Other buttons are attached to text messages, so a new chart should be sent
+	var message telebot.Editable
+	if c.Message != nil && c.Message.Photo != nil {
+		message = c.Message
+	}
+
+	return s.showHistory(chat, d.StationID, getHistoryPeriod(d.Arg), message)
+}
+
+// showHistory renders and sends a history chart of a station
+func (s *botService) showHistory(chat *chatEntity, stationID int, period historyPeriod, message telebot.Editable) error {
+	to := time.Now().UTC()
+	from := to.Add(-period.Duration)
+	statuses, err := s.WAQI.GetHistory(stationID, from, to)
+	if err == waqi.ErrHistoryDisabled {
+		return s.Screens.HistoryUnavailableScreen(chat, "Measurement history is turned off on this server.")
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(statuses) == 0 {
+		return s.Screens.HistoryUnavailableScreen(chat, "No measurements have been recorded for this station yet. Subscribe to it to start collecting history.")
+	}
+
+	// Chart is drawn in chat's AQI standard to match its caption
+	for i, status := range statuses {
+		statuses[i] = chat.Standard().Convert(status)
+	}
+
+	chart, err := renderHistoryChart("Air quality, last "+period.Description, statuses, chat.Standard(), from, to, chat.Location())
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.HistoryScreen(chat, statuses, period, chart, message)
+}
@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+func TestRenderHistoryChart(t *testing.T) {
+	a := assert.New(t)
+
+	to := time.Date(2021, 5, 2, 8, 0, 0, 0, time.UTC)
+	from := to.Add(-24 * time.Hour)
+	statuses := []*waqi.Status{
+		screenStatus(from.Add(time.Hour), 20),
+		screenStatus(from.Add(2*time.Hour), 80),
+		screenStatus(from.Add(3*time.Hour), 160),
+	}
+
+	data, err := renderHistoryChart("Air quality", statuses, waqi.USEPAStandard, from, to, time.UTC)
+	a.Nil(err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	a.Nil(err)
+	a.Equal(chartWidth, img.Bounds().Dx())
+	a.Equal(chartHeight, img.Bounds().Dy())
+
+	// Plot background should be coloured with categories of chart's standard
+	x, y := chartWidth-chartMarginRight-2, chartHeight-chartMarginBottom-2
+	a.Equal(getCategoryBackgroundColor(waqi.USEPAStandard.Category(0)), img.At(x, y))
+
+	data, err = renderHistoryChart("Air quality", statuses, waqi.EUCAQIStandard, from, to, time.UTC)
+	a.Nil(err)
+	img, err = png.Decode(bytes.NewReader(data))
+	a.Nil(err)
+	a.Equal(getCategoryBackgroundColor(waqi.EUCAQIStandard.Category(0)), img.At(x, y))
+	a.NotEqual(getCategoryBackgroundColor(waqi.USEPAStandard.Category(0)), img.At(x, y))
+}
+
+func TestGetHistoryPeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected time.Duration
+	}{
+		{"24h", 24 * time.Hour},
+		{"7d", 7 * 24 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+		{"", 24 * time.Hour},
+		{"1y", 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getHistoryPeriod(test.name).Duration)
+		})
+	}
+}
+
+func TestShowHistory(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Now().UTC()
+	service := &fakeWAQI{
+		history: []*waqi.Status{
+			screenStatus(now.Add(-2*time.Hour), 20),
+			screenStatus(now.Add(-time.Hour), 60),
+		},
+	}
+	s, telegram := newTestBotService(t, service)
+	chat := &chatEntity{ChatID: 1234}
+
+	err := s.showHistory(chat, 123, historyPeriods[0], nil)
+	a.Nil(err)
+	a.Len(telegram.Messages(), 1)
+	a.True(strings.HasPrefix(telegram.Messages()[0], "sendPhoto: "))
+	a.Contains(telegram.Messages()[0], "Air quality over last 24 hours")
+}
+
+func TestShowHistoryUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		history  []*waqi.Status
+		err      error
+		expected string
+	}{
+		{"no measurements", []*waqi.Status{}, nil, "No measurements have been recorded"},
+		{"history disabled", nil, waqi.ErrHistoryDisabled, "Measurement history is turned off"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := assert.New(t)
+			s, telegram := newTestBotService(t, &fakeWAQI{history: test.history, historyErr: test.err})
+
+			err := s.showHistory(&chatEntity{ChatID: 1234}, 123, historyPeriods[0], nil)
+			a.Nil(err)
+			a.Len(telegram.Messages(), 1)
+			a.True(strings.HasPrefix(telegram.Messages()[0], "sendMessage: "))
+			a.Contains(telegram.Messages()[0], test.expected)
+		})
+	}
+}
+
+// newTestBotService creates a bot service which talks to a fake Telegram API and uses a temporary DB
+func newTestBotService(t *testing.T, service waqi.Service) (*botService, *fakeTelegram) {
+	telegram := newFakeTelegram()
+	t.Cleanup(telegram.Close)
+
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	db, err := NewDB(path.Join(dir, "temp.dat"), log.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tgBot, err := telebot.NewBot(telebot.Settings{URL: telegram.URL(), Token: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &botService{
+		Bot:                tgBot,
+		DB:                 db,
+		WAQI:               service,
+		SubscriptionsMutex: &sync.Mutex{},
+		Subscriptions:      make(map[int]int),
+		Screens:            &botScreens{tgBot, log.Default()},
+		Logger:             log.Default(),
+		Context:            context.Background(),
+	}
+	return s, telegram
+}
+
+// fakeTelegram is a fake Telegram Bot API which records sent messages
+type fakeTelegram struct {
+	server   *httptest.Server
+	mutex    *sync.Mutex
+	messages []string
+}
+
+func newFakeTelegram() *fakeTelegram {
+	f := &fakeTelegram{mutex: &sync.Mutex{}}
+	f.server = httptest.NewServer(f)
+	return f
+}
+
+// URL returns a base URL of fake API
+func (f *fakeTelegram) URL() string {
+	return f.server.URL
+}
+
+// Close shuts down fake API
+func (f *fakeTelegram) Close() {
+	f.server.Close()
+}
+
+// Messages returns sent messages as "method: text" strings
+func (f *fakeTelegram) Messages() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.messages...)
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := path.Base(r.URL.Path)
+	if method == "getMe" {
+		_, _ = fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`)
+		return
+	}
+
+	// Media are sent as multipart forms, other messages as JSON
+	var text string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		_ = r.ParseMultipartForm(1 << 20)
+		text = r.FormValue("caption")
+	} else {
+		var payload map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		text, _ = payload["text"].(string)
+	}
+
+	f.mutex.Lock()
+	f.messages = append(f.messages, method+": "+text)
+	f.mutex.Unlock()
+
+	_, _ = fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1234},
+		"photo":[{"file_id":"photo","width":800,"height":480}]}}`)
+}
+
+// fakeWAQI is a fake WAQI service which serves predefined statuses and history
+type fakeWAQI struct {
+	waqi.Service
+	statuses   map[int]*waqi.Status
+	history    []*waqi.Status
+	historyErr error
+}
+
+func (s *fakeWAQI) GetByStation(_ context.Context, stationID int) (*waqi.Status, error) {
+	status, exists := s.statuses[stationID]
+	if !exists {
+		return nil, waqi.Error("Unknown station")
+	}
+
+	return status, nil
+}
+
+func (s *fakeWAQI) GetHistory(int, time.Time, time.Time) ([]*waqi.Status, error) {
+	return s.history, s.historyErr
+}
+
+func (s *fakeWAQI) Subscribe(int, waqi.Listener) {
+}
+
+func (s *fakeWAQI) Unsubscribe(int, waqi.Listener) {
+}
+
+func (s *fakeWAQI) IsOffline(int) bool {
+	return false
+}
+
+func (s *fakeWAQI) UnscheduleDigest(string) {
+}
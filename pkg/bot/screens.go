@@ -1,7 +1,9 @@
 package bot
 
 import (
+	"bytes"
 	"fmt"
+	"html"
 	"log"
 	"regexp"
 	"strings"
@@ -13,6 +15,46 @@ import (
 	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
 )
 
+// alertRulePresets contains alert rules that can be chosen by users
+var alertRulePresets = []string{
+	"level",
+	"aqi<>100",
+	"aqi<>150",
+	"aqi>200",
+	"pm25>35",
+	"pm10>50",
+}
+
+// standardLevelIcons contains level icons of AQI standards whose colours differ from US EPA ones
+var standardLevelIcons = map[waqi.Standard]map[waqi.Level]emoji.Emoji{
+	waqi.EUCAQIStandard: {
+		waqi.GoodLevel:              emoji.GreenSquare,
+		waqi.ModerateLevel:          emoji.GreenCircle,
+		waqi.PossiblyUnhealthyLevel: emoji.YellowSquare,
+		waqi.UnhealthyLevel:         emoji.OrangeSquare,
+		waqi.VeryUnhealthyLevel:     emoji.RedSquare,
+	},
+	waqi.IndiaNAQIStandard: {
+		waqi.GoodLevel:              emoji.GreenSquare,
+		waqi.ModerateLevel:          emoji.GreenCircle,
+		waqi.PossiblyUnhealthyLevel: emoji.YellowSquare,
+		waqi.UnhealthyLevel:         emoji.OrangeSquare,
+		waqi.VeryUnhealthyLevel:     emoji.RedSquare,
+		waqi.HazardousLevel:         emoji.BrownSquare,
+	},
+}
+
+// maxMessageLength is the longest text Telegram accepts in a single message
+// Byte length of a text is never less than its length as counted by Telegram, so it is a safe measure.
+const maxMessageLength = 4096
+
+// quietHoursPresets contains quiet hours that can be chosen by users
+var quietHoursPresets = []string{
+	"22:00-07:00",
+	"23:00-08:00",
+	"00:00-07:00",
+}
+
 type botScreens struct {
 	bot    *telebot.Bot
 	logger *log.Logger
@@ -44,7 +86,7 @@ func (s *botScreens) ErrorScreen(to telebot.Recipient) error {
 
 func (s *botScreens) WelcomeScreen(to telebot.Recipient, message telebot.Editable) error {
 	text := fmt.Sprintf(
-		"%s This Bot helps you track air quality at any location.\nSend me a location to get its current air quality index.",
+		"%s This Bot helps you track air quality at any location.\nSend me a location or a city name to get its current air quality index.\nSend /help to see all commands.",
 		emoji.Umbrella)
 
 	markup := &telebot.ReplyMarkup{
@@ -62,8 +104,9 @@ func (s *botScreens) WelcomeScreen(to telebot.Recipient, message telebot.Editabl
 	return s.sendScreen("WelcomeScreen", to, message, text, markup, telebot.ModeHTML)
 }
 
-func (s *botScreens) LocationScreen(to telebot.Recipient, status *waqi.Status, message telebot.Editable) error {
-	text := s.generateStatusScreen(status)
+func (s *botScreens) LocationScreen(chat *chatEntity, status *waqi.Status, message telebot.Editable) error {
+	status = chat.Standard().Convert(status)
+	text := s.generateStatusScreen(status, chat.Location(), s.generateHealthAdvice(status, chat.Audience()))
 
 	uid := fmt.Sprintf("%d", time.Now().UTC().Unix())
 	markup := &telebot.ReplyMarkup{
@@ -79,69 +122,905 @@ func (s *botScreens) LocationScreen(to telebot.Recipient, status *waqi.Status, m
 					Data: callbackJSON{Type: callbackTypeSubscribe, StationID: status.Station.ID, UID: uid}.String(),
 				},
 			},
+			{
+				{
+					Text: fmt.Sprintf("%s History", emoji.ChartIncreasing),
+					Data: callbackJSON{Type: callbackTypeHistory, StationID: status.Station.ID, Arg: historyPeriods[0].Name}.String(),
+				},
+				{
+					Text: fmt.Sprintf("%s Forecast", emoji.CrystalBall),
+					Data: callbackJSON{Type: callbackTypeForecast, StationID: status.Station.ID}.String(),
+				},
+			},
 		},
 		OneTimeKeyboard: true,
 	}
 
 	name := fmt.Sprintf("LocationScreen(%d)", status.Station.ID)
-	return s.sendScreen(name, to, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+	return s.sendScreen(name, chat, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) SubscribedScreen(chat *chatEntity, status *waqi.Status, subscription *subscriptionEntity, message telebot.Editable) error {
+	status = chat.Standard().Convert(status)
+	text := s.generateStatusScreen(status, chat.Location(), "")
+	if subscription.IsDigest() {
+		text += fmt.Sprintf("\n%s Daily summary at %s", emoji.Calendar, formatTimeOfDay(subscription.DigestTime))
+	} else {
+		text += fmt.Sprintf("\n%s Alerts: %s", emoji.Bell, subscription.AlertRule().Describe())
+	}
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      s.generateSubscribedKeyboard(status),
+		OneTimeKeyboard:     true,
+	}
+
+	name := fmt.Sprintf("SubscribedScreen(%d)", status.Station.ID)
+	return s.sendScreen(name, chat, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) AlertRulesScreen(chat *chatEntity, status *waqi.Status, subscription *subscriptionEntity, message telebot.Editable) error {
+	status = chat.Standard().Convert(status)
+	text := s.generateStatusScreen(status, chat.Location(), "")
+	text += fmt.Sprintf("\n%s When should I notify you?", emoji.Bell)
+
+	currentRule := subscription.AlertRule().String()
+	keyboard := make([][]telebot.InlineButton, 0, len(alertRulePresets)+1)
+	for _, preset := range alertRulePresets {
+		rule, err := waqi.ParseAlertRule(preset)
+		if err != nil {
+			return err
+		}
+
+		buttonText := rule.Describe()
+		if rule.String() == currentRule {
+			buttonText = fmt.Sprintf("%s %s", emoji.CheckMark, buttonText)
+		}
+
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: buttonText,
+				Data: callbackJSON{Type: callbackTypeSetAlertRule, StationID: status.Station.ID, Arg: rule.String()}.String(),
+			},
+		})
+	}
+
+	uid := fmt.Sprintf("%d", time.Now().UTC().Unix())
+	keyboard = append(keyboard, []telebot.InlineButton{
+		{
+			Text: fmt.Sprintf("%s Back", emoji.BackArrow),
+			Data: callbackJSON{Type: callbackTypeRefresh, StationID: status.Station.ID, UID: uid}.String(),
+		},
+	})
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
+
+	name := fmt.Sprintf("AlertRulesScreen(%d)", status.Station.ID)
+	return s.sendScreen(name, chat, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
 }
 
-func (s *botScreens) SubscribedScreen(to telebot.Recipient, status *waqi.Status, message telebot.Editable) error {
-	text := s.generateStatusScreen(status)
+func (s *botScreens) SubscriptionsScreen(to telebot.Recipient, subscriptions []*subscriptionEntity, message telebot.Editable) error {
+	if len(subscriptions) == 0 {
+		text := fmt.Sprintf("%s You have no subscriptions.\nSend me a location to subscribe to its air quality updates.", emoji.Bell)
+		markup := &telebot.ReplyMarkup{
+			ReplyKeyboardRemove: true,
+		}
+		return s.sendScreen("SubscriptionsScreen", to, message, text, markup, telebot.ModeHTML)
+	}
 
+	text := fmt.Sprintf("%s You are subscribed to air quality updates for:\n\n", emoji.Bell)
 	uid := fmt.Sprintf("%d", time.Now().UTC().Unix())
+	keyboard := make([][]telebot.InlineButton, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		name := s.getStationName(subscription.StationID, subscription.StationName)
+		text += fmt.Sprintf("• <b>%s</b>\n", html.EscapeString(name))
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: fmt.Sprintf("%s Unsubscribe from %s", emoji.CrossMarkButton, name),
+				Data: callbackJSON{Type: callbackTypeListUnsubscribe, StationID: subscription.StationID, UID: uid}.String(),
+			},
+		})
+	}
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
+
+	return s.sendScreen("SubscriptionsScreen", to, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) UpdatedScreen(chat *chatEntity, status *waqi.Status, prevStatus *waqi.Status, subscription *subscriptionEntity, event waqi.AlertEvent, message telebot.Editable) error {
+	if prevStatus == nil {
+		return s.SubscribedScreen(chat, status, subscription, message)
+	}
+
+	status = chat.Standard().Convert(status)
+	prevStatus = chat.Standard().Convert(prevStatus)
+
+	text := s.generateAlertHeader(subscription.AlertRule(), event, status)
+	text += s.generateDeltaStatusScreen(status, prevStatus, chat.Location(), s.generateHealthAdvice(status, chat.Audience()))
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      s.generateSubscribedKeyboard(status),
+		OneTimeKeyboard:     true,
+	}
+
+	name := fmt.Sprintf("UpdatedScreen(%d)", status.Station.ID)
+	return s.sendScreen(name, chat, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) HeldUpdatesScreen(chat *chatEntity, updates []*heldUpdateEntity, statuses map[int]*waqi.Status) error {
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+	}
+
+	for _, text := range s.generateHeldUpdatesTexts(chat, updates, statuses) {
+		err := s.sendScreen("HeldUpdatesScreen", chat, nil, text, markup, telebot.ModeHTML, telebot.NoPreview)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *botScreens) SettingsScreen(chat *chatEntity, message telebot.Editable) error {
+	loc := chat.Location()
+	quietHours := "off"
+	if chat.HasQuietHours() {
+		quietHours = formatQuietHours(chat.QuietHoursFrom, chat.QuietHoursTo)
+	}
+
+	text := fmt.Sprintf("%s Settings\n\n", emoji.Gear)
+	text += fmt.Sprintf("Time zone: <code>%s</code> (now %s)\n", loc.String(), time.Now().In(loc).Format("15:04"))
+	text += fmt.Sprintf("Quiet hours: <code>%s</code>\n", quietHours)
+	text += fmt.Sprintf("AQI scale: <code>%s</code>\n", chat.Standard().String())
+	text += fmt.Sprintf("Health profile: <code>%s</code>\n", chat.Audience().String())
+	if chat.HasQuietHours() {
+		text += "\nUpdates received during quiet hours will be sent as one message when quiet hours end."
+	}
+
 	markup := &telebot.ReplyMarkup{
 		ReplyKeyboardRemove: true,
 		InlineKeyboard: [][]telebot.InlineButton{
 			{
 				{
-					Text: fmt.Sprintf("%s Refresh", emoji.CounterclockwiseArrowsButton),
-					Data: callbackJSON{Type: callbackTypeRefresh, StationID: status.Station.ID, UID: uid}.String(),
+					Text: fmt.Sprintf("%s Time zone", emoji.GlobeWithMeridians),
+					Data: callbackJSON{Type: callbackTypeTimeZone}.String(),
+				},
+				{
+					Text: fmt.Sprintf("%s Quiet hours", emoji.CrescentMoon),
+					Data: callbackJSON{Type: callbackTypeQuietHours}.String(),
+				},
+			},
+			{
+				{
+					Text: fmt.Sprintf("%s AQI scale", emoji.BarChart),
+					Data: callbackJSON{Type: callbackTypeScale}.String(),
 				},
 				{
-					Text: fmt.Sprintf("%s Unsubscribe", emoji.CrossMarkButton),
-					Data: callbackJSON{Type: callbackTypeUnsubscribe, StationID: status.Station.ID, UID: uid}.String(),
+					Text: fmt.Sprintf("%s Health profile", emoji.Stethoscope),
+					Data: callbackJSON{Type: callbackTypeProfile}.String(),
 				},
 			},
 		},
 		OneTimeKeyboard: true,
 	}
 
-	name := fmt.Sprintf("SubscribedScreen(%d)", status.Station.ID)
-	return s.sendScreen(name, to, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+	return s.sendScreen("SettingsScreen", chat, message, text, markup, telebot.ModeHTML)
 }
 
-func (s *botScreens) UpdatedScreen(to telebot.Recipient, status *waqi.Status, prevStatus *waqi.Status, message telebot.Editable) error {
-	if prevStatus == nil {
-		return s.SubscribedScreen(to, status, message)
+func (s *botScreens) TimeZoneScreen(chat *chatEntity, message telebot.Editable) error {
+	text := fmt.Sprintf("%s Current time zone is <code>%s</code>.\n\n", emoji.GlobeWithMeridians, chat.Location().String())
+	text += "Send me a time zone name (e.g. <code>Europe/Berlin</code>) or an UTC offset (e.g. <code>UTC+3</code>)."
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard: [][]telebot.InlineButton{
+			{
+				{
+					Text: "UTC",
+					Data: callbackJSON{Type: callbackTypeSetTimeZone, Arg: "UTC"}.String(),
+				},
+				{
+					Text: fmt.Sprintf("%s Back", emoji.BackArrow),
+					Data: callbackJSON{Type: callbackTypeSettings}.String(),
+				},
+			},
+		},
+		OneTimeKeyboard: true,
+	}
+
+	return s.sendScreen("TimeZoneScreen", chat, message, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) QuietHoursScreen(chat *chatEntity, message telebot.Editable) error {
+	text := fmt.Sprintf("%s Choose quiet hours or send them as text (e.g. <code>22:30-07:00</code>).\n", emoji.CrescentMoon)
+	text += fmt.Sprintf("Time zone is <code>%s</code>.", chat.Location().String())
+
+	keyboard := make([][]telebot.InlineButton, 0, len(quietHoursPresets)+1)
+	for _, preset := range quietHoursPresets {
+		buttonText := preset
+		if chat.HasQuietHours() && preset == formatQuietHours(chat.QuietHoursFrom, chat.QuietHoursTo) {
+			buttonText = fmt.Sprintf("%s %s", emoji.CheckMark, buttonText)
+		}
+
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: buttonText,
+				Data: callbackJSON{Type: callbackTypeSetQuietHours, Arg: preset}.String(),
+			},
+		})
+	}
+
+	offText := "Off"
+	if !chat.HasQuietHours() {
+		offText = fmt.Sprintf("%s %s", emoji.CheckMark, offText)
+	}
+	keyboard = append(keyboard, []telebot.InlineButton{
+		{
+			Text: offText,
+			Data: callbackJSON{Type: callbackTypeSetQuietHours, Arg: quietHoursOff}.String(),
+		},
+		{
+			Text: fmt.Sprintf("%s Back", emoji.BackArrow),
+			Data: callbackJSON{Type: callbackTypeSettings}.String(),
+		},
+	})
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
+
+	return s.sendScreen("QuietHoursScreen", chat, message, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) ScaleScreen(chat *chatEntity, message telebot.Editable) error {
+	text := fmt.Sprintf("%s Choose an air quality index scale.\n", emoji.BarChart)
+	text += "Air quality levels, their names and colours will follow the chosen scale."
+
+	standard := chat.Standard()
+	keyboard := make([][]telebot.InlineButton, 0, len(waqi.Standards)+1)
+	for _, item := range waqi.Standards {
+		buttonText := item.String()
+		if item == standard {
+			buttonText = fmt.Sprintf("%s %s", emoji.CheckMark, buttonText)
+		}
+
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: buttonText,
+				Data: callbackJSON{Type: callbackTypeSetScale, Arg: string(item)}.String(),
+			},
+		})
+	}
+
+	keyboard = append(keyboard, []telebot.InlineButton{
+		{
+			Text: fmt.Sprintf("%s Back", emoji.BackArrow),
+			Data: callbackJSON{Type: callbackTypeSettings}.String(),
+		},
+	})
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
 	}
 
-	text := s.generateDeltaStatusScreen(status, prevStatus)
+	return s.sendScreen("ScaleScreen", chat, message, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) ProfileScreen(chat *chatEntity, message telebot.Editable) error {
+	text := fmt.Sprintf("%s Who should health advice be addressed to?\n", emoji.Stethoscope)
+	text += "Advice is shown along with air quality and its updates."
+
+	audience := chat.Audience()
+	keyboard := make([][]telebot.InlineButton, 0, len(waqi.Audiences)+1)
+	for _, item := range waqi.Audiences {
+		buttonText := item.String()
+		if item == audience {
+			buttonText = fmt.Sprintf("%s %s", emoji.CheckMark, buttonText)
+		}
+
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: buttonText,
+				Data: callbackJSON{Type: callbackTypeSetProfile, Arg: string(item)}.String(),
+			},
+		})
+	}
+
+	keyboard = append(keyboard, []telebot.InlineButton{
+		{
+			Text: fmt.Sprintf("%s Back", emoji.BackArrow),
+			Data: callbackJSON{Type: callbackTypeSettings}.String(),
+		},
+	})
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
 
+	return s.sendScreen("ProfileScreen", chat, message, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) DigestSettingsScreen(chat *chatEntity, status *waqi.Status, subscription *subscriptionEntity, message telebot.Editable) error {
+	status = chat.Standard().Convert(status)
+	text := s.generateStatusScreen(status, chat.Location(), "")
+	text += fmt.Sprintf("\n%s When should I send you a daily summary?\n", emoji.Calendar)
+	text += fmt.Sprintf("Choose a time or send it as text (e.g. <code>08:30</code>). Time zone is <code>%s</code>.\n", chat.Location().String())
+	text += "Alerts are not sent while daily summary is on."
+
+	const presetsPerRow = 3
+	keyboard := make([][]telebot.InlineButton, 0)
+	for i, preset := range digestTimePresets {
+		buttonText := preset
+		if subscription.IsDigest() && preset == formatTimeOfDay(subscription.DigestTime) {
+			buttonText = fmt.Sprintf("%s %s", emoji.CheckMark, buttonText)
+		}
+
+		if i%presetsPerRow == 0 {
+			keyboard = append(keyboard, make([]telebot.InlineButton, 0, presetsPerRow))
+		}
+		keyboard[len(keyboard)-1] = append(keyboard[len(keyboard)-1], telebot.InlineButton{
+			Text: buttonText,
+			Data: callbackJSON{Type: callbackTypeSetDigest, StationID: status.Station.ID, Arg: preset}.String(),
+		})
+	}
+
+	offText := "Off (send alerts)"
+	if !subscription.IsDigest() {
+		offText = fmt.Sprintf("%s %s", emoji.CheckMark, offText)
+	}
 	uid := fmt.Sprintf("%d", time.Now().UTC().Unix())
+	keyboard = append(keyboard, []telebot.InlineButton{
+		{
+			Text: offText,
+			Data: callbackJSON{Type: callbackTypeSetDigest, StationID: status.Station.ID, Arg: digestOff}.String(),
+		},
+		{
+			Text: fmt.Sprintf("%s Back", emoji.BackArrow),
+			Data: callbackJSON{Type: callbackTypeRefresh, StationID: status.Station.ID, UID: uid}.String(),
+		},
+	})
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
+
+	name := fmt.Sprintf("DigestSettingsScreen(%d)", status.Station.ID)
+	return s.sendScreen(name, chat, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) DigestScreen(chat *chatEntity, digest *waqi.Digest, subscription *subscriptionEntity) error {
+	digest = chat.Standard().ConvertDigest(digest)
+	text := s.generateDigestText(digest)
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      s.generateSubscribedKeyboard(digest.Status),
+		OneTimeKeyboard:     true,
+	}
+
+	name := fmt.Sprintf("DigestScreen(%d)", subscription.StationID)
+	return s.sendScreen(name, chat, nil, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) HistoryScreen(chat *chatEntity, statuses []*waqi.Status, period historyPeriod, chart []byte, message telebot.Editable) error {
+	station := statuses[len(statuses)-1].Station
+	converted := make([]*waqi.Status, 0, len(statuses))
+	for _, status := range statuses {
+		converted = append(converted, chat.Standard().Convert(status))
+	}
+	text := s.generateHistoryText(converted, period)
+
+	buttons := make([]telebot.InlineButton, 0, len(historyPeriods))
+	for _, p := range historyPeriods {
+		buttonText := p.Name
+		if p.Name == period.Name {
+			buttonText = fmt.Sprintf("%s %s", emoji.CheckMark, buttonText)
+		}
+
+		buttons = append(buttons, telebot.InlineButton{
+			Text: buttonText,
+			Data: callbackJSON{Type: callbackTypeHistory, StationID: station.ID, Arg: p.Name}.String(),
+		})
+	}
+
+	markup := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{buttons},
+	}
+
+	photo := &telebot.Photo{
+		File:    telebot.FromReader(bytes.NewReader(chart)),
+		Caption: text,
+	}
+
+	var err error
+	name := fmt.Sprintf("HistoryScreen(%d, %s)", station.ID, period.Name)
+	if message != nil {
+		_, err = s.bot.EditMedia(message, photo, markup, telebot.ModeHTML)
+		msgID, chatID := message.MessageSig()
+		s.logger.Printf("sent %s to %s updating %s from %d", name, chat.Recipient(), msgID, chatID)
+	} else {
+		_, err = s.bot.Send(chat, photo, markup, telebot.ModeHTML)
+		s.logger.Printf("sent %s to %s", name, chat.Recipient())
+	}
+
+	return err
+}
+
+func (s *botScreens) HistoryStationsScreen(chat *chatEntity, subscriptions []*subscriptionEntity) error {
+	if len(subscriptions) == 0 {
+		text := fmt.Sprintf("%s You have no subscriptions.\nSend me a location and press \"History\" to see its air quality history.", emoji.ChartIncreasing)
+		markup := &telebot.ReplyMarkup{
+			ReplyKeyboardRemove: true,
+		}
+		return s.sendScreen("HistoryStationsScreen", chat, nil, text, markup, telebot.ModeHTML)
+	}
+
+	text := fmt.Sprintf("%s Choose a station to see its air quality history.", emoji.ChartIncreasing)
+	keyboard := make([][]telebot.InlineButton, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: s.getStationName(subscription.StationID, subscription.StationName),
+				Data: callbackJSON{Type: callbackTypeHistory, StationID: subscription.StationID, Arg: historyPeriods[0].Name}.String(),
+			},
+		})
+	}
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
+
+	return s.sendScreen("HistoryStationsScreen", chat, nil, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) HistoryUnavailableScreen(chat *chatEntity, reason string) error {
+	text := fmt.Sprintf("%s %s", emoji.ChartIncreasing, reason)
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+	}
+
+	return s.sendScreen("HistoryUnavailableScreen", chat, nil, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) ForecastScreen(chat *chatEntity, status *waqi.Status, subscription *subscriptionEntity, now time.Time, message telebot.Editable) error {
+	status = chat.Standard().Convert(status)
+	stationName := s.getStationName(status.Station.ID, status.Station.Name)
+
+	text := fmt.Sprintf("%s Air quality forecast\n\n", emoji.CrystalBall)
+	if status.Station.URL != "" {
+		text += fmt.Sprintf("<b><a href=\"%s\">%s</a></b>\n", status.Station.URL, html.EscapeString(stationName))
+	} else {
+		text += fmt.Sprintf("<b>%s</b>\n", html.EscapeString(stationName))
+	}
+
+	// Days before today are of no interest
+	today := now.In(chat.Location()).Format(waqi.ForecastDateLayout)
+	days := 0
+	if status.Forecast != nil {
+		for _, day := range status.Forecast.Days {
+			if day.Date < today {
+				continue
+			}
+
+			text += "\n" + s.generateForecastDay(status.Standard, day)
+			days++
+		}
+	}
+	if days == 0 {
+		text += "\nThis station doesn't provide a forecast.\n"
+	}
+
+	text += fmt.Sprintf("\n<i>Forecasted values are %s sub-indices.</i>", s.getIndexName(status.Standard))
+	text += s.generateAttributions(status.Attributions)
+
+	keyboard := make([][]telebot.InlineButton, 0)
+	if subscription != nil {
+		button := telebot.InlineButton{
+			Text: fmt.Sprintf("%s Evening alert: off", emoji.BellWithSlash),
+			Data: callbackJSON{Type: callbackTypeSetForecastAlert, StationID: status.Station.ID, Arg: forecastAlertOn}.String(),
+		}
+		if subscription.ForecastAlert {
+			button = telebot.InlineButton{
+				Text: fmt.Sprintf("%s Evening alert: on", emoji.Bell),
+				Data: callbackJSON{Type: callbackTypeSetForecastAlert, StationID: status.Station.ID, Arg: forecastAlertOff}.String(),
+			}
+		}
+		keyboard = append(keyboard, []telebot.InlineButton{button})
+	}
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
+
+	name := fmt.Sprintf("ForecastScreen(%d)", status.Station.ID)
+	return s.sendScreen(name, chat, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) ForecastStationsScreen(chat *chatEntity, subscriptions []*subscriptionEntity) error {
+	if len(subscriptions) == 0 {
+		text := fmt.Sprintf("%s You have no subscriptions.\nSend me a location and press \"Forecast\" to see its air quality forecast.", emoji.CrystalBall)
+		markup := &telebot.ReplyMarkup{
+			ReplyKeyboardRemove: true,
+		}
+		return s.sendScreen("ForecastStationsScreen", chat, nil, text, markup, telebot.ModeHTML)
+	}
+
+	text := fmt.Sprintf("%s Choose a station to see its air quality forecast.", emoji.CrystalBall)
+	keyboard := make([][]telebot.InlineButton, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: s.getStationName(subscription.StationID, subscription.StationName),
+				Data: callbackJSON{Type: callbackTypeForecast, StationID: subscription.StationID}.String(),
+			},
+		})
+	}
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
+
+	return s.sendScreen("ForecastStationsScreen", chat, nil, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) ForecastAlertScreen(chat *chatEntity, status *waqi.Status, day *waqi.ForecastDay) error {
+	status = chat.Standard().Convert(status)
+	text := fmt.Sprintf("<b>%s</b>\n\n", html.EscapeString(s.getStationName(status.Station.ID, status.Station.Name)))
+	text += s.generateForecastAlertText(status.Standard, day)
+	text += fmt.Sprintf("\nAir quality now: %s <code>%s</code> (%s %0.0f)", s.getLevelIcon(status.Standard, status.Level), s.getLevelName(status.Standard, status.Level), s.getIndexName(status.Standard), status.AQI)
+
 	markup := &telebot.ReplyMarkup{
 		ReplyKeyboardRemove: true,
 		InlineKeyboard: [][]telebot.InlineButton{
 			{
 				{
-					Text: fmt.Sprintf("%s Refresh", emoji.CounterclockwiseArrowsButton),
-					Data: callbackJSON{Type: callbackTypeRefresh, StationID: status.Station.ID, UID: uid}.String(),
-				},
-				{
-					Text: fmt.Sprintf("%s Unsubscribe", emoji.CrossMarkButton),
-					Data: callbackJSON{Type: callbackTypeUnsubscribe, StationID: status.Station.ID, UID: uid}.String(),
+					Text: fmt.Sprintf("%s Forecast", emoji.CrystalBall),
+					Data: callbackJSON{Type: callbackTypeForecast, StationID: status.Station.ID}.String(),
 				},
 			},
 		},
 		OneTimeKeyboard: true,
 	}
 
-	name := fmt.Sprintf("UpdatedScreen(%d)", status.Station.ID)
-	return s.sendScreen(name, to, message, text, markup, telebot.ModeHTML, telebot.NoPreview)
+	name := fmt.Sprintf("ForecastAlertScreen(%d)", status.Station.ID)
+	return s.sendScreen(name, chat, nil, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) SearchResultsScreen(chat *chatEntity, keyword string, results []*waqi.StationSummary) error {
+	if len(results) == 0 {
+		text := fmt.Sprintf("%s Nothing found for <b>%s</b>.\nTry another name or send me a location.",
+			emoji.MagnifyingGlassTiltedLeft, html.EscapeString(keyword))
+		markup := &telebot.ReplyMarkup{
+			ReplyKeyboardRemove: true,
+		}
+		return s.sendScreen("SearchResultsScreen", chat, nil, text, markup, telebot.ModeHTML)
+	}
+
+	text := fmt.Sprintf("%s Stations matching <b>%s</b>:", emoji.MagnifyingGlassTiltedLeft, html.EscapeString(keyword))
+	keyboard := make([][]telebot.InlineButton, 0, len(results))
+	for _, result := range results {
+		buttonText := s.getStationName(result.Station.ID, result.Station.Name)
+		if result.AQI != nil {
+			// Station summaries carry no pollutant values, so only their level is shown in chat's standard
+			buttonText = fmt.Sprintf("%s %s (AQI %0.0f)", s.getLevelIcon(chat.Standard(), result.Level), buttonText, *result.AQI)
+		}
+
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: buttonText,
+				Data: callbackJSON{Type: callbackTypeStation, StationID: result.Station.ID}.String(),
+			},
+		})
+	}
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      keyboard,
+		OneTimeKeyboard:     true,
+	}
+
+	return s.sendScreen("SearchResultsScreen", chat, nil, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) NearbyStationsScreen(chat *chatEntity, stations []*waqi.NearbyStation) error {
+	text := fmt.Sprintf("%s Other stations nearby:", emoji.RoundPushpin)
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      s.generateNearbyStationsKeyboard(chat.Standard(), stations),
+		OneTimeKeyboard:     true,
+	}
+
+	return s.sendScreen("NearbyStationsScreen", chat, nil, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) StationOfflineScreen(chat *chatEntity, status *waqi.Status, since time.Time, alternatives []*waqi.NearbyStation) error {
+	status = chat.Standard().Convert(status)
+	text := fmt.Sprintf("<b>%s</b>\n\n", html.EscapeString(s.getStationName(status.Station.ID, status.Station.Name)))
+	text += s.generateStationOfflineText(since, chat.Location())
+	text += fmt.Sprintf("\nLast reported: %s <code>%s</code> (%s %0.0f)", s.getLevelIcon(status.Standard, status.Level), s.getLevelName(status.Standard, status.Level), s.getIndexName(status.Standard), status.AQI)
+	if len(alternatives) > 0 {
+		text += fmt.Sprintf("\n\n%s Try one of stations nearby:", emoji.RoundPushpin)
+	}
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      s.generateNearbyStationsKeyboard(chat.Standard(), alternatives),
+		OneTimeKeyboard:     true,
+	}
+
+	name := fmt.Sprintf("StationOfflineScreen(%d)", status.Station.ID)
+	return s.sendScreen(name, chat, nil, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) StationOnlineScreen(chat *chatEntity, status *waqi.Status, since time.Time) error {
+	status = chat.Standard().Convert(status)
+	text := s.generateStationOnlineText(since, chat.Location()) + "\n\n"
+	text += s.generateStatusScreen(status, chat.Location(), "")
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+		InlineKeyboard:      s.generateSubscribedKeyboard(status),
+		OneTimeKeyboard:     true,
+	}
+
+	name := fmt.Sprintf("StationOnlineScreen(%d)", status.Station.ID)
+	return s.sendScreen(name, chat, nil, text, markup, telebot.ModeHTML, telebot.NoPreview)
+}
+
+func (s *botScreens) HelpScreen(chat *chatEntity, commands []telebot.Command) error {
+	text := fmt.Sprintf("%s Send me a location or a city name to get its current air quality index.\n\nAvailable commands:\n", emoji.Information)
+	for _, command := range commands {
+		text += fmt.Sprintf("/%s - %s\n", command.Text, html.EscapeString(command.Description))
+	}
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+	}
+
+	return s.sendScreen("HelpScreen", chat, nil, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) InvalidInputScreen(chat *chatEntity, hint string) error {
+	text := fmt.Sprintf("%s %s", emoji.Warning, hint)
+
+	markup := &telebot.ReplyMarkup{
+		ReplyKeyboardRemove: true,
+	}
+
+	return s.sendScreen("InvalidInputScreen", chat, nil, text, markup, telebot.ModeHTML)
+}
+
+func (s *botScreens) generateSubscribedKeyboard(status *waqi.Status) [][]telebot.InlineButton {
+	uid := fmt.Sprintf("%d", time.Now().UTC().Unix())
+	return [][]telebot.InlineButton{
+		{
+			{
+				Text: fmt.Sprintf("%s Refresh", emoji.CounterclockwiseArrowsButton),
+				Data: callbackJSON{Type: callbackTypeRefresh, StationID: status.Station.ID, UID: uid}.String(),
+			},
+			{
+				Text: fmt.Sprintf("%s Unsubscribe", emoji.CrossMarkButton),
+				Data: callbackJSON{Type: callbackTypeUnsubscribe, StationID: status.Station.ID, UID: uid}.String(),
+			},
+		},
+		{
+			{
+				Text: fmt.Sprintf("%s Alerts", emoji.Bell),
+				Data: callbackJSON{Type: callbackTypeAlertRules, StationID: status.Station.ID, UID: uid}.String(),
+			},
+			{
+				Text: fmt.Sprintf("%s Daily", emoji.Calendar),
+				Data: callbackJSON{Type: callbackTypeDigest, StationID: status.Station.ID, UID: uid}.String(),
+			},
+		},
+		{
+			{
+				Text: fmt.Sprintf("%s History", emoji.ChartIncreasing),
+				Data: callbackJSON{Type: callbackTypeHistory, StationID: status.Station.ID, Arg: historyPeriods[0].Name}.String(),
+			},
+			{
+				Text: fmt.Sprintf("%s Forecast", emoji.CrystalBall),
+				Data: callbackJSON{Type: callbackTypeForecast, StationID: status.Station.ID}.String(),
+			},
+		},
+	}
+}
+
+func (s *botScreens) generateNearbyStationsKeyboard(standard waqi.Standard, stations []*waqi.NearbyStation) [][]telebot.InlineButton {
+	keyboard := make([][]telebot.InlineButton, 0, len(stations))
+	for _, station := range stations {
+		buttonText := fmt.Sprintf("%s, %s", s.getStationName(station.Station.ID, station.Station.Name), s.formatDistance(station.Distance))
+		if station.AQI != nil {
+			// Station summaries carry no pollutant values, so only their level is shown in chat's standard
+			buttonText = fmt.Sprintf("%s %s (AQI %0.0f)", s.getLevelIcon(standard, station.Level), buttonText, *station.AQI)
+		}
+
+		keyboard = append(keyboard, []telebot.InlineButton{
+			{
+				Text: buttonText,
+				Data: callbackJSON{Type: callbackTypeStation, StationID: station.Station.ID}.String(),
+			},
+		})
+	}
+
+	return keyboard
+}
+
+func (s *botScreens) generateHeldUpdatesTexts(chat *chatEntity, updates []*heldUpdateEntity, statuses map[int]*waqi.Status) []string {
+	header := fmt.Sprintf("%s While quiet hours were on:\n\n", emoji.CrescentMoon)
+	texts := make([]string, 0, 1)
+	text := header
+	for _, update := range updates {
+		block := fmt.Sprintf("<b>%s</b>\n", html.EscapeString(s.getStationName(update.StationID, update.StationName)))
+		block += update.Text + "\n"
+
+		status, exists := statuses[update.StationID]
+		if exists {
+			status = chat.Standard().Convert(status)
+			block += fmt.Sprintf("Now: %s <code>%s</code> (%s %0.0f)\n", s.getLevelIcon(status.Standard, status.Level), s.getLevelName(status.Standard, status.Level), s.getIndexName(status.Standard), status.AQI)
+		}
+		block += "\n"
+
+		// Updates of many stations are split into several messages
+		if text != header && len(text)+len(block) > maxMessageLength {
+			texts = append(texts, text)
+			text = ""
+		}
+		text += block
+	}
+
+	return append(texts, text)
+}
+
+func (s *botScreens) generateDigestText(digest *waqi.Digest) string {
+	status := digest.Status
+	stationName := s.getStationName(status.Station.ID, status.Station.Name)
+
+	text := fmt.Sprintf("%s Daily summary\n\n", emoji.Calendar)
+	if status.Station.URL != "" {
+		text += fmt.Sprintf("<b><a href=\"%s\">%s</a></b>\n\n", status.Station.URL, html.EscapeString(stationName))
+	} else {
+		text += fmt.Sprintf("<b>%s</b>\n\n", html.EscapeString(stationName))
+	}
+
+	indexName := s.getIndexName(status.Standard)
+	getValueIcon := s.getValueIconFunc(status.Standard)
+	text += fmt.Sprintf("Air quality now: %s <code>%s</code> (%s %0.0f)\n\n", s.getLevelIcon(status.Standard, status.Level), s.getLevelName(status.Standard, status.Level), indexName, status.AQI)
+	text += fmt.Sprintf("Last %0.0f hours:\n", digest.To.Sub(digest.From).Hours())
+	text += fmt.Sprintf("<code>Min %s: %6.0f</code> %s\n", indexName, digest.MinAQI, getValueIcon(digest.MinAQI))
+	text += fmt.Sprintf("<code>Avg %s: %6.0f</code> %s\n", indexName, digest.AvgAQI, getValueIcon(digest.AvgAQI))
+	text += fmt.Sprintf("<code>Max %s: %6.0f</code> %s\n", indexName, digest.MaxAQI, getValueIcon(digest.MaxAQI))
+	if digest.DominantPollutant != "" {
+		text += fmt.Sprintf("\nDominant pollutant: <code>%s</code>\n", digest.DominantPollutant.String())
+	}
+
+	return text
+}
+
+func (s *botScreens) generateHistoryText(statuses []*waqi.Status, period historyPeriod) string {
+	station := statuses[len(statuses)-1].Station
+	standard := statuses[len(statuses)-1].Standard
+	minAQI, maxAQI, sumAQI := statuses[0].AQI, statuses[0].AQI, float32(0)
+	for _, status := range statuses {
+		if status.AQI < minAQI {
+			minAQI = status.AQI
+		}
+		if status.AQI > maxAQI {
+			maxAQI = status.AQI
+		}
+		sumAQI += status.AQI
+	}
+	avgAQI := sumAQI / float32(len(statuses))
+
+	getValueIcon := s.getValueIconFunc(standard)
+	text := fmt.Sprintf("<b>%s</b>\n", html.EscapeString(s.getStationName(station.ID, station.Name)))
+	text += fmt.Sprintf("%s Air quality over last %s\n", emoji.ChartIncreasing, period.Description)
+	text += fmt.Sprintf("%s: min <code>%0.0f</code> %s, avg <code>%0.0f</code> %s, max <code>%0.0f</code> %s",
+		s.getIndexName(standard),
+		minAQI, getValueIcon(minAQI),
+		avgAQI, getValueIcon(avgAQI),
+		maxAQI, getValueIcon(maxAQI))
+
+	return text
+}
+
+func (s *botScreens) generateStationOfflineText(since time.Time, loc *time.Location) string {
+	return fmt.Sprintf("%s Station is offline since %s, its data might be outdated", emoji.Warning, since.In(loc).Format("2006-Jan-2 15:04 MST"))
 }
 
-func (s *botScreens) generateStatusScreen(status *waqi.Status) string {
+func (s *botScreens) generateStationOnlineText(since time.Time, loc *time.Location) string {
+	return fmt.Sprintf("%s Station is back online (it was offline since %s)", emoji.CheckMarkButton, since.In(loc).Format("2006-Jan-2 15:04 MST"))
+}
+
+func (s *botScreens) generateForecastAlertText(standard waqi.Standard, day *waqi.ForecastDay) string {
+	max, parameter := day.MaxAQI()
+	level := standard.OrDefault().CalcLevel(max)
+	return fmt.Sprintf("%s Tomorrow air quality is expected to be %s <code>%s</code> (%s up to %0.0f)\n",
+		emoji.CrystalBall, s.getLevelIcon(standard, level), s.getLevelName(standard, level), parameter.String(), max)
+}
+
+func (s *botScreens) generateForecastDay(standard waqi.Standard, day *waqi.ForecastDay) string {
+	title := day.Date
+	date, err := time.Parse(waqi.ForecastDateLayout, day.Date)
+	if err == nil {
+		title = date.Format("Mon, Jan 2")
+	}
+
+	// A day might have no pollutant forecasts (e.g. UV index only)
+	text := fmt.Sprintf("<b>%s</b>\n", title)
+	calcLevel := standard.OrDefault().CalcLevel
+	if max, parameter := day.MaxAQI(); parameter != "" {
+		level := calcLevel(max)
+		text = fmt.Sprintf("<b>%s</b>: %s <code>%s</code>\n", title, s.getLevelIcon(standard, level), s.getLevelName(standard, level))
+	}
+	text = s.appendForecastValue(text, "PM2.5", day.PM25, standard, calcLevel)
+	text = s.appendForecastValue(text, "PM10 ", day.PM10, standard, calcLevel)
+	text = s.appendForecastValue(text, "O3   ", day.O3, standard, calcLevel)
+	text = s.appendForecastValue(text, "UVI  ", day.UVI, standard, nil)
+	return text
+}
+
+func (s *botScreens) appendForecastValue(text, name string, value *waqi.ForecastValue, standard waqi.Standard, calcLevel func(float32) waqi.Level) string {
+	if value != nil {
+		iconStr := ""
+		if calcLevel != nil {
+			iconStr = s.getLevelIcon(standard, calcLevel(value.Max))
+		}
+
+		text += fmt.Sprintf("<code>%s: %4.0f..%-4.0f</code> %s\n", name, value.Min, value.Max, iconStr)
+	}
+	return text
+}
+
+func (s *botScreens) generateAlertHeader(rule *waqi.AlertRule, event waqi.AlertEvent, status *waqi.Status) string {
+	text := s.generateAlertText(rule, event, status)
+	if text == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s %s\n\n", emoji.Bell, text)
+}
+
+func (s *botScreens) generateAlertText(rule *waqi.AlertRule, event waqi.AlertEvent, status *waqi.Status) string {
+	switch event {
+	case waqi.LevelChangedAlertEvent:
+		return fmt.Sprintf("Air quality has changed to %s <code>%s</code>", s.getLevelIcon(status.Standard, status.Level), s.getLevelName(status.Standard, status.Level))
+	case waqi.RaisedAlertEvent:
+		return fmt.Sprintf("%s has risen above %0.1f", rule.Parameter, rule.Threshold)
+	case waqi.ClearedAlertEvent:
+		return fmt.Sprintf("%s has dropped below %0.1f", rule.Parameter, rule.Threshold)
+	default:
+		return ""
+	}
+}
+
+func (s *botScreens) generateStatusScreen(status *waqi.Status, loc *time.Location, advice string) string {
 	// First row - title and hyperlink
 	text := ""
 	stationName := status.Station.Name
@@ -152,26 +1031,50 @@ func (s *botScreens) generateStatusScreen(status *waqi.Status) string {
 	}
 
 	// Second row - status icon and text
-	text += fmt.Sprintf("Air quality: %s <code>%s</code>\n", s.getLevelIcon(status.Level), status.Level.String())
+	text += fmt.Sprintf("Air quality: %s <code>%s</code>\n", s.getLevelIcon(status.Standard, status.Level), s.getLevelName(status.Standard, status.Level))
 	text += "\n"
 
 	// Third and subsequent rows - parameters
-	unit := "μg/m3"
-	text = s.appendStatusParameter(text, "AQI  ", &status.AQI, nil, "", waqi.CalcAQILevel)
-	text = s.appendStatusParameter(text, "PM2.1", status.PM25, nil, unit, waqi.CalcPM25Level)
-	text = s.appendStatusParameter(text, "PM10 ", status.PM10, nil, unit, waqi.CalcPM10Level)
-	text = s.appendStatusParameter(text, "O3   ", status.O3, nil, unit, waqi.CalcO3Level)
-	text = s.appendStatusParameter(text, "NO2  ", status.NO2, nil, unit, waqi.CalcNO2Level)
-	text = s.appendStatusParameter(text, "SO2  ", status.SO2, nil, unit, waqi.CalcSO2Level)
-	text = s.appendStatusParameter(text, "CO   ", status.CO, nil, unit, waqi.CalcCOLevel)
+	// Pollutants are sub-indices of the status standard rather than concentrations, so they have no units
+	getLevelIcon := s.getValueIconFunc(status.Standard)
+	text = s.appendStatusParameter(text, "AQI  ", &status.AQI, nil, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "PM2.5", status.PM25, nil, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "PM10 ", status.PM10, nil, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "O3   ", status.O3, nil, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "NO2  ", status.NO2, nil, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "SO2  ", status.SO2, nil, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "CO   ", status.CO, nil, "", getLevelIcon)
+	if status.DominantPollutant != "" {
+		text += fmt.Sprintf("\nDominant pollutant: <code>%s</code>\n", status.DominantPollutant.String())
+	}
+
+	// Health advice rows
+	text += advice
+
+	// Weather rows
+	if status.Weather != nil {
+		text += "\n"
+		text = s.appendStatusParameter(text, "Temp ", status.Weather.Temperature, nil, "°C", nil)
+		text = s.appendStatusParameter(text, "Dew  ", status.Weather.DewPoint, nil, "°C", nil)
+		text = s.appendStatusParameter(text, "Hum  ", status.Weather.Humidity, nil, "%", nil)
+		text = s.appendStatusParameter(text, "Press", status.Weather.Pressure, nil, "hPa", nil)
+		text = s.appendStatusParameter(text, "Wind ", status.Weather.Wind, nil, "m/s", nil)
+		text = s.appendStatusParameter(text, "Gust ", status.Weather.WindGust, nil, "m/s", nil)
+	}
 
 	// Last row - date and time
-	text += fmt.Sprintf("\nUpdated at %s UTC", status.Time.Format("2006-Jan-2 15:04:05"))
+	text += fmt.Sprintf("\nUpdated at %s", status.Time.In(loc).Format("2006-Jan-2 15:04:05 MST"))
+	if status.Stale {
+		text += fmt.Sprintf("\n%s <i>WAQI service is not responding, data might be outdated</i>", emoji.Warning)
+	}
+
+	// Data sources should be credited according to WAQI terms of use
+	text += s.generateAttributions(status.Attributions)
 
 	return text
 }
 
-func (s *botScreens) generateDeltaStatusScreen(status *waqi.Status, prevStatus *waqi.Status) string {
+func (s *botScreens) generateDeltaStatusScreen(status *waqi.Status, prevStatus *waqi.Status, loc *time.Location, advice string) string {
 	// First row - title and hyperlink
 	text := ""
 	stationName := status.Station.Name
@@ -182,26 +1085,99 @@ func (s *botScreens) generateDeltaStatusScreen(status *waqi.Status, prevStatus *
 	}
 
 	// Second row - status icon and text
-	text += fmt.Sprintf("Air quality: %s <code>%s</code>\n", s.getLevelIcon(status.Level), status.Level.String())
+	text += fmt.Sprintf("Air quality: %s <code>%s</code>\n", s.getLevelIcon(status.Standard, status.Level), s.getLevelName(status.Standard, status.Level))
 	text += "\n"
 
 	// Third and subsequent rows - parameters
-	unit := "μg/m3"
-	text = s.appendStatusParameter(text, "AQI  ", &status.AQI, &prevStatus.AQI, "", waqi.CalcAQILevel)
-	text = s.appendStatusParameter(text, "PM2.1", status.PM25, prevStatus.PM25, unit, waqi.CalcPM25Level)
-	text = s.appendStatusParameter(text, "PM10 ", status.PM10, prevStatus.PM10, unit, waqi.CalcPM10Level)
-	text = s.appendStatusParameter(text, "O3   ", status.O3, prevStatus.O3, unit, waqi.CalcO3Level)
-	text = s.appendStatusParameter(text, "NO2  ", status.NO2, prevStatus.NO2, unit, waqi.CalcNO2Level)
-	text = s.appendStatusParameter(text, "SO2  ", status.SO2, prevStatus.SO2, unit, waqi.CalcSO2Level)
-	text = s.appendStatusParameter(text, "CO   ", status.CO, prevStatus.CO, unit, waqi.CalcCOLevel)
+	// Pollutants are sub-indices of the status standard rather than concentrations, so they have no units
+	getLevelIcon := s.getValueIconFunc(status.Standard)
+	text = s.appendStatusParameter(text, "AQI  ", &status.AQI, &prevStatus.AQI, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "PM2.5", status.PM25, prevStatus.PM25, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "PM10 ", status.PM10, prevStatus.PM10, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "O3   ", status.O3, prevStatus.O3, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "NO2  ", status.NO2, prevStatus.NO2, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "SO2  ", status.SO2, prevStatus.SO2, "", getLevelIcon)
+	text = s.appendStatusParameter(text, "CO   ", status.CO, prevStatus.CO, "", getLevelIcon)
+
+	// Health advice rows
+	text += advice
 
 	// Last row - date and time
-	text += fmt.Sprintf("\nUpdated at %s UTC", status.Time.Format("2006-Jan-2 15:04:05"))
+	text += fmt.Sprintf("\nUpdated at %s", status.Time.In(loc).Format("2006-Jan-2 15:04:05 MST"))
+	text += s.generateAttributions(status.Attributions)
 
 	return text
 }
 
-func (s *botScreens) getLevelIcon(level waqi.Level) string {
+func (s *botScreens) generateHealthAdvice(status *waqi.Status, audience waqi.Audience) string {
+	advice := waqi.HealthAdvice(status.Level, status.DominantPollutant, audience)
+	if len(advice) == 0 {
+		return ""
+	}
+
+	text := fmt.Sprintf("\n%s Advice for %s:\n", emoji.LightBulb, strings.ToLower(audience.OrDefault().String()))
+	for _, item := range advice {
+		text += fmt.Sprintf("• %s\n", html.EscapeString(item))
+	}
+	return text
+}
+
+func (s *botScreens) generateAttributions(attributions []*waqi.Attribution) string {
+	if len(attributions) == 0 {
+		return ""
+	}
+
+	links := make([]string, 0, len(attributions))
+	for _, attribution := range attributions {
+		if attribution.URL != "" {
+			links = append(links, fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(attribution.URL), html.EscapeString(attribution.Name)))
+		} else {
+			links = append(links, html.EscapeString(attribution.Name))
+		}
+	}
+
+	return fmt.Sprintf("\n\n<i>Data: %s</i>", strings.Join(links, ", "))
+}
+
+func (s *botScreens) getStationName(stationID int, stationName string) string {
+	if stationName == "" {
+		return fmt.Sprintf("station #%d", stationID)
+	}
+
+	return stationName
+}
+
+func (s *botScreens) formatDistance(distance float64) string {
+	if distance < 1 {
+		return fmt.Sprintf("%0.0f m", distance*1000)
+	}
+
+	return fmt.Sprintf("%0.1f km", distance)
+}
+
+func (s *botScreens) getValueIconFunc(standard waqi.Standard) func(float32) string {
+	return func(value float32) string {
+		return s.getLevelIcon(standard, standard.OrDefault().CalcLevel(value))
+	}
+}
+
+func (s *botScreens) getLevelName(standard waqi.Standard, level waqi.Level) string {
+	return standard.OrDefault().LevelName(level)
+}
+
+func (s *botScreens) getIndexName(standard waqi.Standard) string {
+	if standard.OrDefault() == waqi.USEPAStandard {
+		return "AQI"
+	}
+
+	return standard.String()
+}
+
+func (s *botScreens) getLevelIcon(standard waqi.Standard, level waqi.Level) string {
+	if icons, exists := standardLevelIcons[standard.OrDefault()]; exists {
+		return icons[level].String()
+	}
+
 	var icon emoji.Emoji = ""
 	switch level {
 	case waqi.GoodLevel:
@@ -227,7 +1203,7 @@ func (s *botScreens) getLevelIcon(level waqi.Level) string {
 	return icon.String()
 }
 
-func (s *botScreens) appendStatusParameter(text, name string, value *float32, prevValue *float32, unit string, calcLevel func(float32) waqi.Level) string {
+func (s *botScreens) appendStatusParameter(text, name string, value *float32, prevValue *float32, unit string, getLevelIcon func(float32) string) string {
 	if value != nil {
 		valueStr := fmt.Sprintf("%0.1f", *value)
 		const minValueLength = 6
@@ -241,8 +1217,8 @@ func (s *botScreens) appendStatusParameter(text, name string, value *float32, pr
 		}
 
 		iconStr := ""
-		if calcLevel != nil {
-			iconStr = s.getLevelIcon(calcLevel(*value))
+		if getLevelIcon != nil {
+			iconStr = getLevelIcon(*value)
 		}
 
 		prevStr := ""
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enescakir/emoji"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+func screenStatus(t time.Time, aqi float32) *waqi.Status {
+	pm25 := aqi
+	return &waqi.Status{
+		Station: &waqi.Station{ID: 123, Name: "Station #123"},
+		Time:    t,
+		AQI:     aqi,
+		Level:   waqi.CalcAQILevel(aqi),
+		PM25:    &pm25,
+	}
+}
+
+func TestDigestTextNonEPAChat(t *testing.T) {
+	a := assert.New(t)
+
+	to := time.Date(2021, 5, 2, 8, 0, 0, 0, time.UTC)
+	from := to.Add(-waqi.DigestPeriod)
+	history := []*waqi.Status{
+		screenStatus(from.Add(time.Hour), 20),
+		screenStatus(from.Add(2*time.Hour), 102),
+	}
+	digest := waqi.CalcDigest(screenStatus(to, 60), history, from, to)
+
+	s := &botScreens{}
+	chat := &chatEntity{Scale: string(waqi.EUCAQIStandard)}
+	text := s.generateDigestText(chat.Standard().ConvertDigest(digest))
+
+	// PM2.5 sub-index 102 is "High" on CAQI scale, which is shown in orange
+	a.Contains(text, "(EU CAQI ")
+	a.Contains(text, "<code>Max EU CAQI:     80</code> "+emoji.OrangeSquare.String())
+	a.NotContains(text, "Max AQI")
+}
+
+func TestHistoryTextNonEPAChat(t *testing.T) {
+	a := assert.New(t)
+
+	to := time.Date(2021, 5, 2, 8, 0, 0, 0, time.UTC)
+	statuses := []*waqi.Status{
+		screenStatus(to.Add(-time.Hour), 20),
+		screenStatus(to, 102),
+	}
+
+	s := &botScreens{}
+	chat := &chatEntity{Scale: string(waqi.EUCAQIStandard)}
+	converted := make([]*waqi.Status, 0, len(statuses))
+	for _, status := range statuses {
+		converted = append(converted, chat.Standard().Convert(status))
+	}
+	text := s.generateHistoryText(converted, historyPeriods[0])
+
+	a.Contains(text, "EU CAQI: min")
+	a.Contains(text, "max <code>80</code> "+emoji.OrangeSquare.String())
+}
+
+func TestHeldUpdatesTextsSplit(t *testing.T) {
+	a := assert.New(t)
+
+	updates := make([]*heldUpdateEntity, 0)
+	for i := 0; i < 50; i++ {
+		updates = append(updates, &heldUpdateEntity{
+			StationID:   i,
+			StationName: fmt.Sprintf("Station #%d", i),
+			Text:        strings.Repeat("x", 200),
+		})
+	}
+
+	s := &botScreens{}
+	texts := s.generateHeldUpdatesTexts(&chatEntity{}, updates, nil)
+
+	// Each station should be delivered once, and no message should exceed Telegram limit
+	a.True(len(texts) > 1)
+	count := 0
+	for _, text := range texts {
+		a.True(len(text) <= maxMessageLength)
+		count += strings.Count(text, "</b>")
+	}
+	a.Equal(len(updates), count)
+
+	// Few updates should fit into a single message
+	a.Len(s.generateHeldUpdatesTexts(&chatEntity{}, updates[:2], nil), 1)
+}
@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"context"
+	"strings"
+
+	"gopkg.in/tucnak/telebot.v2"
+)
+
+// maxSearchResults is a max count of search results shown to user
+const maxSearchResults = 10
+
+// search looks up stations by a keyword and shows matching ones
+func (s *botService) search(ctx context.Context, chat *chatEntity, keyword string) error {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return s.Screens.WelcomeScreen(chat, nil)
+	}
+
+	results, err := s.WAQI.Search(ctx, keyword)
+	if err != nil {
+		return err
+	}
+
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+
+	return s.Screens.SearchResultsScreen(chat, keyword, results)
+}
+
+// onCallbackStation handles "station" callbacks
+func (s *botService) onCallbackStation(ctx context.Context, _ *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	status, err := s.WAQI.GetByStation(ctx, d.StationID)
+	if err != nil {
+		return s.onLookupError(chat, err)
+	}
+
+	// Search results are kept so user could pick another station
+	return s.showStatus(chat, status, nil)
+}
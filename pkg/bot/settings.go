@@ -0,0 +1,394 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// Embed time zone database since it might be missing in container
+	_ "time/tzdata"
+
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+const (
+	inputTimeZone   = "timezone"
+	inputQuietHours = "quiet_hours"
+
+	quietHoursOff = "off"
+
+	heldUpdatesCheckPeriod = time.Minute
+)
+
+var (
+	utcOffsetRegexp  = regexp.MustCompile(`^(?:UTC|GMT)?\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+	quietHoursRegexp = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*-\s*(\d{1,2})(?::(\d{2}))?$`)
+)
+
+// onSettings handles "/settings" command
+func (s *botService) onSettings(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onSettingsCore)
+}
+
+// onSettingsCore handles "/settings" command (without error handling)
+func (s *botService) onSettingsCore(_ context.Context, _ interface{}, chat *chatEntity) error {
+	chat.Input = ""
+	err := s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.SettingsScreen(chat, nil)
+}
+
+// onText handles text messages
+func (s *botService) onText(m *telebot.Message) {
+	s.Logger.Printf("got message \"%s\" from %d @%s", m.Text, m.Sender.ID, m.Sender.Username)
+	s.handle(m, m.Chat, m.Sender, s.onTextCore)
+}
+
+// onTextCore handles text messages (without error handling)
+func (s *botService) onTextCore(ctx context.Context, arg interface{}, chat *chatEntity) error {
+	m := arg.(*telebot.Message)
+
+	switch chat.Input {
+	case inputTimeZone:
+		return s.setTimeZone(chat, m.Text, nil)
+	case inputQuietHours:
+		return s.setQuietHours(chat, m.Text, nil)
+	}
+
+	if strings.HasPrefix(chat.Input, inputDigestTime+":") {
+		return s.onDigestTimeInput(ctx, chat, m.Text)
+	}
+
+	// Any other text is treated as a search query
+	return s.search(ctx, chat, m.Text)
+}
+
+// onCallbackSettings handles "settings" callbacks
+func (s *botService) onCallbackSettings(_ context.Context, c *telebot.Callback, _ *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	chat.Input = ""
+	err := s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.SettingsScreen(chat, c.Message)
+}
+
+// onCallbackTimeZone handles "tz" callbacks
+func (s *botService) onCallbackTimeZone(_ context.Context, c *telebot.Callback, _ *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	chat.Input = inputTimeZone
+	err := s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.TimeZoneScreen(chat, c.Message)
+}
+
+// onCallbackSetTimeZone handles "set_tz" callbacks
+func (s *botService) onCallbackSetTimeZone(_ context.Context, c *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	return s.setTimeZone(chat, d.Arg, c.Message)
+}
+
+// onCallbackQuietHours handles "quiet" callbacks
+func (s *botService) onCallbackQuietHours(_ context.Context, c *telebot.Callback, _ *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	chat.Input = inputQuietHours
+	err := s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.QuietHoursScreen(chat, c.Message)
+}
+
+// onCallbackSetQuietHours handles "set_quiet" callbacks
+func (s *botService) onCallbackSetQuietHours(_ context.Context, c *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	return s.setQuietHours(chat, d.Arg, c.Message)
+}
+
+// onCallbackScale handles "scale" callbacks
+func (s *botService) onCallbackScale(_ context.Context, c *telebot.Callback, _ *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	chat.Input = ""
+	err := s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.ScaleScreen(chat, c.Message)
+}
+
+// onCallbackSetScale handles "set_scale" callbacks
+func (s *botService) onCallbackSetScale(_ context.Context, c *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	return s.setScale(chat, d.Arg, c.Message)
+}
+
+// onCallbackProfile handles "profile" callbacks
+func (s *botService) onCallbackProfile(_ context.Context, c *telebot.Callback, _ *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	chat.Input = ""
+	err := s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.ProfileScreen(chat, c.Message)
+}
+
+// onCallbackSetProfile handles "set_profile" callbacks
+func (s *botService) onCallbackSetProfile(_ context.Context, c *telebot.Callback, d *callbackJSON, _ telebot.Recipient, chat *chatEntity) error {
+	return s.setProfile(chat, d.Arg, c.Message)
+}
+
+// setTimeZone parses and stores chat's time zone
+func (s *botService) setTimeZone(chat *chatEntity, str string, message telebot.Editable) error {
+	_, name, err := parseTimeZone(str)
+	if err != nil {
+		return s.Screens.InvalidInputScreen(chat,
+			"Unknown time zone. Send me a time zone name (e.g. <code>Europe/Berlin</code>) or an UTC offset (e.g. <code>UTC+3</code>).")
+	}
+
+	chat.TimeZone = name
+	chat.Input = ""
+	err = s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	// Daily digests are scheduled in chat's local time
+	err = s.rescheduleDigests(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.SettingsScreen(chat, message)
+}
+
+// setQuietHours parses and stores chat's quiet hours
+func (s *botService) setQuietHours(chat *chatEntity, str string, message telebot.Editable) error {
+	from, to, err := parseQuietHours(str)
+	if err != nil {
+		return s.Screens.InvalidInputScreen(chat,
+			"Malformed quiet hours. Send me quiet hours as <code>HH:MM-HH:MM</code> (e.g. <code>22:30-07:00</code>) or <code>off</code>.")
+	}
+
+	chat.QuietHoursFrom = from
+	chat.QuietHoursTo = to
+	chat.Input = ""
+	err = s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.SettingsScreen(chat, message)
+}
+
+// setScale parses and stores chat's AQI scale
+func (s *botService) setScale(chat *chatEntity, str string, message telebot.Editable) error {
+	standard, err := waqi.ParseStandard(str)
+	if err != nil {
+		return s.Screens.InvalidInputScreen(chat, "Unknown AQI scale.")
+	}
+
+	if standard == chat.Standard() {
+		return s.Screens.SettingsScreen(chat, message)
+	}
+
+	chat.Scale = string(standard)
+	chat.Input = ""
+	err = s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	// Alert rule states are stored in previous scale and would trigger spurious alerts
+	subscriptions, err := s.DB.GetSubscriptions(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription.RuleState != "" {
+			subscription.RuleState = ""
+			err = s.DB.UpdateRuleState(subscription)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.Screens.SettingsScreen(chat, message)
+}
+
+// setProfile parses and stores chat's health advice audience
+func (s *botService) setProfile(chat *chatEntity, str string, message telebot.Editable) error {
+	audience, err := waqi.ParseAudience(str)
+	if err != nil {
+		return s.Screens.InvalidInputScreen(chat, "Unknown health profile.")
+	}
+
+	chat.Profile = string(audience)
+	chat.Input = ""
+	err = s.DB.Update(chat)
+	if err != nil {
+		return err
+	}
+
+	return s.Screens.SettingsScreen(chat, message)
+}
+
+// HeldUpdatesLoop periodically delivers updates held during quiet hours
+func (s *botService) HeldUpdatesLoop(ticker *time.Ticker, done chan bool) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.DeliverHeldUpdates()
+		}
+	}
+}
+
+// DeliverHeldUpdates delivers updates held during quiet hours to chats whose quiet hours have ended
+func (s *botService) DeliverHeldUpdates() {
+	chats, err := s.DB.GetChatsWithHeldUpdates()
+	if err != nil {
+		s.Logger.Printf("unable to fetch held updates: %s", err)
+		return
+	}
+
+	now := time.Now()
+	for _, chat := range chats {
+		if chat.IsQuietTime(now) {
+			continue
+		}
+
+		err = s.deliverHeldUpdatesToChat(s.Context, chat)
+		if err != nil {
+			s.Logger.Printf("unable to deliver held updates to %d: %s", chat.ChatID, err)
+		}
+	}
+}
+
+// deliverHeldUpdatesToChat sends all updates held for a chat as one combined message
+func (s *botService) deliverHeldUpdatesToChat(ctx context.Context, chat *chatEntity) error {
+	updates, err := s.DB.GetHeldUpdates(chat.ChatID)
+	if err != nil {
+		return err
+	}
+
+	statuses := make(map[int]*waqi.Status)
+	for _, update := range updates {
+		status, err := s.WAQI.GetByStation(ctx, update.StationID)
+		if err != nil {
+			s.Logger.Printf("unable to query status for station #%d: %s", update.StationID, err)
+			continue
+		}
+
+		statuses[update.StationID] = status
+	}
+
+	err = s.Screens.HeldUpdatesScreen(chat, updates, statuses)
+	if err != nil {
+		return err
+	}
+
+	return s.DB.DeleteHeldUpdates(updates)
+}
+
+// parseTimeZone parses either a time zone name or an UTC offset
+// Returns a parsed location and its normalized name
+func parseTimeZone(str string) (*time.Location, string, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return time.UTC, time.UTC.String(), nil
+	}
+
+	loc, err := time.LoadLocation(str)
+	if err == nil {
+		return loc, loc.String(), nil
+	}
+
+	match := utcOffsetRegexp.FindStringSubmatch(strings.ToUpper(str))
+	if match == nil {
+		return nil, "", err
+	}
+
+	hours, _ := strconv.Atoi(match[2])
+	minutes := 0
+	if match[3] != "" {
+		minutes, _ = strconv.Atoi(match[3])
+	}
+	if hours > 14 || minutes > 59 {
+		return nil, "", fmt.Errorf("malformed UTC offset: \"%s\"", str)
+	}
+
+	offset := hours*3600 + minutes*60
+	if match[1] == "-" {
+		offset = -offset
+	}
+
+	name := fmt.Sprintf("UTC%s%02d:%02d", match[1], hours, minutes)
+	return time.FixedZone(name, offset), name, nil
+}
+
+// parseQuietHours parses quiet hours in "HH:MM-HH:MM" format
+// Returns beginning and ending of quiet hours as minutes since midnight
+// Returns equal values if quiet hours are turned off
+func parseQuietHours(str string) (int, int, error) {
+	str = strings.TrimSpace(strings.ToLower(str))
+	if str == quietHoursOff {
+		return 0, 0, nil
+	}
+
+	str = strings.ReplaceAll(str, "–", "-")
+	match := quietHoursRegexp.FindStringSubmatch(str)
+	if match == nil {
+		return 0, 0, fmt.Errorf("malformed quiet hours: \"%s\"", str)
+	}
+
+	from, err := parseTimeOfDay(match[1], match[2])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to, err := parseTimeOfDay(match[3], match[4])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return from, to, nil
+}
+
+// parseTimeOfDay converts hours and minutes into minutes since midnight
+func parseTimeOfDay(hoursStr, minutesStr string) (int, error) {
+	hours, err := strconv.Atoi(hoursStr)
+	if err != nil {
+		return 0, err
+	}
+
+	minutes := 0
+	if minutesStr != "" {
+		minutes, err = strconv.Atoi(minutesStr)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if hours > 24 || minutes > 59 || (hours == 24 && minutes > 0) {
+		return 0, fmt.Errorf("malformed time of day: \"%s:%s\"", hoursStr, minutesStr)
+	}
+
+	return (hours*60 + minutes) % (24 * 60), nil
+}
+
+// formatQuietHours converts quiet hours into "HH:MM-HH:MM" format
+func formatQuietHours(from, to int) string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", from/60, from%60, to/60, to%60)
+}
@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeZone(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		offset   int
+		hasError bool
+	}{
+		{"", "UTC", 0, false},
+		{"Europe/Berlin", "Europe/Berlin", 60 * 60, false},
+		{"UTC+3", "UTC+03:00", 3 * 60 * 60, false},
+		{"utc-5:30", "UTC-05:30", -(5*60 + 30) * 60, false},
+		{"GMT+0545", "UTC+05:45", (5*60 + 45) * 60, false},
+		{" +2 ", "UTC+02:00", 2 * 60 * 60, false},
+		{"UTC+15", "", 0, true},
+		{"UTC+3:75", "", 0, true},
+		{"Mars/Olympus", "", 0, true},
+	}
+
+	// Berlin is UTC+1 in winter
+	winter := time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			a := assert.New(t)
+			loc, name, err := parseTimeZone(test.input)
+			if test.hasError {
+				a.NotNil(err)
+				return
+			}
+
+			a.Nil(err)
+			a.Equal(test.name, name)
+			_, offset := winter.In(loc).Zone()
+			a.Equal(test.offset, offset)
+		})
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		input    string
+		from     int
+		to       int
+		hasError bool
+	}{
+		{"off", 0, 0, false},
+		{" OFF ", 0, 0, false},
+		{"22:00-07:00", 22 * 60, 7 * 60, false},
+		{"22:30 - 7:15", 22*60 + 30, 7*60 + 15, false},
+		{"23–8", 23 * 60, 8 * 60, false},
+		{"0-24", 0, 0, false},
+		{"22:00", 0, 0, true},
+		{"25:00-07:00", 0, 0, true},
+		{"22:60-07:00", 0, 0, true},
+		{"24:30-07:00", 0, 0, true},
+		{"night", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			a := assert.New(t)
+			from, to, err := parseQuietHours(test.input)
+			if test.hasError {
+				a.NotNil(err)
+				return
+			}
+
+			a.Nil(err)
+			a.Equal(test.from, from)
+			a.Equal(test.to, to)
+		})
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	tests := []struct {
+		hours    string
+		minutes  string
+		expected int
+		hasError bool
+	}{
+		{"8", "", 8 * 60, false},
+		{"08", "30", 8*60 + 30, false},
+		{"0", "00", 0, false},
+		{"23", "59", 23*60 + 59, false},
+		{"24", "", 0, false},
+		{"24", "01", 0, true},
+		{"25", "", 0, true},
+		{"12", "60", 0, true},
+		{"", "", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.hours+":"+test.minutes, func(t *testing.T) {
+			a := assert.New(t)
+			minutes, err := parseTimeOfDay(test.hours, test.minutes)
+			if test.hasError {
+				a.NotNil(err)
+				return
+			}
+
+			a.Nil(err)
+			a.Equal(test.expected, minutes)
+		})
+	}
+}
+
+func TestIsQuietTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     int
+		to       int
+		time     string
+		expected bool
+	}{
+		{"off", 0, 0, "03:00", false},
+		{"within same day", 13 * 60, 15 * 60, "14:00", true},
+		{"before same day", 13 * 60, 15 * 60, "12:59", false},
+		{"end of same day", 13 * 60, 15 * 60, "15:00", false},
+		{"start before midnight", 22 * 60, 7 * 60, "22:00", true},
+		{"before midnight", 22 * 60, 7 * 60, "23:59", true},
+		{"midnight", 22 * 60, 7 * 60, "00:00", true},
+		{"after midnight", 22 * 60, 7 * 60, "06:59", true},
+		{"end after midnight", 22 * 60, 7 * 60, "07:00", false},
+		{"daytime", 22 * 60, 7 * 60, "12:00", false},
+		{"evening", 22 * 60, 7 * 60, "21:59", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock, err := time.Parse("15:04", test.time)
+			assert.Nil(t, err)
+
+			chat := chatEntity{QuietHoursFrom: test.from, QuietHoursTo: test.to}
+			now := time.Date(2021, 1, 10, clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+			assert.Equal(t, test.expected, chat.IsQuietTime(now))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
@@ -138,6 +139,8 @@ func NewBot(fn ...Option) (Bot, error) {
 		Subscriptions:      make(map[int]int),
 		Screens:            &botScreens{tgBot, opts.Logger},
 		Logger:             opts.Logger,
+		HeldUpdatesDone:    make(chan bool),
 	}
+	bot.Context, bot.Cancel = context.WithCancel(context.Background())
 	return bot, nil
 }
@@ -1,24 +1,34 @@
 package waqi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 // adapter is an adapter for WAQI service
 type adapter interface {
 	// GetByCity fetches current measurements for city
-	GetByCity(city string) (*Status, error)
+	GetByCity(ctx context.Context, city string) (*Status, error)
 
 	// GetByStation fetches current measurements for station
-	GetByStation(stationID int) (*Status, error)
+	GetByStation(ctx context.Context, stationID int) (*Status, error)
 
 	// GetByGeo fetches current measurements for geo coordinates
-	GetByGeo(lat, lon float32) (*Status, error)
+	GetByGeo(ctx context.Context, lat, lon float32) (*Status, error)
+
+	// Search looks up stations by a keyword
+	Search(ctx context.Context, keyword string) ([]*StationSummary, error)
+
+	// GetByBounds returns stations located within specified rectangle
+	GetByBounds(ctx context.Context, bounds Bounds) ([]*StationSummary, error)
 
 	// Close shuts down adapter
 	Close() error
@@ -28,64 +38,185 @@ type serviceAdapter struct {
 	url    string
 	logger *log.Logger
 	token  string
+	client *http.Client
 }
 
-func newServiceAdapter(url, token string, logger *log.Logger) adapter {
-	return &serviceAdapter{url, logger, token}
+func newServiceAdapter(url, token string, client *http.Client, logger *log.Logger) adapter {
+	return &serviceAdapter{url, logger, token, client}
+}
+
+// newHTTPClient creates an HTTP client with bounded connect, handshake and response timeouts
+func newHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = 5 * time.Second
+	transport.ResponseHeaderTimeout = timeout
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
 }
 
 // GetByCity fetches current measurements for city
-func (s *serviceAdapter) GetByCity(city string) (*Status, error) {
-	path := fmt.Sprintf("feed/%s/", city)
-	return s.Get(path)
+func (s *serviceAdapter) GetByCity(ctx context.Context, city string) (*Status, error) {
+	path := fmt.Sprintf("feed/%s/", url.PathEscape(city))
+	return s.Get(ctx, path)
 }
 
 // GetByStation fetches current measurements for station
-func (s *serviceAdapter) GetByStation(stationID int) (*Status, error) {
+func (s *serviceAdapter) GetByStation(ctx context.Context, stationID int) (*Status, error) {
 	path := fmt.Sprintf("feed/@%d/", stationID)
-	return s.Get(path)
+	return s.Get(ctx, path)
 }
 
 // GetByGeo fetches current measurements for geo coordinates
-func (s *serviceAdapter) GetByGeo(lat, lon float32) (*Status, error) {
+func (s *serviceAdapter) GetByGeo(ctx context.Context, lat, lon float32) (*Status, error) {
 	path := fmt.Sprintf("feed/geo:%f;%f/", lat, lon)
-	return s.Get(path)
+	return s.Get(ctx, path)
+}
+
+// Search looks up stations by a keyword
+func (s *serviceAdapter) Search(ctx context.Context, keyword string) ([]*StationSummary, error) {
+	var data []*searchResultJSON
+	err := s.Fetch(ctx, "search/", url.Values{"keyword": {keyword}}, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*StationSummary, 0, len(data))
+	for _, item := range data {
+		if item.Station == nil {
+			continue
+		}
+
+		results = append(results, item.ToStationSummary())
+	}
+
+	return results, nil
+}
+
+// GetByBounds returns stations located within specified rectangle
+func (s *serviceAdapter) GetByBounds(ctx context.Context, bounds Bounds) ([]*StationSummary, error) {
+	latlng := fmt.Sprintf("%f,%f,%f,%f", bounds.MinLat, bounds.MinLon, bounds.MaxLat, bounds.MaxLon)
+	var data []*boundsResultJSON
+	err := s.Fetch(ctx, "map/bounds/", url.Values{"latlng": {latlng}}, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*StationSummary, 0, len(data))
+	for _, item := range data {
+		results = append(results, item.ToStationSummary())
+	}
+
+	return results, nil
 }
 
 // Get fetches current measurements by a relative URL
-func (s *serviceAdapter) Get(path string) (*Status, error) {
-	u := fmt.Sprintf("%s/%s?token=%s", s.url, path, url.QueryEscape(s.token))
-	resp, err := http.Get(u)
+func (s *serviceAdapter) Get(ctx context.Context, path string) (*Status, error) {
+	var data dataJSON
+	err := s.Fetch(ctx, path, nil, &data)
 	if err != nil {
-		s.logger.Printf("GET %s failed: %s", u, err)
 		return nil, err
 	}
+
+	raw := responseJSON{Status: responseStatusOK, Data: &data}
+	return raw.ToStatus(), nil
+}
+
+// Fetch performs a request by a relative URL and decodes "data" node of the response
+func (s *serviceAdapter) Fetch(ctx context.Context, path string, params url.Values, data interface{}) error {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("token", s.token)
+
+	u := fmt.Sprintf("%s/%s?%s", s.url, path, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Printf("GET %s failed: %s", path, err)
+		return err
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		s.logger.Printf("GET %s -> %d", u, resp.StatusCode)
-		return nil, Error("server returned non-successful response")
+		s.logger.Printf("GET %s -> %d", path, resp.StatusCode)
+		return &responseError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
 	buffer, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("GET %s failed: %s", u, err)
-		return nil, err
+		s.logger.Printf("GET %s failed: %s", path, err)
+		return err
 	}
 
-	var raw responseJSON
+	var raw envelopeJSON
 	err = json.Unmarshal(buffer, &raw)
 	if err != nil {
-		s.logger.Printf("GET %s failed: %s", u, err)
-		return nil, err
+		s.logger.Printf("GET %s failed: %s", path, err)
+		return err
 	}
 
-	if raw.Status == responseStatusError {
-		s.logger.Printf("GET %s -> %d: %s", u, resp.StatusCode, raw.Message)
-		return nil, Error(fmt.Sprintf("server error: %s", raw.Message))
+	if raw.Status != responseStatusOK {
+		message := raw.ErrorMessage()
+		s.logger.Printf("GET %s -> %d: %s", path, resp.StatusCode, message)
+		return Error(fmt.Sprintf("server error: %s", message))
 	}
 
-	return raw.ToStatus(), nil
+	err = json.Unmarshal(raw.Data, data)
+	if err != nil {
+		s.logger.Printf("GET %s failed: %s", path, err)
+		return err
+	}
+
+	return nil
+}
+
+// responseError is returned when server responds with non-successful HTTP status code
+type responseError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+// Error returns error message
+func (e *responseError) Error() string {
+	return fmt.Sprintf("server returned non-successful response (%d)", e.StatusCode)
+}
+
+// parseRetryAfter parses "Retry-After" header value (either delay in seconds or HTTP date)
+// Returns zero if header is missing or malformed
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil || t.Before(now) {
+		return 0
+	}
+
+	return t.Sub(now)
 }
 
 // Close shuts down adapter
@@ -0,0 +1,172 @@
+package waqi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceAdapterSearch(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/search/", r.URL.Path)
+		a.Equal("new york", r.URL.Query().Get("keyword"))
+		a.Equal("secret", r.URL.Query().Get("token"))
+
+		_, _ = fmt.Fprint(w, `{"status":"ok","data":[
+			{"uid":3307,"aqi":"42","time":{"tz":"-04:00","stime":"2021-05-01 10:00:00","vtime":1619877600},
+			 "station":{"name":"New York, USA","geo":[40.7,-74.0],"url":"usa/newyork"}},
+			{"uid":3308,"aqi":"-","time":{"vtime":0},"station":{"name":"Queens, New York, USA","geo":[40.7,-73.8]}}
+		]}`)
+	}))
+	defer server.Close()
+
+	adapter := newServiceAdapter(server.URL, "secret", server.Client(), log.Default())
+	results, err := adapter.Search(context.Background(), "new york")
+	a.Nil(err)
+	a.Len(results, 2)
+
+	a.Equal(3307, results[0].Station.ID)
+	a.Equal("New York, USA", results[0].Station.Name)
+	a.Equal(float32(40.7), results[0].Station.Lat)
+	a.Equal(float32(-74.0), results[0].Station.Lon)
+	a.Equal(float32(42), *results[0].AQI)
+	a.Equal(GoodLevel, results[0].Level)
+	a.Equal(int64(1619877600), results[0].Time.Unix())
+
+	// Stations without recent data have no AQI
+	a.Equal(3308, results[1].Station.ID)
+	a.Nil(results[1].AQI)
+	a.Equal(Level(""), results[1].Level)
+	a.True(results[1].Time.IsZero())
+}
+
+func TestServiceAdapterGetByBounds(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/map/bounds/", r.URL.Path)
+		a.Equal("55.000000,37.000000,56.000000,38.000000", r.URL.Query().Get("latlng"))
+
+		_, _ = fmt.Fprint(w, `{"status":"ok","data":[
+			{"lat":55.75,"lon":37.62,"uid":6251,"aqi":"75","station":{"name":"Moscow","time":"2021-05-01T10:00:00+03:00"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	adapter := newServiceAdapter(server.URL, "secret", server.Client(), log.Default())
+	results, err := adapter.GetByBounds(context.Background(), Bounds{MinLat: 55, MinLon: 37, MaxLat: 56, MaxLon: 38})
+	a.Nil(err)
+	a.Len(results, 1)
+	a.Equal(6251, results[0].Station.ID)
+	a.Equal("Moscow", results[0].Station.Name)
+	a.Equal(float32(55.75), results[0].Station.Lat)
+	a.Equal(float32(37.62), results[0].Station.Lon)
+	a.Equal(float32(75), *results[0].AQI)
+	a.Equal(ModerateLevel, results[0].Level)
+	a.Equal(int64(1619852400), results[0].Time.Unix())
+}
+
+func TestServiceAdapterGetByStation(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/feed/@6251/", r.URL.Path)
+
+		_, _ = fmt.Fprint(w, `{"status":"ok","data":{
+			"aqi":75,"idx":6251,"dominentpol":"pm25",
+			"attributions":[
+				{"url":"http://www.mosecom.ru/","name":"Mosecomonitoring"},
+				{"url":"https://waqi.info/","name":"World Air Quality Index Project"}
+			],
+			"city":{"geo":[55.75,37.62],"name":"Moscow","url":"https://aqicn.org/city/moscow"},
+			"iaqi":{"pm25":{"v":75},"t":{"v":21.5},"h":{"v":60},"p":{"v":1012},"w":{"v":3.6},"dew":{"v":13}},
+			"time":{"iso":"2021-05-01T10:00:00+03:00"},
+			"forecast":{"daily":{
+				"pm25":[{"avg":70,"day":"2021-05-02","max":89,"min":55},{"avg":64,"day":"2021-05-01","max":76,"min":50}],
+				"pm10":[{"avg":30,"day":"2021-05-01","max":35,"min":21},{"avg":45,"day":"2021-05-02","max":120,"min":28}],
+				"uvi":[{"avg":1,"day":"2021-05-01","max":4,"min":0},{"avg":1,"day":"not a date","max":4,"min":0}]
+			}}
+		}}`)
+	}))
+	defer server.Close()
+
+	adapter := newServiceAdapter(server.URL, "secret", server.Client(), log.Default())
+	status, err := adapter.GetByStation(context.Background(), 6251)
+	a.Nil(err)
+	a.Equal(6251, status.Station.ID)
+	a.Equal(float32(75), *status.PM25)
+	a.Equal(PM25Parameter, status.DominantPollutant)
+	a.Equal(int64(1619852400), status.Time.Unix())
+
+	a.NotNil(status.Weather)
+	a.Equal(float32(21.5), *status.Weather.Temperature)
+	a.Equal(float32(60), *status.Weather.Humidity)
+	a.Equal(float32(1012), *status.Weather.Pressure)
+	a.Equal(float32(3.6), *status.Weather.Wind)
+	a.Nil(status.Weather.WindGust)
+	a.Equal(float32(13), *status.Weather.DewPoint)
+
+	a.Equal([]*Attribution{
+		{Name: "Mosecomonitoring", URL: "http://www.mosecom.ru/"},
+		{Name: "World Air Quality Index Project", URL: "https://waqi.info/"},
+	}, status.Attributions)
+
+	a.NotNil(status.Forecast)
+	a.Len(status.Forecast.Days, 2)
+	a.Equal("2021-05-01", status.Forecast.Days[0].Date)
+	a.Equal(&ForecastValue{Min: 50, Avg: 64, Max: 76}, status.Forecast.Days[0].PM25)
+	a.Equal(&ForecastValue{Min: 0, Avg: 1, Max: 4}, status.Forecast.Days[0].UVI)
+	a.Nil(status.Forecast.Days[0].O3)
+	a.Equal("2021-05-02", status.Forecast.Days[1].Date)
+	a.Equal(float32(120), status.Forecast.Days[1].PM10.Max)
+	a.Nil(status.Forecast.Days[1].UVI)
+}
+
+func TestServiceAdapterError(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"status":"error","data":"Unknown station"}`)
+	}))
+	defer server.Close()
+
+	adapter := newServiceAdapter(server.URL, "secret", server.Client(), log.Default())
+	_, err := adapter.GetByStation(context.Background(), 123)
+	a.Equal(Error("server error: Unknown station"), err)
+}
+
+func TestServiceAdapterCancellation(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	adapter := newServiceAdapter(server.URL, "secret", server.Client(), log.Default())
+
+	// Request should be aborted once its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := adapter.GetByStation(ctx, 123)
+	a.Error(err)
+	a.ErrorIs(err, context.DeadlineExceeded)
+
+	// Request should be aborted by client timeout as well
+	adapter = newServiceAdapter(server.URL, "secret", &http.Client{Timeout: 50 * time.Millisecond}, log.Default())
+	_, err = adapter.GetByStation(context.Background(), 123)
+	a.Error(err)
+}
@@ -0,0 +1,259 @@
+package waqi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Audience is a group of people health advice is addressed to
+type Audience string
+
+const (
+	// GeneralAudience is general public
+	GeneralAudience Audience = "general"
+
+	// ChildrenAudience is children and teenagers
+	ChildrenAudience Audience = "children"
+
+	// ElderlyAudience is older adults
+	ElderlyAudience Audience = "elderly"
+
+	// AsthmaAudience is people with asthma or other lung diseases
+	AsthmaAudience Audience = "asthma"
+
+	// AthletesAudience is people who exercise or work outdoors
+	AthletesAudience Audience = "athletes"
+)
+
+// Audiences contains all known audiences
+var Audiences = []Audience{
+	GeneralAudience,
+	ChildrenAudience,
+	ElderlyAudience,
+	AsthmaAudience,
+	AthletesAudience,
+}
+
+// String converts a value of Audience into string
+func (a Audience) String() string {
+	switch a {
+	case GeneralAudience:
+		return "General public"
+	case ChildrenAudience:
+		return "Children"
+	case ElderlyAudience:
+		return "Older adults"
+	case AsthmaAudience:
+		return "People with asthma"
+	case AthletesAudience:
+		return "Outdoor athletes"
+	default:
+		return string(a)
+	}
+}
+
+// OrDefault returns general audience for an empty value
+func (a Audience) OrDefault() Audience {
+	if a == "" {
+		return GeneralAudience
+	}
+	return a
+}
+
+// ParseAudience parses an audience from its string representation
+func ParseAudience(str string) (Audience, error) {
+	value := strings.ToLower(strings.TrimSpace(str))
+	for _, audience := range Audiences {
+		if value == string(audience) {
+			return audience, nil
+		}
+	}
+
+	return "", Error(fmt.Sprintf("unknown audience: \"%s\"", str))
+}
+
+// adviceKey is a key of health advice catalogue
+// Advice with an empty parameter applies to any pollutant.
+type adviceKey struct {
+	level     Level
+	parameter Parameter
+	audience  Audience
+}
+
+// healthAdvice is a catalogue of health advice
+// Audiences without an entry for a level get advice for general public.
+var healthAdvice = map[adviceKey][]string{
+	// General public
+	{GoodLevel, "", GeneralAudience}: {
+		"Air quality is good, enjoy outdoor activities",
+	},
+	{ModerateLevel, "", GeneralAudience}: {
+		"Air quality is acceptable for most people",
+	},
+	{PossiblyUnhealthyLevel, "", GeneralAudience}: {
+		"Most people can keep up outdoor activities, take it easier if you notice coughing or shortness of breath",
+	},
+	{UnhealthyLevel, "", GeneralAudience}: {
+		"Reduce prolonged or heavy outdoor exertion",
+		"Close windows",
+	},
+	{VeryUnhealthyLevel, "", GeneralAudience}: {
+		"Avoid prolonged or heavy outdoor exertion",
+		"Close windows and run an air purifier if you have one",
+	},
+	{HazardousLevel, "", GeneralAudience}: {
+		"Avoid all physical activity outdoors",
+		"Stay indoors with windows closed",
+	},
+
+	// Children
+	{PossiblyUnhealthyLevel, "", ChildrenAudience}: {
+		"Keep outdoor play shorter and take more breaks",
+	},
+	{UnhealthyLevel, "", ChildrenAudience}: {
+		"Keep outdoor play short and light, move sports indoors",
+		"Close windows",
+	},
+	{VeryUnhealthyLevel, "", ChildrenAudience}: {
+		"Keep children indoors and move all activities inside",
+		"Close windows",
+	},
+	{HazardousLevel, "", ChildrenAudience}: {
+		"Keep children indoors with windows closed",
+	},
+
+	// Older adults
+	{PossiblyUnhealthyLevel, "", ElderlyAudience}: {
+		"Reduce prolonged or heavy outdoor exertion",
+	},
+	{UnhealthyLevel, "", ElderlyAudience}: {
+		"Avoid prolonged or heavy outdoor exertion and postpone errands if you can",
+		"Close windows",
+	},
+	{VeryUnhealthyLevel, "", ElderlyAudience}: {
+		"Stay indoors and keep activity levels low",
+		"Close windows and run an air purifier if you have one",
+	},
+	{HazardousLevel, "", ElderlyAudience}: {
+		"Stay indoors with windows closed and keep activity levels low",
+		"Contact a doctor if you feel chest pain or shortness of breath",
+	},
+
+	// People with asthma
+	{ModerateLevel, "", AsthmaAudience}: {
+		"Air quality is acceptable, keep your quick-relief inhaler at hand",
+	},
+	{PossiblyUnhealthyLevel, "", AsthmaAudience}: {
+		"Reduce outdoor exertion and keep your quick-relief inhaler with you",
+	},
+	{UnhealthyLevel, "", AsthmaAudience}: {
+		"Avoid outdoor exertion and follow your asthma action plan",
+		"Close windows",
+	},
+	{VeryUnhealthyLevel, "", AsthmaAudience}: {
+		"Stay indoors and follow your asthma action plan",
+		"Close windows and run an air purifier if you have one",
+	},
+	{HazardousLevel, "", AsthmaAudience}: {
+		"Stay indoors with windows closed and follow your asthma action plan",
+		"Seek medical help if symptoms get worse",
+	},
+
+	// Outdoor athletes
+	{ModerateLevel, "", AthletesAudience}: {
+		"Air quality is acceptable, choose lighter workouts if you feel unusual symptoms",
+	},
+	{PossiblyUnhealthyLevel, "", AthletesAudience}: {
+		"Shorten outdoor workouts and take more breaks",
+	},
+	{UnhealthyLevel, "", AthletesAudience}: {
+		"Postpone outdoor exercise or move it indoors",
+	},
+	{VeryUnhealthyLevel, "", AthletesAudience}: {
+		"Postpone outdoor exercise and train indoors",
+	},
+	{HazardousLevel, "", AthletesAudience}: {
+		"Cancel outdoor training and competitions",
+	},
+
+	// Particulate matter
+	{VeryUnhealthyLevel, PM25Parameter, GeneralAudience}: {
+		"Wear a well-fitting N95 or FFP2 respirator if you have to go out",
+	},
+	{HazardousLevel, PM25Parameter, GeneralAudience}: {
+		"Wear a well-fitting N95 or FFP2 respirator if you have to go out",
+	},
+	{VeryUnhealthyLevel, PM10Parameter, GeneralAudience}: {
+		"Wear a well-fitting N95 or FFP2 respirator if you have to go out",
+	},
+	{HazardousLevel, PM10Parameter, GeneralAudience}: {
+		"Wear a well-fitting N95 or FFP2 respirator if you have to go out",
+	},
+
+	// Ozone peaks in the afternoon and doesn't build up indoors
+	{PossiblyUnhealthyLevel, O3Parameter, GeneralAudience}: {
+		"Ozone is lower in the morning, plan outdoor activities before noon",
+	},
+	{UnhealthyLevel, O3Parameter, GeneralAudience}: {
+		"Ozone is lower in the morning, plan outdoor activities before noon",
+	},
+	{VeryUnhealthyLevel, O3Parameter, GeneralAudience}: {
+		"Ozone is lower in the morning, plan unavoidable outdoor activities before noon",
+	},
+	{PossiblyUnhealthyLevel, O3Parameter, AthletesAudience}: {
+		"Ozone is lower in the morning, train early",
+	},
+	{UnhealthyLevel, O3Parameter, AthletesAudience}: {
+		"Ozone is lower in the morning, postpone training to early hours",
+	},
+
+	// Traffic pollutants
+	{PossiblyUnhealthyLevel, NO2Parameter, GeneralAudience}: {
+		"Stay away from busy roads",
+	},
+	{UnhealthyLevel, NO2Parameter, GeneralAudience}: {
+		"Stay away from busy roads",
+	},
+	{VeryUnhealthyLevel, NO2Parameter, GeneralAudience}: {
+		"Stay away from busy roads",
+	},
+	{PossiblyUnhealthyLevel, COParameter, GeneralAudience}: {
+		"Stay away from heavy traffic",
+	},
+	{UnhealthyLevel, COParameter, GeneralAudience}: {
+		"Stay away from heavy traffic and check that gas appliances are vented properly",
+	},
+	{VeryUnhealthyLevel, COParameter, GeneralAudience}: {
+		"Stay away from heavy traffic and check that gas appliances are vented properly",
+	},
+
+	// Sulfur dioxide mostly affects people with asthma
+	{PossiblyUnhealthyLevel, SO2Parameter, AsthmaAudience}: {
+		"Sulfur dioxide can trigger asthma symptoms even after a short exposure, avoid areas near industrial sources",
+	},
+	{UnhealthyLevel, SO2Parameter, AsthmaAudience}: {
+		"Sulfur dioxide can trigger asthma symptoms even after a short exposure, avoid areas near industrial sources",
+	},
+}
+
+// HealthAdvice returns health advice for an air quality level, a dominant pollutant and an audience
+// Advice for any pollutant comes first and is followed by pollutant-specific advice.
+// Audience-specific advice takes priority, otherwise advice for general public is returned.
+func HealthAdvice(level Level, parameter Parameter, audience Audience) []string {
+	audience = audience.OrDefault()
+	lookup := func(parameter Parameter) []string {
+		advice, exists := healthAdvice[adviceKey{level, parameter, audience}]
+		if !exists {
+			advice = healthAdvice[adviceKey{level, parameter, GeneralAudience}]
+		}
+		return advice
+	}
+
+	result := make([]string, 0)
+	result = append(result, lookup("")...)
+	if parameter != "" && parameter != AQIParameter {
+		result = append(result, lookup(parameter)...)
+	}
+
+	return result
+}
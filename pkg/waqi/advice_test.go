@@ -0,0 +1,43 @@
+package waqi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthAdvice(t *testing.T) {
+	a := assert.New(t)
+
+	// Every level should have advice for general public
+	for _, level := range []Level{GoodLevel, ModerateLevel, PossiblyUnhealthyLevel, UnhealthyLevel, VeryUnhealthyLevel, HazardousLevel} {
+		a.NotEmpty(HealthAdvice(level, "", GeneralAudience), level.String())
+	}
+
+	// Audience-specific advice should take priority
+	a.Equal([]string{"Postpone outdoor exercise or move it indoors"}, HealthAdvice(UnhealthyLevel, "", AthletesAudience))
+
+	// Audiences without specific advice should get advice for general public
+	a.Equal(HealthAdvice(GoodLevel, "", GeneralAudience), HealthAdvice(GoodLevel, "", ChildrenAudience))
+	a.Equal(HealthAdvice(ModerateLevel, "", GeneralAudience), HealthAdvice(ModerateLevel, "", ""))
+
+	// Pollutant-specific advice should follow general one
+	advice := HealthAdvice(VeryUnhealthyLevel, PM25Parameter, GeneralAudience)
+	a.Equal([]string{
+		"Avoid prolonged or heavy outdoor exertion",
+		"Close windows and run an air purifier if you have one",
+		"Wear a well-fitting N95 or FFP2 respirator if you have to go out",
+	}, advice)
+
+	advice = HealthAdvice(UnhealthyLevel, O3Parameter, AthletesAudience)
+	a.Equal([]string{
+		"Postpone outdoor exercise or move it indoors",
+		"Ozone is lower in the morning, postpone training to early hours",
+	}, advice)
+
+	audience, err := ParseAudience(" Asthma ")
+	a.Nil(err)
+	a.Equal(AsthmaAudience, audience)
+	_, err = ParseAudience("pets")
+	a.EqualError(err, "unknown audience: \"pets\"")
+}
@@ -0,0 +1,285 @@
+package waqi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultLevelHysteresis is default hysteresis (in AQI points) for level change alert rules
+	DefaultLevelHysteresis = 5
+
+	// DefaultThresholdHysteresis is default hysteresis (as a fraction of threshold) for threshold alert rules
+	DefaultThresholdHysteresis = 0.1
+)
+
+// AlertRuleKind is a kind of alert rule
+type AlertRuleKind string
+
+const (
+	// LevelChangeAlertRule triggers an alert on any air quality level change
+	LevelChangeAlertRule AlertRuleKind = "level"
+
+	// AboveAlertRule triggers an alert when a parameter rises above a threshold
+	AboveAlertRule AlertRuleKind = ">"
+
+	// CrossAlertRule triggers an alert when a parameter crosses a threshold in either direction
+	CrossAlertRule AlertRuleKind = "<>"
+)
+
+// AlertEvent is a result of alert rule evaluation
+type AlertEvent int
+
+const (
+	// NoAlertEvent means that an alert should not be triggered
+	NoAlertEvent AlertEvent = iota
+
+	// LevelChangedAlertEvent means that air quality level has changed
+	LevelChangedAlertEvent
+
+	// RaisedAlertEvent means that a parameter has risen above a threshold
+	RaisedAlertEvent
+
+	// ClearedAlertEvent means that a parameter has dropped below a threshold
+	ClearedAlertEvent
+)
+
+const (
+	alertStateAbove = "above"
+	alertStateBelow = "below"
+)
+
+// AlertRule defines when subscribers should be notified about air quality changes
+type AlertRule struct {
+	// Rule kind
+	Kind AlertRuleKind
+
+	// Parameter to watch (not used by LevelChangeAlertRule)
+	Parameter Parameter
+
+	// Threshold value (not used by LevelChangeAlertRule)
+	Threshold float32
+
+	// Hysteresis value which prevents alerts from bouncing around a boundary
+	Hysteresis float32
+}
+
+// DefaultAlertRule returns an alert rule that is used by default
+func DefaultAlertRule() *AlertRule {
+	return &AlertRule{
+		Kind:       LevelChangeAlertRule,
+		Hysteresis: DefaultLevelHysteresis,
+	}
+}
+
+// ParseAlertRule parses an alert rule from its string representation
+// Supported formats are "level", "<parameter>><threshold>" and "<parameter><><threshold>",
+// optionally followed by "~<hysteresis>", e.g. "aqi<>100" or "pm25>35~2"
+// An empty string is parsed as a default alert rule
+func ParseAlertRule(str string) (*AlertRule, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if str == "" {
+		return DefaultAlertRule(), nil
+	}
+
+	var hysteresis *float32
+	if i := strings.Index(str, "~"); i >= 0 {
+		value, err := strconv.ParseFloat(str[i+1:], 32)
+		if err != nil || value < 0 {
+			return nil, Error(fmt.Sprintf("malformed alert rule hysteresis: \"%s\"", str))
+		}
+		h := float32(value)
+		hysteresis = &h
+		str = str[:i]
+	}
+
+	var rule *AlertRule
+	if str == string(LevelChangeAlertRule) {
+		rule = DefaultAlertRule()
+	} else {
+		kind := AboveAlertRule
+		i := strings.Index(str, string(CrossAlertRule))
+		if i >= 0 {
+			kind = CrossAlertRule
+		} else {
+			i = strings.Index(str, string(AboveAlertRule))
+		}
+		if i <= 0 {
+			return nil, Error(fmt.Sprintf("malformed alert rule: \"%s\"", str))
+		}
+
+		parameter := Parameter(str[:i])
+		if !isKnownParameter(parameter) {
+			return nil, Error(fmt.Sprintf("unknown alert rule parameter: \"%s\"", parameter))
+		}
+
+		threshold, err := strconv.ParseFloat(str[i+len(kind):], 32)
+		if err != nil {
+			return nil, Error(fmt.Sprintf("malformed alert rule threshold: \"%s\"", str))
+		}
+
+		rule = &AlertRule{
+			Kind:       kind,
+			Parameter:  parameter,
+			Threshold:  float32(threshold),
+			Hysteresis: float32(threshold) * DefaultThresholdHysteresis,
+		}
+	}
+
+	if hysteresis != nil {
+		rule.Hysteresis = *hysteresis
+	}
+
+	return rule, nil
+}
+
+// String converts an alert rule into its string representation
+func (r *AlertRule) String() string {
+	var str string
+	var defaultHysteresis float32
+	if r.Kind == LevelChangeAlertRule {
+		str = string(LevelChangeAlertRule)
+		defaultHysteresis = DefaultLevelHysteresis
+	} else {
+		str = fmt.Sprintf("%s%s%s", string(r.Parameter), r.Kind, formatAlertValue(r.Threshold))
+		defaultHysteresis = r.Threshold * DefaultThresholdHysteresis
+	}
+
+	if r.Hysteresis != defaultHysteresis {
+		str += "~" + formatAlertValue(r.Hysteresis)
+	}
+
+	return str
+}
+
+// Describe returns a human-readable description of an alert rule
+func (r *AlertRule) Describe() string {
+	switch r.Kind {
+	case LevelChangeAlertRule:
+		return "any level change"
+	case AboveAlertRule:
+		return fmt.Sprintf("%s above %s", r.Parameter, formatAlertValue(r.Threshold))
+	case CrossAlertRule:
+		return fmt.Sprintf("%s crosses %s", r.Parameter, formatAlertValue(r.Threshold))
+	default:
+		return r.String()
+	}
+}
+
+// Evaluate checks a new status against an alert rule
+// Levels and thresholds are evaluated in the standard of status (see Standard.Convert)
+// State is an opaque value returned from a previous evaluation (or an empty string on first evaluation)
+// Returns an alert event and a new state value that should be stored and passed to the next evaluation
+// The first evaluation never triggers an alert, it only captures an initial state
+func (r *AlertRule) Evaluate(status *Status, state string) (AlertEvent, string) {
+	if r.Kind == LevelChangeAlertRule {
+		return r.evaluateLevelChange(status, state)
+	}
+
+	return r.evaluateThreshold(status, state)
+}
+
+func (r *AlertRule) evaluateLevelChange(status *Status, state string) (AlertEvent, string) {
+	standard := status.Standard.OrDefault()
+	level := standard.CalcLevel(status.AQI)
+	prevLevel := Level(state)
+	if state == "" || prevLevel.Ordinal() < 0 {
+		return NoAlertEvent, string(level)
+	}
+
+	if level == prevLevel {
+		return NoAlertEvent, state
+	}
+
+	// Level change is confirmed only if value has moved past the boundary by hysteresis value
+	if level.Ordinal() > prevLevel.Ordinal() {
+		if standard.CalcLevel(status.AQI-r.Hysteresis).Ordinal() <= prevLevel.Ordinal() {
+			return NoAlertEvent, state
+		}
+	} else {
+		if standard.CalcLevel(status.AQI+r.Hysteresis).Ordinal() >= prevLevel.Ordinal() {
+			return NoAlertEvent, state
+		}
+	}
+
+	return LevelChangedAlertEvent, string(level)
+}
+
+func (r *AlertRule) evaluateThreshold(status *Status, state string) (AlertEvent, string) {
+	value := status.Value(r.Parameter)
+	if value == nil {
+		return NoAlertEvent, state
+	}
+
+	switch state {
+	case alertStateAbove:
+		if *value < r.Threshold-r.Hysteresis {
+			if r.Kind == CrossAlertRule {
+				return ClearedAlertEvent, alertStateBelow
+			}
+			return NoAlertEvent, alertStateBelow
+		}
+		return NoAlertEvent, state
+
+	case alertStateBelow:
+		if *value > r.Threshold {
+			return RaisedAlertEvent, alertStateAbove
+		}
+		return NoAlertEvent, state
+
+	default:
+		if *value > r.Threshold {
+			return NoAlertEvent, alertStateAbove
+		}
+		return NoAlertEvent, alertStateBelow
+	}
+}
+
+// EvaluateForecast checks a daily forecast against an alert rule
+// Returns true if forecasted max value is going to cross the rule's threshold
+// (or to reach a worse air quality level than the current one for level change rules).
+// Rules on parameters that are not forecasted fall back to level comparison.
+func (r *AlertRule) EvaluateForecast(status *Status, day *ForecastDay) bool {
+	if day == nil {
+		return false
+	}
+
+	max, parameter := day.MaxAQI()
+	if parameter == "" {
+		return false
+	}
+
+	if r.Kind != LevelChangeAlertRule {
+		if r.Parameter != AQIParameter {
+			value := day.Value(r.Parameter)
+			if value == nil {
+				return r.evaluateForecastLevel(status, max)
+			}
+			max = value.Max
+		}
+
+		current := status.Value(r.Parameter)
+		return max > r.Threshold && (current == nil || *current <= r.Threshold)
+	}
+
+	return r.evaluateForecastLevel(status, max)
+}
+
+func (r *AlertRule) evaluateForecastLevel(status *Status, max float32) bool {
+	standard := status.Standard.OrDefault()
+	return standard.CalcLevel(max-r.Hysteresis).Ordinal() > standard.CalcLevel(status.AQI).Ordinal()
+}
+
+func isKnownParameter(parameter Parameter) bool {
+	for _, p := range Parameters {
+		if p == parameter {
+			return true
+		}
+	}
+	return false
+}
+
+func formatAlertValue(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
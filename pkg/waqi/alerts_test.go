@@ -0,0 +1,171 @@
+package waqi_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kapitanov/tg-waqi-bot/pkg/waqi"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	a := assert.New(t)
+
+	rule, err := waqi.ParseAlertRule("")
+	a.Nil(err)
+	a.Equal(waqi.LevelChangeAlertRule, rule.Kind)
+	a.Equal("level", rule.String())
+
+	rule, err = waqi.ParseAlertRule("aqi<>100")
+	a.Nil(err)
+	a.Equal(waqi.CrossAlertRule, rule.Kind)
+	a.Equal(waqi.AQIParameter, rule.Parameter)
+	a.Equal(float32(100), rule.Threshold)
+	a.Equal(float32(10), rule.Hysteresis)
+	a.Equal("aqi<>100", rule.String())
+	a.Equal("AQI crosses 100", rule.Describe())
+
+	rule, err = waqi.ParseAlertRule("pm25>35~2")
+	a.Nil(err)
+	a.Equal(waqi.AboveAlertRule, rule.Kind)
+	a.Equal(waqi.PM25Parameter, rule.Parameter)
+	a.Equal(float32(35), rule.Threshold)
+	a.Equal(float32(2), rule.Hysteresis)
+	a.Equal("pm25>35~2", rule.String())
+	a.Equal("PM2.5 above 35", rule.Describe())
+
+	_, err = waqi.ParseAlertRule("foo>35")
+	a.NotNil(err)
+	_, err = waqi.ParseAlertRule(">35")
+	a.NotNil(err)
+	_, err = waqi.ParseAlertRule("aqi>abc")
+	a.NotNil(err)
+}
+
+func TestEvaluateLevelChangeAlertRule(t *testing.T) {
+	a := assert.New(t)
+	rule := waqi.DefaultAlertRule()
+
+	// First evaluation only captures a state
+	event, state := rule.Evaluate(statusWithAQI(40), "")
+	a.Equal(waqi.NoAlertEvent, event)
+
+	// A jump within a level should not trigger an alert
+	event, state = rule.Evaluate(statusWithAQI(50), state)
+	a.Equal(waqi.NoAlertEvent, event)
+
+	// A value just above a boundary should not trigger an alert because of hysteresis
+	event, state = rule.Evaluate(statusWithAQI(53), state)
+	a.Equal(waqi.NoAlertEvent, event)
+
+	// A value far enough from a boundary should trigger an alert
+	event, state = rule.Evaluate(statusWithAQI(60), state)
+	a.Equal(waqi.LevelChangedAlertEvent, event)
+
+	// A value bouncing back just below a boundary should not trigger an alert
+	event, state = rule.Evaluate(statusWithAQI(48), state)
+	a.Equal(waqi.NoAlertEvent, event)
+
+	event, state = rule.Evaluate(statusWithAQI(40), state)
+	a.Equal(waqi.LevelChangedAlertEvent, event)
+	a.Equal(string(waqi.GoodLevel), state)
+}
+
+func TestEvaluateCrossAlertRule(t *testing.T) {
+	a := assert.New(t)
+	rule, err := waqi.ParseAlertRule("aqi<>100")
+	a.Nil(err)
+
+	event, state := rule.Evaluate(statusWithAQI(52), "")
+	a.Equal(waqi.NoAlertEvent, event)
+
+	// A jump within a level should trigger an alert once a threshold is crossed
+	event, state = rule.Evaluate(statusWithAQI(99), state)
+	a.Equal(waqi.NoAlertEvent, event)
+	event, state = rule.Evaluate(statusWithAQI(101), state)
+	a.Equal(waqi.RaisedAlertEvent, event)
+
+	// Values bouncing around a threshold should not trigger alerts
+	event, state = rule.Evaluate(statusWithAQI(95), state)
+	a.Equal(waqi.NoAlertEvent, event)
+	event, state = rule.Evaluate(statusWithAQI(105), state)
+	a.Equal(waqi.NoAlertEvent, event)
+
+	// A value far enough below a threshold should trigger an alert
+	event, _ = rule.Evaluate(statusWithAQI(85), state)
+	a.Equal(waqi.ClearedAlertEvent, event)
+}
+
+func TestEvaluateAboveAlertRule(t *testing.T) {
+	a := assert.New(t)
+	rule, err := waqi.ParseAlertRule("pm25>35")
+	a.Nil(err)
+
+	event, state := rule.Evaluate(statusWithPM25(20), "")
+	a.Equal(waqi.NoAlertEvent, event)
+
+	event, state = rule.Evaluate(statusWithPM25(40), state)
+	a.Equal(waqi.RaisedAlertEvent, event)
+
+	// Dropping below a threshold should not trigger an alert but should re-arm a rule
+	event, state = rule.Evaluate(statusWithPM25(20), state)
+	a.Equal(waqi.NoAlertEvent, event)
+
+	event, state = rule.Evaluate(statusWithPM25(40), state)
+	a.Equal(waqi.RaisedAlertEvent, event)
+
+	// Missing values should not affect a state
+	event, newState := rule.Evaluate(statusWithAQI(100), state)
+	a.Equal(waqi.NoAlertEvent, event)
+	a.Equal(state, newState)
+}
+
+func TestEvaluateForecastAlertRule(t *testing.T) {
+	a := assert.New(t)
+
+	day := &waqi.ForecastDay{
+		Date: "2021-05-02",
+		PM25: &waqi.ForecastValue{Min: 40, Avg: 70, Max: 120},
+		PM10: &waqi.ForecastValue{Min: 10, Avg: 20, Max: 30},
+	}
+
+	// Level change rule triggers when tomorrow is going to be worse than now
+	rule := waqi.DefaultAlertRule()
+	a.True(rule.EvaluateForecast(statusWithAQI(60), day))
+	a.False(rule.EvaluateForecast(statusWithAQI(110), day))
+	a.False(rule.EvaluateForecast(statusWithAQI(60), &waqi.ForecastDay{Date: "2021-05-02", PM25: &waqi.ForecastValue{Max: 103}}))
+	a.False(rule.EvaluateForecast(statusWithAQI(60), nil))
+
+	// Threshold rules compare forecasted max value of their parameter
+	rule, err := waqi.ParseAlertRule("pm25>100")
+	a.Nil(err)
+	a.True(rule.EvaluateForecast(statusWithPM25(60), day))
+	a.False(rule.EvaluateForecast(statusWithPM25(110), day))
+
+	rule, err = waqi.ParseAlertRule("pm10>50")
+	a.Nil(err)
+	a.False(rule.EvaluateForecast(statusWithPM25(20), day))
+
+	rule, err = waqi.ParseAlertRule("aqi<>100")
+	a.Nil(err)
+	a.True(rule.EvaluateForecast(statusWithAQI(60), day))
+
+	// Parameters which are not forecasted fall back to level comparison
+	rule, err = waqi.ParseAlertRule("no2>50")
+	a.Nil(err)
+	a.True(rule.EvaluateForecast(statusWithAQI(60), day))
+}
+
+func statusWithAQI(aqi float32) *waqi.Status {
+	return &waqi.Status{
+		Station: &waqi.Station{ID: 1},
+		AQI:     aqi,
+		Level:   waqi.CalcAQILevel(aqi),
+	}
+}
+
+func statusWithPM25(pm25 float32) *waqi.Status {
+	status := statusWithAQI(pm25)
+	status.PM25 = &pm25
+	return status
+}
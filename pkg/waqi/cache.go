@@ -1,103 +1,349 @@
 package waqi
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"log"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/syndtr/goleveldb/leveldb"
 )
 
-type cachedStatus struct {
-	time   time.Time
-	status *Status
+// backgroundRefreshTimeout is max duration of a background cache refresh
+const backgroundRefreshTimeout = time.Minute
+
+// cacheConfig contains cachingServiceAdapter settings
+type cacheConfig struct {
+	// Cache store URL (see openCacheStore)
+	URL string
+
+	// Upstream identifier, records from other sources are discarded
+	Source string
+
+	// Age after which cached values are refreshed in background
+	SoftTTL time.Duration
+
+	// Age after which cached values are refreshed synchronously
+	HardTTL time.Duration
+
+	// Age after which cached values are removed, until then they are served if refresh fails
+	Retention time.Duration
+
+	// Max count of entries in store
+	MaxEntries int
+
+	// Max total size of entries in store (in bytes)
+	MaxSize int64
+
+	// Max count of entries kept in memory (zero disables in-memory tier)
+	MemoryEntries int
+
+	// Time to live of entries kept in memory
+	MemoryTTL time.Duration
 }
 
+// cachingServiceAdapter caches statuses in a cache store
+// Recently used records are also kept in memory, so that they are not read and decoded over and over again.
+// Statuses younger than softTTL are served as is.
+// Statuses younger than hardTTL are served as stale ones while being refreshed in background.
+// Older statuses are refreshed synchronously, but are still served as stale ones if refresh fails.
+// Statuses are kept in store for retention period, so that there is something to serve while WAQI is down.
+// Concurrent cache misses of the same key result in a single upstream request.
 type cachingServiceAdapter struct {
-	adapter adapter
-	db      *leveldb.DB
-	maxAge  time.Duration
+	adapter    adapter
+	store      cacheStore
+	memory     *memoryCache
+	source     string
+	softTTL    time.Duration
+	hardTTL    time.Duration
+	retention  time.Duration
+	maxEntries int
+	maxSize    int64
+	logger     *log.Logger
+	now        func() time.Time
+	flights    *flightGroup
+	refreshes  map[string]bool
+	accessed   map[string]time.Time
+	counters   CacheStats
+	mutex      *sync.Mutex
+	background *sync.WaitGroup
+	ticker     *time.Ticker
+	done       chan bool
 }
 
-func newCachingServiceAdapter(adapter adapter, path string, maxAge time.Duration) (adapter, error) {
-	db, err := leveldb.OpenFile(path, nil)
+func newCachingServiceAdapter(adapter adapter, config cacheConfig, logger *log.Logger) (*cachingServiceAdapter, error) {
+	store, err := openCacheStore(config.URL)
 	if err != nil {
 		return nil, err
 	}
-	return &cachingServiceAdapter{adapter, db, maxAge}, nil
 
+	if config.HardTTL < config.SoftTTL {
+		config.HardTTL = config.SoftTTL
+	}
+	if config.Retention < config.HardTTL {
+		config.Retention = config.HardTTL
+	}
+
+	s := &cachingServiceAdapter{
+		adapter:    adapter,
+		store:      store,
+		memory:     newMemoryCache(config.MemoryEntries, config.MemoryTTL),
+		source:     config.Source,
+		softTTL:    config.SoftTTL,
+		hardTTL:    config.HardTTL,
+		retention:  config.Retention,
+		maxEntries: config.MaxEntries,
+		maxSize:    config.MaxSize,
+		logger:     logger,
+		now:        time.Now,
+		flights:    newFlightGroup(),
+		refreshes:  make(map[string]bool),
+		accessed:   make(map[string]time.Time),
+		mutex:      &sync.Mutex{},
+		background: &sync.WaitGroup{},
+		ticker:     time.NewTicker(cacheJanitorPeriod),
+		done:       make(chan bool),
+	}
+	go s.JanitorLoop()
+
+	return s, nil
 }
 
 // GetByCity fetches current measurements for city
-func (s *cachingServiceAdapter) GetByCity(city string) (*Status, error) {
-	return s.GetOrAdd(s.GetCityKey(city), func() (*Status, error) {
-		return s.adapter.GetByCity(city)
+func (s *cachingServiceAdapter) GetByCity(ctx context.Context, city string) (*Status, error) {
+	return s.GetOrAdd(ctx, s.GetCityKey(city), func(ctx context.Context) (*Status, error) {
+		return s.adapter.GetByCity(ctx, city)
 	})
 }
 
 // GetByStation fetches current measurements for station
-func (s *cachingServiceAdapter) GetByStation(stationID int) (*Status, error) {
-	return s.GetOrAdd(s.GetStationKey(stationID), func() (*Status, error) {
-		return s.adapter.GetByStation(stationID)
+func (s *cachingServiceAdapter) GetByStation(ctx context.Context, stationID int) (*Status, error) {
+	return s.GetOrAdd(ctx, s.GetStationKey(stationID), func(ctx context.Context) (*Status, error) {
+		return s.adapter.GetByStation(ctx, stationID)
 	})
 }
 
 // GetByGeo fetches current measurements for geo coordinates
-func (s *cachingServiceAdapter) GetByGeo(lat, lon float32) (*Status, error) {
-	return s.GetOrAdd(s.GetGeoKey(lat, lon), func() (*Status, error) {
-		return s.adapter.GetByGeo(lat, lon)
+func (s *cachingServiceAdapter) GetByGeo(ctx context.Context, lat, lon float32) (*Status, error) {
+	return s.GetOrAdd(ctx, s.GetGeoKey(lat, lon), func(ctx context.Context) (*Status, error) {
+		return s.adapter.GetByGeo(ctx, lat, lon)
 	})
 }
 
-// GetOrAdd gets a value from cache or fetches a new one
-func (s *cachingServiceAdapter) GetOrAdd(key string, fn func() (*Status, error)) (*Status, error) {
-	raw, err := s.db.Get([]byte(key), nil)
+// Search looks up stations by a keyword
+func (s *cachingServiceAdapter) Search(ctx context.Context, keyword string) ([]*StationSummary, error) {
+	return s.GetOrAddSummaries(ctx, s.GetSearchKey(keyword), func(ctx context.Context) ([]*StationSummary, error) {
+		return s.adapter.Search(ctx, keyword)
+	})
+}
+
+// GetByBounds returns stations located within specified rectangle
+func (s *cachingServiceAdapter) GetByBounds(ctx context.Context, bounds Bounds) ([]*StationSummary, error) {
+	return s.GetOrAddSummaries(ctx, s.GetBoundsKey(bounds), func(ctx context.Context) ([]*StationSummary, error) {
+		return s.adapter.GetByBounds(ctx, bounds)
+	})
+}
+
+// RefreshStation fetches current measurements for station regardless of cached ones
+func (s *cachingServiceAdapter) RefreshStation(ctx context.Context, stationID int) (*Status, error) {
+	return s.FetchAndPutOnce(ctx, s.GetStationKey(stationID), func(ctx context.Context) (*Status, error) {
+		return s.adapter.GetByStation(ctx, stationID)
+	})
+}
+
+// GetOrAddSummaries gets a list of stations from cache or fetches a new one
+func (s *cachingServiceAdapter) GetOrAddSummaries(ctx context.Context, key string, fn func(context.Context) ([]*StationSummary, error)) ([]*StationSummary, error) {
+	record, err := s.Get(key)
 	if err != nil {
-		if err == leveldb.ErrNotFound {
-			return s.FetchAndPut(fn)
+		return nil, err
+	}
+
+	if record != nil && !record.HasStatus() && s.now().Sub(record.FetchedAt) < s.softTTL {
+		s.CountLookup(true)
+		s.Touch(key)
+		return record.Summaries, nil
+	}
+
+	s.CountLookup(false)
+
+	value, err := s.flights.Do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		results, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		bytes, err := newSummariesRecord(results, s.now(), s.source).Marshal()
+		if err != nil {
+			return nil, err
 		}
 
+		err = s.store.Put(key, bytes, s.retention)
+		if err != nil {
+			return nil, err
+		}
+
+		s.memory.Remove(key)
+		s.Touch(key)
+		return results, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value.([]*StationSummary), nil
+}
+
+// GetOrAdd gets a value from cache or fetches a new one
+func (s *cachingServiceAdapter) GetOrAdd(ctx context.Context, key string, fn func(context.Context) (*Status, error)) (*Status, error) {
+	cached, err := s.Get(key)
+	if err != nil {
 		return nil, err
 	}
 
-	var cached cachedStatus
-	err = json.Unmarshal(raw, &cached)
+	if cached != nil && !cached.HasStatus() {
+		s.Discard(key, "record contains no status")
+		cached = nil
+	}
+
+	if cached != nil {
+		age := s.now().Sub(cached.FetchedAt)
+		if age < s.softTTL {
+			s.CountLookup(true)
+			s.Touch(key)
+			return cached.Status, nil
+		}
+
+		if age < s.hardTTL {
+			s.CountLookup(true)
+			s.Touch(key)
+			s.RefreshInBackground(key, fn)
+			return cached.StaleStatus(), nil
+		}
+	}
+
+	s.CountLookup(false)
+
+	status, err := s.FetchAndPutOnce(ctx, key, fn)
 	if err != nil {
+		if cached != nil && ctx.Err() == nil {
+			s.logger.Printf("unable to refresh \"%s\", using stale data: %s", key, err)
+			return cached.StaleStatus(), nil
+		}
+
 		return nil, err
 	}
 
-	age := time.Now().Sub(cached.time)
-	if age.Milliseconds() >= s.maxAge.Milliseconds() {
-		return s.FetchAndPut(fn)
+	return status, nil
+}
+
+// Get reads a record from cache
+// Corrupt records, records in older format and records from another source are discarded
+func (s *cachingServiceAdapter) Get(key string) (*cacheRecord, error) {
+	if record := s.memory.Get(key, s.now()); record != nil {
+		s.CountMemoryHit()
+		return record, nil
+	}
+
+	raw, err := s.store.Get(key)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	record, err := unmarshalCacheRecord(raw)
+	if err != nil {
+		s.Discard(key, err.Error())
+		return nil, nil
+	}
+
+	if record.Source != s.source {
+		s.Discard(key, fmt.Sprintf("record is from \"%s\"", record.Source))
+		return nil, nil
 	}
 
-	return cached.status, nil
+	s.memory.Put(key, record, s.now())
+	return record, nil
+}
+
+// Discard removes an unusable record from cache
+func (s *cachingServiceAdapter) Discard(key, reason string) {
+	s.logger.Printf("discarding cached \"%s\": %s", key, reason)
+	s.memory.Remove(key)
+
+	err := s.store.Delete(key)
+	if err != nil {
+		s.logger.Printf("unable to discard cached \"%s\": %s", key, err)
+	}
+}
+
+// RefreshInBackground refreshes a cached value unless it's being refreshed already
+func (s *cachingServiceAdapter) RefreshInBackground(key string, fn func(context.Context) (*Status, error)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.refreshes[key] {
+		return
+	}
+	s.refreshes[key] = true
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		defer func() {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			delete(s.refreshes, key)
+		}()
+
+		// Request context might be over already, so refresh gets its own one
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundRefreshTimeout)
+		defer cancel()
+
+		_, err := s.FetchAndPutOnce(ctx, key, fn)
+		if err != nil {
+			s.logger.Printf("unable to refresh \"%s\" in background: %s", key, err)
+		}
+	}()
+}
+
+// FetchAndPutOnce fetches a value and stores it into cache
+// If the same key is being fetched already, result of that request is returned
+func (s *cachingServiceAdapter) FetchAndPutOnce(ctx context.Context, key string, fn func(context.Context) (*Status, error)) (*Status, error) {
+	value, err := s.flights.Do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return s.FetchAndPut(ctx, fn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value.(*Status), nil
 }
 
 // FetchAndPut fetches a value and stores it into cache
-func (s *cachingServiceAdapter) FetchAndPut(fn func() (*Status, error)) (*Status, error) {
-	status, err := fn()
+func (s *cachingServiceAdapter) FetchAndPut(ctx context.Context, fn func(context.Context) (*Status, error)) (*Status, error) {
+	status, err := fn(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	cached := &cachedStatus{
-		status: status,
-		time:   time.Now(),
+	// Stale values are returned by resilient adapter when service is down, they should not be cached
+	if status.Stale {
+		return status, nil
 	}
-	bytes, err := json.Marshal(cached)
+
+	bytes, err := newStatusRecord(status, s.now(), s.source).Marshal()
 	if err != nil {
 		return nil, err
 	}
 
 	keys := s.GetKeys(status)
 	for _, key := range keys {
-		err = s.db.Put([]byte(key), bytes, nil)
+		err = s.store.Put(key, bytes, s.retention)
 		if err != nil {
 			return nil, err
 		}
 	}
+	s.memory.Remove(keys...)
+	s.Touch(keys...)
 
 	return status, nil
 }
@@ -107,6 +353,16 @@ func (s *cachingServiceAdapter) GetCityKey(name string) string {
 	return fmt.Sprintf("city/%s", strings.ToLower(name))
 }
 
+// GetSearchKey returns cache key for search keyword
+func (s *cachingServiceAdapter) GetSearchKey(keyword string) string {
+	return fmt.Sprintf("search/%s", strings.ToLower(strings.TrimSpace(keyword)))
+}
+
+// GetBoundsKey returns cache key for geo rectangle
+func (s *cachingServiceAdapter) GetBoundsKey(bounds Bounds) string {
+	return fmt.Sprintf("bounds/%0.2f/%0.2f/%0.2f/%0.2f", bounds.MinLat, bounds.MinLon, bounds.MaxLat, bounds.MaxLon)
+}
+
 // GetStationKey returns cache key for station ID
 func (s *cachingServiceAdapter) GetStationKey(stationID int) string {
 	return fmt.Sprintf("station/%d", stationID)
@@ -129,12 +385,16 @@ func (s *cachingServiceAdapter) GetKeys(status *Status) []string {
 
 // Close shuts down adapter
 func (s *cachingServiceAdapter) Close() error {
+	s.ticker.Stop()
+	s.done <- true
+	s.background.Wait()
+
 	err := s.adapter.Close()
 	if err != nil {
 		return err
 	}
 
-	err = s.db.Close()
+	err = s.store.Close()
 	if err != nil {
 		return err
 	}
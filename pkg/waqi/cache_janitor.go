@@ -0,0 +1,170 @@
+package waqi
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultCacheMaxEntries is default max count of cached entries
+	DefaultCacheMaxEntries = 10000
+
+	// DefaultCacheMaxSize is default max total size of cached entries (in bytes)
+	DefaultCacheMaxSize = 64 * 1024 * 1024
+
+	// cacheJanitorPeriod is a period of cache cleanup
+	cacheJanitorPeriod = 5 * time.Minute
+)
+
+// cacheEntryInfo describes a cached entry during cleanup
+type cacheEntryInfo struct {
+	key        string
+	size       int64
+	lastAccess time.Time
+}
+
+// JanitorLoop runs background cache cleanup loop
+func (s *cachingServiceAdapter) JanitorLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.ticker.C:
+			err := s.Cleanup(s.now())
+			if err != nil {
+				s.logger.Printf("cache cleanup failed: %s", err)
+			}
+		}
+	}
+}
+
+// Cleanup removes entries older than retention period as well as unreadable ones
+// If cache is still over its limits, least recently used entries are evicted
+func (s *cachingServiceAdapter) Cleanup(now time.Time) error {
+	removed := make([]string, 0)
+	entries := make([]*cacheEntryInfo, 0)
+	var totalSize int64
+	err := s.store.Scan(func(key string, value []byte) error {
+		record, err := unmarshalCacheRecord(value)
+		if err != nil || record.Source != s.source || now.Sub(record.FetchedAt) >= s.retention {
+			removed = append(removed, key)
+			return nil
+		}
+
+		size := int64(len(key) + len(value))
+		totalSize += size
+		entries = append(entries, &cacheEntryInfo{
+			key:        key,
+			size:       size,
+			lastAccess: s.GetLastAccess(key, record.FetchedAt),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	expired := len(removed)
+
+	// Evict least recently used entries until cache fits into limits
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastAccess.Before(entries[j].lastAccess)
+	})
+	evicted := 0
+	for len(entries)-evicted > s.maxEntries || totalSize > s.maxSize {
+		entry := entries[evicted]
+		removed = append(removed, entry.key)
+		totalSize -= entry.size
+		evicted++
+	}
+
+	s.mutex.Lock()
+	s.counters.Expirations += uint64(expired)
+	s.counters.Evictions += uint64(evicted)
+	alive := make(map[string]time.Time, len(entries)-evicted)
+	for _, entry := range entries[evicted:] {
+		if t, exists := s.accessed[entry.key]; exists {
+			alive[entry.key] = t
+		}
+	}
+	s.accessed = alive
+	s.mutex.Unlock()
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	s.logger.Printf("cache cleanup: %d expired and %d evicted record(s) removed", expired, evicted)
+	err = s.store.Delete(removed...)
+	if err != nil {
+		return err
+	}
+
+	// Removed records might be still kept in memory
+	s.memory.Remove(removed...)
+	return nil
+}
+
+// GetLastAccess returns last access time of a cached entry
+// Entries that haven't been accessed since startup are considered to be accessed when they were fetched
+func (s *cachingServiceAdapter) GetLastAccess(key string, fetchedAt time.Time) time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if t, exists := s.accessed[key]; exists && t.After(fetchedAt) {
+		return t
+	}
+
+	return fetchedAt
+}
+
+// Touch records an access to cached entries
+func (s *cachingServiceAdapter) Touch(keys ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	for _, key := range keys {
+		s.accessed[key] = now
+	}
+}
+
+// CountLookup records a cache lookup result
+func (s *cachingServiceAdapter) CountLookup(hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if hit {
+		s.counters.Hits++
+	} else {
+		s.counters.Misses++
+	}
+}
+
+// CountMemoryHit records a lookup served from in-memory tier
+func (s *cachingServiceAdapter) CountMemoryHit() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.counters.MemoryHits++
+}
+
+// GetStats returns cache usage statistics
+func (s *cachingServiceAdapter) GetStats() (*CacheStats, error) {
+	s.mutex.Lock()
+	stats := s.counters
+	s.mutex.Unlock()
+
+	stats.Enabled = true
+	stats.MemoryEntries = s.memory.Len()
+
+	err := s.store.Scan(func(key string, value []byte) error {
+		stats.Entries++
+		stats.Size += int64(len(key) + len(value))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,104 @@
+package waqi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachingServiceAdapterCleanup(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42}
+	cache, err := newTestCachingServiceAdapter(t, upstream, 7)
+	a.Nil(err)
+	defer cache.Close()
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	// Each status is stored under 3 keys
+	for _, stationID := range []int{1, 2, 3} {
+		_, err = cache.GetByStation(context.Background(), stationID)
+		a.Nil(err)
+		now = now.Add(time.Minute)
+	}
+	a.Nil(cache.store.Put("station/999", []byte("garbage"), 0))
+
+	// Station #1 is accessed by its ID recently, so its city and geo entries are the least recently used ones
+	_, err = cache.GetByStation(context.Background(), 1)
+	a.Nil(err)
+
+	a.Nil(cache.Cleanup(now))
+	a.True(hasCacheEntry(cache, cache.GetStationKey(1)))
+	a.False(hasCacheEntry(cache, cache.GetCityKey("Station #1")))
+	a.False(hasCacheEntry(cache, cache.GetGeoKey(1, 0)))
+	a.True(hasCacheEntry(cache, cache.GetCityKey("Station #2")))
+	a.True(hasCacheEntry(cache, cache.GetStationKey(3)))
+	a.False(hasCacheEntry(cache, "station/999"))
+
+	stats, err := cache.GetStats()
+	a.Nil(err)
+	a.True(stats.Enabled)
+	a.Equal(7, stats.Entries)
+	a.Equal(uint64(2), stats.Evictions)
+	a.Equal(uint64(1), stats.Expirations)
+	a.Equal(uint64(1), stats.Hits)
+	a.Equal(uint64(3), stats.Misses)
+
+	// Entries older than hard TTL should be kept to be served if WAQI is down
+	now = now.Add(time.Hour)
+	a.Nil(cache.Cleanup(now))
+	a.True(hasCacheEntry(cache, cache.GetStationKey(1)))
+
+	// Entries older than retention period should be removed
+	now = now.Add(5 * time.Hour)
+	a.Nil(cache.Cleanup(now))
+	stats, err = cache.GetStats()
+	a.Nil(err)
+	a.Equal(0, stats.Entries)
+	a.Equal(int64(0), stats.Size)
+	a.Equal(uint64(8), stats.Expirations)
+}
+
+func TestCachingServiceAdapterCleanupBySize(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42}
+	cache, err := newTestCachingServiceAdapter(t, upstream, DefaultCacheMaxEntries)
+	a.Nil(err)
+	defer cache.Close()
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	for _, stationID := range []int{1, 2} {
+		_, err = cache.GetByStation(context.Background(), stationID)
+		a.Nil(err)
+		now = now.Add(time.Minute)
+	}
+
+	stats, err := cache.GetStats()
+	a.Nil(err)
+	a.Equal(6, stats.Entries)
+
+	// Limit size so that only one status fits
+	cache.maxSize = stats.Size / 2
+	a.Nil(cache.Cleanup(now))
+	a.False(hasCacheEntry(cache, cache.GetStationKey(1)))
+	a.True(hasCacheEntry(cache, cache.GetStationKey(2)))
+
+	stats, err = cache.GetStats()
+	a.Nil(err)
+	a.Equal(3, stats.Entries)
+	a.True(stats.Size <= cache.maxSize)
+}
+
+// hasCacheEntry checks whether cache store contains a key
+func hasCacheEntry(cache *cachingServiceAdapter, key string) bool {
+	value, err := cache.store.Get(key)
+	return err == nil && value != nil
+}
@@ -0,0 +1,110 @@
+package waqi
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheMemoryEntries is default max count of entries kept in memory
+	DefaultCacheMemoryEntries = 1000
+
+	// DefaultCacheMemoryTTL is default time to live of entries kept in memory
+	DefaultCacheMemoryTTL = time.Minute
+)
+
+// memoryCache is an in-memory LRU cache of decoded records with limited time to live
+// It's used as a first tier in front of leveldb
+type memoryCache struct {
+	capacity int
+	ttl      time.Duration
+	mutex    *sync.Mutex
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+// memoryCacheItem is an entry of memoryCache
+type memoryCacheItem struct {
+	key     string
+	record  *cacheRecord
+	expires time.Time
+}
+
+func newMemoryCache(capacity int, ttl time.Duration) *memoryCache {
+	return &memoryCache{
+		capacity: capacity,
+		ttl:      ttl,
+		mutex:    &sync.Mutex{},
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns a record unless it's missing or expired
+func (c *memoryCache) Get(key string, now time.Time) *cacheRecord {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.items[key]
+	if !exists {
+		return nil
+	}
+
+	item := element.Value.(*memoryCacheItem)
+	if !now.Before(item.expires) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return nil
+	}
+
+	c.order.MoveToFront(element)
+	return item.record
+}
+
+// Put adds a record, evicting the least recently used one if cache is full
+func (c *memoryCache) Put(key string, record *cacheRecord, now time.Time) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.items[key]; exists {
+		item := element.Value.(*memoryCacheItem)
+		item.record = record
+		item.expires = now.Add(c.ttl)
+		c.order.MoveToFront(element)
+		return
+	}
+
+	for c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryCacheItem).key)
+	}
+
+	c.items[key] = c.order.PushFront(&memoryCacheItem{key: key, record: record, expires: now.Add(c.ttl)})
+}
+
+// Remove removes records
+func (c *memoryCache) Remove(keys ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range keys {
+		if element, exists := c.items[key]; exists {
+			c.order.Remove(element)
+			delete(c.items, key)
+		}
+	}
+}
+
+// Len returns count of records
+func (c *memoryCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,129 @@
+package waqi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCacheLRU(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cache := newMemoryCache(2, time.Minute)
+	first, second, third := &cacheRecord{}, &cacheRecord{}, &cacheRecord{}
+
+	cache.Put("first", first, now)
+	cache.Put("second", second, now)
+
+	// "first" becomes the most recently used one, so "second" should be evicted
+	a.Same(first, cache.Get("first", now))
+	cache.Put("third", third, now)
+
+	a.Equal(2, cache.Len())
+	a.Same(first, cache.Get("first", now))
+	a.Nil(cache.Get("second", now))
+	a.Same(third, cache.Get("third", now))
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cache := newMemoryCache(10, time.Minute)
+	record := &cacheRecord{}
+
+	cache.Put("key", record, now)
+	a.Same(record, cache.Get("key", now.Add(30*time.Second)))
+	a.Nil(cache.Get("key", now.Add(time.Minute)))
+	a.Equal(0, cache.Len())
+}
+
+func TestMemoryCacheDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cache := newMemoryCache(0, time.Minute)
+
+	cache.Put("key", &cacheRecord{}, now)
+	a.Nil(cache.Get("key", now))
+	a.Equal(0, cache.Len())
+}
+
+func TestCachingServiceAdapterMemoryTier(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42}
+	cache, err := newTestCachingServiceAdapter(t, upstream, DefaultCacheMaxEntries)
+	a.Nil(err)
+	defer cache.Close()
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	_, err = cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+
+	// The first hit is read from leveldb, the next ones are served from memory
+	for i := 0; i < 3; i++ {
+		status, err := cache.GetByStation(context.Background(), 123)
+		a.Nil(err)
+		a.Equal(float32(42), status.AQI)
+	}
+
+	stats, err := cache.GetStats()
+	a.Nil(err)
+	a.Equal(uint64(3), stats.Hits)
+	a.Equal(uint64(2), stats.MemoryHits)
+	a.Equal(1, stats.MemoryEntries)
+
+	// Fetched status should invalidate all of its keys in memory
+	now = now.Add(2 * time.Hour)
+	upstream.SetAQI(50)
+	status, err := cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(50), status.AQI)
+	a.Equal(0, cache.memory.Len())
+
+	status, err = cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(50), status.AQI)
+	a.Equal(2, upstream.Calls())
+}
+
+func BenchmarkCachingServiceAdapterGetByStation(b *testing.B) {
+	for _, bench := range []struct {
+		name          string
+		memoryEntries int
+	}{
+		{"leveldb", 0},
+		{"memory", DefaultCacheMemoryEntries},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42}
+			cache, err := newTestCachingServiceAdapter(b, upstream, DefaultCacheMaxEntries)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer cache.Close()
+			cache.memory = newMemoryCache(bench.memoryEntries, DefaultCacheMemoryTTL)
+
+			_, err = cache.GetByStation(context.Background(), 123)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err = cache.GetByStation(context.Background(), 123)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package waqi
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// cacheRecordVersion is a version of cache record format
+// It should be incremented whenever format changes, so that records in older format are discarded
+const cacheRecordVersion = 3
+
+// errCacheRecordVersion is returned when a cache record has unsupported version
+var errCacheRecordVersion = errors.New("unsupported cache record version")
+
+// cacheRecord is a cache entry as it's stored on disk
+type cacheRecord struct {
+	// Format version
+	Version int `json:"v"`
+
+	// Time when value has been fetched from upstream
+	FetchedAt time.Time `json:"fetched_at"`
+
+	// Measurement time reported by upstream
+	UpstreamTime time.Time `json:"upstream_time"`
+
+	// Upstream adapter which has provided value
+	Source string `json:"source"`
+
+	// Cached status (for status records)
+	Status *Status `json:"status,omitempty"`
+
+	// Cached list of stations (for search results)
+	Summaries []*StationSummary `json:"summaries,omitempty"`
+}
+
+// newStatusRecord creates a cache record for a status
+func newStatusRecord(status *Status, fetchedAt time.Time, source string) *cacheRecord {
+	return &cacheRecord{
+		Version:      cacheRecordVersion,
+		FetchedAt:    fetchedAt,
+		UpstreamTime: status.Time,
+		Source:       source,
+		Status:       status,
+	}
+}
+
+// newSummariesRecord creates a cache record for a list of stations
+func newSummariesRecord(summaries []*StationSummary, fetchedAt time.Time, source string) *cacheRecord {
+	return &cacheRecord{
+		Version:   cacheRecordVersion,
+		FetchedAt: fetchedAt,
+		Source:    source,
+		Summaries: summaries,
+	}
+}
+
+// unmarshalCacheRecord decodes a cache record
+// Records in older formats are rejected with errCacheRecordVersion
+func unmarshalCacheRecord(raw []byte) (*cacheRecord, error) {
+	var record cacheRecord
+	err := json.Unmarshal(raw, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Version != cacheRecordVersion {
+		return nil, errCacheRecordVersion
+	}
+
+	return &record, nil
+}
+
+// Marshal encodes a cache record
+func (r *cacheRecord) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// HasStatus returns true if record contains a usable status
+func (r *cacheRecord) HasStatus() bool {
+	return r.Status != nil && r.Status.Station != nil
+}
+
+// StaleStatus returns a copy of cached status marked as stale
+func (r *cacheRecord) StaleStatus() *Status {
+	status := *r.Status
+	status.Stale = true
+	return &status
+}
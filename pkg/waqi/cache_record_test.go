@@ -0,0 +1,110 @@
+package waqi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheRecordStatusRoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	pm25 := float32(12.5)
+	status := &Status{
+		Station: &Station{ID: 123, Name: "Moscow", URL: "https://aqicn.org/city/moscow", Lat: 55.75, Lon: 37.62},
+		Time:    time.Date(2021, 5, 1, 9, 0, 0, 0, time.UTC),
+		AQI:     42,
+		Level:   GoodLevel,
+		PM25:    &pm25,
+	}
+	fetchedAt := time.Date(2021, 5, 1, 9, 30, 0, 0, time.UTC)
+
+	raw, err := newStatusRecord(status, fetchedAt, "https://api.waqi.info").Marshal()
+	a.Nil(err)
+
+	record, err := unmarshalCacheRecord(raw)
+	a.Nil(err)
+	a.Equal(cacheRecordVersion, record.Version)
+	a.True(fetchedAt.Equal(record.FetchedAt))
+	a.True(status.Time.Equal(record.UpstreamTime))
+	a.Equal("https://api.waqi.info", record.Source)
+	a.True(record.HasStatus())
+	a.Equal(*status.Station, *record.Status.Station)
+	a.True(status.Equal(record.Status))
+	a.Equal(float32(12.5), *record.Status.PM25)
+	a.Nil(record.Status.PM10)
+	a.Nil(record.Summaries)
+}
+
+func TestCacheRecordSummariesRoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	summary := &StationSummary{Station: &Station{ID: 123, Name: "Moscow"}, Time: time.Date(2021, 5, 1, 9, 0, 0, 0, time.UTC)}
+	summary.SetAQI("42")
+	fetchedAt := time.Date(2021, 5, 1, 9, 30, 0, 0, time.UTC)
+
+	raw, err := newSummariesRecord([]*StationSummary{summary}, fetchedAt, "https://api.waqi.info").Marshal()
+	a.Nil(err)
+
+	record, err := unmarshalCacheRecord(raw)
+	a.Nil(err)
+	a.False(record.HasStatus())
+	a.Len(record.Summaries, 1)
+	a.Equal(123, record.Summaries[0].Station.ID)
+	a.Equal(float32(42), *record.Summaries[0].AQI)
+	a.Equal(GoodLevel, record.Summaries[0].Level)
+}
+
+func TestUnmarshalCacheRecordRejectsUnsupported(t *testing.T) {
+	a := assert.New(t)
+
+	// Records written by older versions
+	_, err := unmarshalCacheRecord([]byte(`{}`))
+	a.Equal(errCacheRecordVersion, err)
+	_, err = unmarshalCacheRecord([]byte(`{"time":"2021-05-01T09:00:00Z","status":{"aqi":42}}`))
+	a.Equal(errCacheRecordVersion, err)
+	_, err = unmarshalCacheRecord([]byte(`{"v":1,"status":{"aqi":42}}`))
+	a.Equal(errCacheRecordVersion, err)
+	_, err = unmarshalCacheRecord([]byte(`{"v":2,"status":{"aqi":42}}`))
+	a.Equal(errCacheRecordVersion, err)
+	_, err = unmarshalCacheRecord([]byte(`{"v":99,"status":{"aqi":42}}`))
+	a.Equal(errCacheRecordVersion, err)
+
+	// Corrupt records
+	_, err = unmarshalCacheRecord([]byte(`{"v":1,"status":`))
+	a.Error(err)
+	_, err = unmarshalCacheRecord([]byte{0xff, 0x00})
+	a.Error(err)
+}
+
+func TestCachingServiceAdapterDiscardsUnusableRecords(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42}
+	cache, err := newTestCachingServiceAdapter(t, upstream, DefaultCacheMaxEntries)
+	a.Nil(err)
+	defer cache.Close()
+
+	key := cache.GetStationKey(123)
+	otherSource, err := newStatusRecord(&Status{Station: &Station{ID: 123}, AQI: 100}, time.Now(), "other").Marshal()
+	a.Nil(err)
+
+	for _, raw := range [][]byte{[]byte(`{}`), []byte(`garbage`), []byte(`{"v":1}`), otherSource} {
+		a.Nil(cache.store.Put(key, raw, 0))
+
+		status, err := cache.GetByStation(context.Background(), 123)
+		a.Nil(err)
+		a.Equal(float32(42), status.AQI)
+		a.False(status.Stale)
+	}
+	a.Equal(4, upstream.Calls())
+
+	// A valid record should be written instead of discarded one
+	record, err := cache.Get(key)
+	a.Nil(err)
+	a.Equal("test", record.Source)
+	a.Equal(float32(42), record.Status.AQI)
+}
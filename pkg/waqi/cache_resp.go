@@ -0,0 +1,375 @@
+package waqi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultRESPPort is default port of Redis-compatible server
+	defaultRESPPort = "6379"
+
+	// defaultRESPPrefix is default prefix of cache keys stored in Redis-compatible server
+	defaultRESPPrefix = "waqi:cache:"
+
+	// defaultRESPTimeout is default timeout of Redis-compatible server commands
+	defaultRESPTimeout = 5 * time.Second
+
+	// respMaxIdleConns is max count of idle connections to Redis-compatible server
+	respMaxIdleConns = 4
+
+	// respScanCount is a count of keys requested per SCAN command
+	respScanCount = 100
+)
+
+// respError is an error reply of Redis-compatible server
+type respError string
+
+// Error returns error message
+func (e respError) Error() string {
+	return string(e)
+}
+
+// respCacheStore is a cache store backed by a Redis-compatible server
+// Several bot instances might share the same cache this way.
+type respCacheStore struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	timeout  time.Duration
+	idle     chan *respConn
+}
+
+func newRESPCacheStore(url string) (cacheStore, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &respCacheStore{
+		addr:    u.Host,
+		prefix:  defaultRESPPrefix,
+		timeout: defaultRESPTimeout,
+		idle:    make(chan *respConn, respMaxIdleConns),
+	}
+
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), defaultRESPPort)
+	}
+
+	if u.User != nil {
+		s.password, _ = u.User.Password()
+	}
+
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		s.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("malformed cache database number \"%s\"", db)
+		}
+	}
+
+	query := u.Query()
+	if prefix, exists := query["prefix"]; exists {
+		s.prefix = prefix[0]
+	}
+	if timeout := query.Get("timeout"); timeout != "" {
+		s.timeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Make sure server is reachable
+	_, err = s.Do("PING")
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Get reads a value, missing values are returned as nil
+func (s *respCacheStore) Get(key string) ([]byte, error) {
+	reply, err := s.Do("GET", s.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _ := reply.([]byte)
+	return value, nil
+}
+
+// Put writes a value which expires after ttl
+func (s *respCacheStore) Put(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", s.prefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+
+	_, err := s.Do(args...)
+	return err
+}
+
+// Delete removes values
+func (s *respCacheStore) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := []string{"DEL"}
+	for _, key := range keys {
+		args = append(args, s.prefix+key)
+	}
+
+	_, err := s.Do(args...)
+	return err
+}
+
+// Scan calls fn for each stored value until fn returns an error
+// Values that are modified during scan might be skipped or visited twice
+func (s *respCacheStore) Scan(fn func(key string, value []byte) error) error {
+	pattern := escapeRESPPattern(s.prefix) + "*"
+	cursor := "0"
+	for {
+		reply, err := s.Do("SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(respScanCount))
+		if err != nil {
+			return err
+		}
+
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return fmt.Errorf("malformed SCAN reply")
+		}
+
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]interface{})
+		if len(keys) > 0 {
+			args := []string{"MGET"}
+			for _, key := range keys {
+				raw, _ := key.([]byte)
+				args = append(args, string(raw))
+			}
+
+			reply, err = s.Do(args...)
+			if err != nil {
+				return err
+			}
+
+			values, _ := reply.([]interface{})
+			for i, value := range values {
+				// Value might have been removed after SCAN
+				raw, ok := value.([]byte)
+				if !ok {
+					continue
+				}
+
+				err = fn(strings.TrimPrefix(args[i+1], s.prefix), raw)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// Do sends a command and returns its reply
+// Replies are decoded into nil, string, int64, []byte or []interface{} values
+func (s *respCacheStore) Do(args ...string) (interface{}, error) {
+	conn, err := s.Acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.Do(time.Now().Add(s.timeout), args...)
+	if err != nil {
+		// Error replies don't break the connection, other errors do
+		var e respError
+		if errors.As(err, &e) {
+			s.Release(conn)
+		} else {
+			_ = conn.Close()
+		}
+		return nil, err
+	}
+
+	s.Release(conn)
+	return reply, nil
+}
+
+// Acquire returns an idle connection or opens a new one
+func (s *respCacheStore) Acquire() (*respConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+
+	c, err := net.DialTimeout("tcp", s.addr, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &respConn{conn: c, reader: bufio.NewReader(c)}
+	deadline := time.Now().Add(s.timeout)
+	if s.password != "" {
+		_, err = conn.Do(deadline, "AUTH", s.password)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		_, err = conn.Do(deadline, "SELECT", strconv.Itoa(s.db))
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// Release returns a connection into idle pool
+func (s *respCacheStore) Release(conn *respConn) {
+	select {
+	case s.idle <- conn:
+	default:
+		_ = conn.Close()
+	}
+}
+
+// Close shuts down store
+func (s *respCacheStore) Close() error {
+	for {
+		select {
+		case conn := <-s.idle:
+			_ = conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// respConn is a connection to Redis-compatible server
+type respConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Do sends a command and reads its reply
+func (c *respConn) Do(deadline time.Time, args ...string) (interface{}, error) {
+	err := c.conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.conn.Write(encodeRESPCommand(args...))
+	if err != nil {
+		return nil, err
+	}
+
+	return readRESPReply(c.reader)
+}
+
+// Close closes connection
+func (c *respConn) Close() error {
+	return c.conn.Close()
+}
+
+// encodeRESPCommand encodes a command as an array of bulk strings
+func encodeRESPCommand(args ...string) []byte {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, fmt.Sprintf("*%d\r\n", len(args))...)
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n", len(arg))...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	return buf
+}
+
+// readRESPReply reads a single reply
+// Error replies are returned as respError
+func readRESPReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("malformed RESP reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, respError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, length+2)
+		_, err = io.ReadFull(reader, buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:length], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+
+		items := make([]interface{}, count)
+		for i := range items {
+			items[i], err = readRESPReply(reader)
+			if err != nil {
+				var e respError
+				if !errors.As(err, &e) {
+					return nil, err
+				}
+				items[i] = e
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected RESP reply type '%c'", line[0])
+	}
+}
+
+// escapeRESPPattern escapes glob special characters in a SCAN pattern
+func escapeRESPPattern(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,259 @@
+package waqi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	bolt "go.etcd.io/bbolt"
+)
+
+// cacheStore is a key-value storage of cache records
+type cacheStore interface {
+	// Get reads a value, missing values are returned as nil
+	Get(key string) ([]byte, error)
+
+	// Put writes a value
+	// Stores which support expiration remove the value after ttl, other ones rely on cache janitor
+	Put(key string, value []byte, ttl time.Duration) error
+
+	// Delete removes values
+	Delete(keys ...string) error
+
+	// Scan calls fn for each stored value until fn returns an error
+	// Key and value must not be retained by fn
+	Scan(fn func(key string, value []byte) error) error
+
+	// Close shuts down store
+	Close() error
+}
+
+// openCacheStore opens a cache store specified by URL:
+//
+//	leveldb:<path> - leveldb database
+//	bbolt:<path> - bbolt database file
+//	memory: - in-process map (nothing is persisted)
+//	redis://[:password@]host[:port][/db][?prefix=...&timeout=...] - Redis-compatible server
+//
+// A URL without a scheme is a path to leveldb database.
+func openCacheStore(url string) (cacheStore, error) {
+	i := strings.Index(url, ":")
+	if i < 0 {
+		return newLevelDBCacheStore(url)
+	}
+
+	scheme := strings.ToLower(url[:i])
+	path := strings.TrimPrefix(url[i+1:], "//")
+	switch scheme {
+	case "leveldb":
+		return newLevelDBCacheStore(path)
+	case "bbolt", "bolt":
+		return newBoltCacheStore(path)
+	case "memory":
+		return newMemoryCacheStore(), nil
+	case "redis":
+		return newRESPCacheStore(url)
+	default:
+		return nil, fmt.Errorf("unsupported cache URL scheme \"%s\"", scheme)
+	}
+}
+
+type levelDBCacheStore struct {
+	db *leveldb.DB
+}
+
+func newLevelDBCacheStore(path string) (cacheStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("missing leveldb cache path")
+	}
+
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelDBCacheStore{db}, nil
+}
+
+// Get reads a value, missing values are returned as nil
+func (s *levelDBCacheStore) Get(key string) ([]byte, error) {
+	value, err := s.db.Get([]byte(key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+
+	return value, err
+}
+
+// Put writes a value
+func (s *levelDBCacheStore) Put(key string, value []byte, _ time.Duration) error {
+	return s.db.Put([]byte(key), value, nil)
+}
+
+// Delete removes values
+func (s *levelDBCacheStore) Delete(keys ...string) error {
+	batch := new(leveldb.Batch)
+	for _, key := range keys {
+		batch.Delete([]byte(key))
+	}
+
+	return s.db.Write(batch, nil)
+}
+
+// Scan calls fn for each stored value until fn returns an error
+func (s *levelDBCacheStore) Scan(fn func(key string, value []byte) error) error {
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		err := fn(string(iter.Key()), iter.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	return iter.Error()
+}
+
+// Close shuts down store
+func (s *levelDBCacheStore) Close() error {
+	return s.db.Close()
+}
+
+// boltCacheBucket is a name of bbolt bucket containing cache records
+var boltCacheBucket = []byte("cache")
+
+type boltCacheStore struct {
+	db *bolt.DB
+}
+
+func newBoltCacheStore(path string) (cacheStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("missing bbolt cache path")
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltCacheBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &boltCacheStore{db}, nil
+}
+
+// Get reads a value, missing values are returned as nil
+func (s *boltCacheStore) Get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// Values are only valid within transaction
+		if raw := tx.Bucket(boltCacheBucket).Get([]byte(key)); raw != nil {
+			value = append([]byte{}, raw...)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// Put writes a value
+func (s *boltCacheStore) Put(key string, value []byte, _ time.Duration) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCacheBucket).Put([]byte(key), value)
+	})
+}
+
+// Delete removes values
+func (s *boltCacheStore) Delete(keys ...string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltCacheBucket)
+		for _, key := range keys {
+			err := bucket.Delete([]byte(key))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan calls fn for each stored value until fn returns an error
+func (s *boltCacheStore) Scan(fn func(key string, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCacheBucket).ForEach(func(key, value []byte) error {
+			return fn(string(key), value)
+		})
+	})
+}
+
+// Close shuts down store
+func (s *boltCacheStore) Close() error {
+	return s.db.Close()
+}
+
+type memoryCacheStore struct {
+	mutex  *sync.RWMutex
+	values map[string][]byte
+}
+
+func newMemoryCacheStore() cacheStore {
+	return &memoryCacheStore{
+		mutex:  &sync.RWMutex{},
+		values: make(map[string][]byte),
+	}
+}
+
+// Get reads a value, missing values are returned as nil
+func (s *memoryCacheStore) Get(key string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.values[key], nil
+}
+
+// Put writes a value
+func (s *memoryCacheStore) Put(key string, value []byte, _ time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.values[key] = append([]byte{}, value...)
+	return nil
+}
+
+// Delete removes values
+func (s *memoryCacheStore) Delete(keys ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(s.values, key)
+	}
+	return nil
+}
+
+// Scan calls fn for each stored value until fn returns an error
+func (s *memoryCacheStore) Scan(fn func(key string, value []byte) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for key, value := range s.values {
+		err := fn(key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close shuts down store
+func (s *memoryCacheStore) Close() error {
+	return nil
+}
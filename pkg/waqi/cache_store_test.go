@@ -0,0 +1,293 @@
+package waqi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheStores(t *testing.T) {
+	server := newFakeRESPServer(t, "secret")
+
+	for _, url := range []string{
+		"leveldb:" + path.Join(t.TempDir(), "cache"),
+		"bbolt:" + path.Join(t.TempDir(), "cache.db"),
+		"memory:",
+		fmt.Sprintf("redis://:secret@%s/2?prefix=test:", server.Addr()),
+	} {
+		t.Run(strings.SplitN(url, ":", 2)[0], func(t *testing.T) {
+			a := assert.New(t)
+
+			store, err := openCacheStore(url)
+			a.Nil(err)
+			defer store.Close()
+
+			value, err := store.Get("missing")
+			a.Nil(err)
+			a.Nil(value)
+
+			a.Nil(store.Put("station/1", []byte("first"), time.Hour))
+			a.Nil(store.Put("station/2", []byte("second"), time.Hour))
+			a.Nil(store.Put("station/3", []byte("third"), time.Hour))
+			a.Nil(store.Put("station/2", []byte("updated"), time.Hour))
+
+			value, err = store.Get("station/2")
+			a.Nil(err)
+			a.Equal([]byte("updated"), value)
+
+			a.Nil(store.Delete("station/1", "station/999"))
+			values := make(map[string]string)
+			err = store.Scan(func(key string, value []byte) error {
+				values[key] = string(value)
+				return nil
+			})
+			a.Nil(err)
+			a.Equal(map[string]string{"station/2": "updated", "station/3": "third"}, values)
+		})
+	}
+
+	// Redis-compatible server should receive prefixed keys with expiration
+	a := assert.New(t)
+	a.Equal([]string{"test:station/2", "test:station/3"}, server.Keys(2))
+	a.Equal(time.Hour, server.TTL(2, "test:station/3"))
+}
+
+func TestOpenCacheStoreErrors(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := openCacheStore("ftp://example.com")
+	a.EqualError(err, "unsupported cache URL scheme \"ftp\"")
+
+	_, err = openCacheStore("leveldb:")
+	a.EqualError(err, "missing leveldb cache path")
+
+	server := newFakeRESPServer(t, "secret")
+	_, err = openCacheStore(fmt.Sprintf("redis://:wrong@%s", server.Addr()))
+	a.EqualError(err, "WRONGPASS invalid password")
+}
+
+func TestCachingServiceAdapterRESP(t *testing.T) {
+	a := assert.New(t)
+
+	server := newFakeRESPServer(t, "")
+	upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42}
+	config := cacheConfig{
+		URL:        fmt.Sprintf("redis://%s", server.Addr()),
+		Source:     "test",
+		SoftTTL:    15 * time.Minute,
+		HardTTL:    time.Hour,
+		MaxEntries: DefaultCacheMaxEntries,
+		MaxSize:    DefaultCacheMaxSize,
+	}
+
+	// Two replicas share the same cache
+	first, err := newCachingServiceAdapter(upstream, config, log.Default())
+	a.Nil(err)
+	defer first.Close()
+	second, err := newCachingServiceAdapter(upstream, config, log.Default())
+	a.Nil(err)
+	defer second.Close()
+
+	status, err := first.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(42), status.AQI)
+
+	status, err = second.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(42), status.AQI)
+	a.Equal(1, upstream.Calls())
+
+	stats, err := second.GetStats()
+	a.Nil(err)
+	a.Equal(3, stats.Entries)
+}
+
+// fakeRESPServer is an in-process server which implements a subset of Redis commands
+type fakeRESPServer struct {
+	listener net.Listener
+	password string
+	mutex    *sync.Mutex
+	values   map[int]map[string]string
+	ttls     map[int]map[string]time.Duration
+}
+
+func newFakeRESPServer(t *testing.T, password string) *fakeRESPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeRESPServer{
+		listener: listener,
+		password: password,
+		mutex:    &sync.Mutex{},
+		values:   make(map[int]map[string]string),
+		ttls:     make(map[int]map[string]time.Duration),
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.Serve(conn)
+		}
+	}()
+
+	return s
+}
+
+// Addr returns server address
+func (s *fakeRESPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Keys returns sorted keys of a database
+func (s *fakeRESPServer) Keys(db int) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := make([]string, 0)
+	for key := range s.values[db] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TTL returns expiration of a key
+func (s *fakeRESPServer) TTL(db int, key string) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.ttls[db][key]
+}
+
+// Serve handles commands of a connection
+func (s *fakeRESPServer) Serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	db := 0
+	authorized := s.password == ""
+	for {
+		request, err := readRESPReply(reader)
+		if err != nil {
+			return
+		}
+
+		items, _ := request.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			raw, _ := item.([]byte)
+			args[i] = string(raw)
+		}
+
+		var reply string
+		switch {
+		case len(args) == 0:
+			reply = "-ERR empty command\r\n"
+		case strings.ToUpper(args[0]) == "AUTH":
+			authorized = args[1] == s.password
+			if authorized {
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authorized:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case strings.ToUpper(args[0]) == "SELECT":
+			db, _ = strconv.Atoi(args[1])
+			reply = "+OK\r\n"
+		default:
+			reply = s.Execute(db, args)
+		}
+
+		_, err = conn.Write([]byte(reply))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Execute runs a data command and returns an encoded reply
+func (s *fakeRESPServer) Execute(db int, args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.values[db] == nil {
+		s.values[db] = make(map[string]string)
+		s.ttls[db] = make(map[string]time.Duration)
+	}
+	values, ttls := s.values[db], s.ttls[db]
+
+	bulk := func(value string, exists bool) string {
+		if !exists {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, exists := values[args[1]]
+		return bulk(value, exists)
+	case "SET":
+		values[args[1]] = args[2]
+		delete(ttls, args[1])
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			ttls[args[1]] = time.Duration(ms) * time.Millisecond
+		}
+		return "+OK\r\n"
+	case "DEL":
+		count := 0
+		for _, key := range args[1:] {
+			if _, exists := values[key]; exists {
+				delete(values, key)
+				delete(ttls, key)
+				count++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", count)
+	case "MGET":
+		reply := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, key := range args[1:] {
+			value, exists := values[key]
+			reply += bulk(value, exists)
+		}
+		return reply
+	case "SCAN":
+		// All matching keys are returned at once, pattern is expected to be "<prefix>*"
+		prefix := strings.TrimSuffix(args[3], "*")
+		keys := make([]string, 0)
+		for key := range values {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+
+		reply := fmt.Sprintf("*2\r\n%s*%d\r\n", bulk("0", true), len(keys))
+		for _, key := range keys {
+			reply += bulk(key, true)
+		}
+		return reply
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
@@ -0,0 +1,286 @@
+package waqi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachingServiceAdapterTTL(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42}
+	cache, err := newTestCachingServiceAdapter(t, upstream, DefaultCacheMaxEntries)
+	a.Nil(err)
+	defer cache.Close()
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	// Cache miss should be fetched synchronously
+	status, err := cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(42), status.AQI)
+	a.False(status.Stale)
+	a.Equal(1, upstream.Calls())
+
+	// Fresh value should be served from cache
+	now = now.Add(10 * time.Minute)
+	upstream.SetAQI(50)
+	status, err = cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(42), status.AQI)
+	a.False(status.Stale)
+	a.Equal(1, upstream.Calls())
+
+	// Stale value should be served immediately and refreshed in background
+	now = now.Add(10 * time.Minute)
+	status, err = cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(42), status.AQI)
+	a.True(status.Stale)
+	cache.background.Wait()
+	a.Equal(2, upstream.Calls())
+
+	status, err = cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(50), status.AQI)
+	a.False(status.Stale)
+	a.Equal(2, upstream.Calls())
+
+	// Expired value should be fetched synchronously
+	now = now.Add(2 * time.Hour)
+	upstream.SetAQI(60)
+	status, err = cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(60), status.AQI)
+	a.False(status.Stale)
+	a.Equal(3, upstream.Calls())
+}
+
+func TestCachingServiceAdapterStaleIfError(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42}
+	cache, err := newTestCachingServiceAdapter(t, upstream, DefaultCacheMaxEntries)
+	a.Nil(err)
+	defer cache.Close()
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	_, err = cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+
+	// Expired value should survive cleanup and be served if service is down
+	now = now.Add(2 * time.Hour)
+	a.Nil(cache.Cleanup(now))
+	upstream.SetError(errors.New("connection refused"))
+	status, err := cache.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(42), status.AQI)
+	a.True(status.Stale)
+
+	// Values older than retention period should not be served
+	now = now.Add(6 * time.Hour)
+	a.Nil(cache.Cleanup(now))
+	_, err = cache.GetByStation(context.Background(), 123)
+	a.EqualError(err, "connection refused")
+
+	// Errors should be returned if there is nothing to serve
+	_, err = cache.GetByStation(context.Background(), 456)
+	a.EqualError(err, "connection refused")
+}
+
+func TestCachingServiceAdapterCoalescing(t *testing.T) {
+	a := assert.New(t)
+
+	gate := make(chan struct{})
+	upstream := &countingAdapter{mutex: &sync.Mutex{}, aqi: 42, gate: gate}
+	cache, err := newTestCachingServiceAdapter(t, upstream, DefaultCacheMaxEntries)
+	a.Nil(err)
+	defer cache.Close()
+
+	const count = 10
+	statuses := make([]*Status, count)
+	errs := make([]error, count)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i], errs[i] = cache.GetByStation(context.Background(), 123)
+		}(i)
+	}
+
+	// Wait until all requests are blocked on the same upstream call
+	key := cache.GetStationKey(123)
+	for cache.flights.Waiters(key) < count-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(gate)
+	wg.Wait()
+
+	a.Equal(1, upstream.Calls())
+	for i := 0; i < count; i++ {
+		a.Nil(errs[i])
+		a.Equal(float32(42), statuses[i].AQI)
+	}
+}
+
+func TestFlightGroupCancellation(t *testing.T) {
+	a := assert.New(t)
+
+	group := newFlightGroup()
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The first caller is cancelled while the second one is waiting for it
+	go func() {
+		_, _ = group.Do(ctx, "key", func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	}()
+	<-started
+
+	go func() {
+		for group.Waiters("key") < 1 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	calls := 0
+	value, err := group.Do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		calls++
+		return 42, nil
+	})
+
+	// The second caller should retry on its own
+	a.Nil(err)
+	a.Equal(42, value)
+	a.Equal(1, calls)
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	a := assert.New(t)
+
+	group := newFlightGroup()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	// The first caller panics while the second one is waiting for it
+	go func() {
+		defer func() {
+			_ = recover()
+		}()
+		_, _ = group.Do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	go func() {
+		for group.Waiters("key") < 1 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+	}()
+
+	_, err := group.Do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return 42, nil
+	})
+	a.Equal(errFlightPanicked, err)
+
+	// Later callers should not be blocked by the panicked call
+	value, err := group.Do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return 42, nil
+	})
+	a.Nil(err)
+	a.Equal(42, value)
+	a.Equal(0, group.Waiters("key"))
+}
+
+// countingAdapter is a fake adapter which counts GetByStation calls
+type countingAdapter struct {
+	adapter
+	mutex *sync.Mutex
+	gate  chan struct{}
+	aqi   float32
+	err   error
+	calls int
+}
+
+func (s *countingAdapter) GetByStation(_ context.Context, stationID int) (*Status, error) {
+	// Block until test lets requests through
+	if s.gate != nil {
+		<-s.gate
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &Status{
+		Station: &Station{ID: stationID, Name: fmt.Sprintf("Station #%d", stationID), Lat: float32(stationID)},
+		AQI:     s.aqi,
+		Level:   CalcAQILevel(s.aqi),
+	}, nil
+}
+
+func (s *countingAdapter) Close() error {
+	return nil
+}
+
+// Calls returns count of GetByStation calls
+func (s *countingAdapter) Calls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls
+}
+
+// SetAQI changes AQI value returned by GetByStation
+func (s *countingAdapter) SetAQI(aqi float32) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.aqi = aqi
+}
+
+// SetError makes GetByStation fail with specified error
+func (s *countingAdapter) SetError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.err = err
+}
+
+// newTestCachingServiceAdapter creates a cachingServiceAdapter with default settings in a temp directory
+func newTestCachingServiceAdapter(t testing.TB, upstream adapter, maxEntries int) (*cachingServiceAdapter, error) {
+	return newCachingServiceAdapter(upstream, cacheConfig{
+		URL:           "leveldb:" + t.TempDir(),
+		Source:        "test",
+		SoftTTL:       15 * time.Minute,
+		HardTTL:       time.Hour,
+		Retention:     6 * time.Hour,
+		MaxEntries:    maxEntries,
+		MaxSize:       DefaultCacheMaxSize,
+		MemoryEntries: DefaultCacheMemoryEntries,
+		MemoryTTL:     DefaultCacheMemoryTTL,
+	}, log.Default())
+}
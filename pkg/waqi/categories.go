@@ -0,0 +1,259 @@
+package waqi
+
+import "time"
+
+// Category is an air quality category (a band of index values) of an AQI standard
+type Category struct {
+	// Common air quality level the category is mapped to
+	Level Level `json:"level"`
+
+	// Category name as defined by the standard
+	Name string `json:"name"`
+
+	// Lowest index value of the category
+	MinIndex float32 `json:"min_index"`
+
+	// Category colour as defined by the standard (hex RGB)
+	Color string `json:"color"`
+}
+
+// standardCategories contains categories of all known AQI standards sorted from best to worst
+// Standards with less than six categories don't use the worst common levels
+var standardCategories = map[Standard][]*Category{
+	USEPAStandard: {
+		{Level: GoodLevel, Name: "Good", MinIndex: 0, Color: "#00E400"},
+		{Level: ModerateLevel, Name: "Moderate", MinIndex: 51, Color: "#FFFF00"},
+		{Level: PossiblyUnhealthyLevel, Name: "Unhealthy for sensitive groups", MinIndex: 101, Color: "#FF7E00"},
+		{Level: UnhealthyLevel, Name: "Unhealthy", MinIndex: 151, Color: "#FF0000"},
+		{Level: VeryUnhealthyLevel, Name: "Very unhealthy", MinIndex: 201, Color: "#8F3F97"},
+		{Level: HazardousLevel, Name: "Hazardous", MinIndex: 301, Color: "#7E0023"},
+	},
+	EUCAQIStandard: {
+		{Level: GoodLevel, Name: "Very low", MinIndex: 0, Color: "#79BC6A"},
+		{Level: ModerateLevel, Name: "Low", MinIndex: 25, Color: "#BBCF4C"},
+		{Level: PossiblyUnhealthyLevel, Name: "Medium", MinIndex: 50, Color: "#EEC20B"},
+		{Level: UnhealthyLevel, Name: "High", MinIndex: 75, Color: "#F29305"},
+		{Level: VeryUnhealthyLevel, Name: "Very high", MinIndex: 100, Color: "#E8416F"},
+	},
+	IndiaNAQIStandard: {
+		{Level: GoodLevel, Name: "Good", MinIndex: 0, Color: "#00B050"},
+		{Level: ModerateLevel, Name: "Satisfactory", MinIndex: 51, Color: "#92D050"},
+		{Level: PossiblyUnhealthyLevel, Name: "Moderate", MinIndex: 101, Color: "#FFFF00"},
+		{Level: UnhealthyLevel, Name: "Poor", MinIndex: 201, Color: "#FF9900"},
+		{Level: VeryUnhealthyLevel, Name: "Very poor", MinIndex: 301, Color: "#FF0000"},
+		{Level: HazardousLevel, Name: "Severe", MinIndex: 401, Color: "#C00000"},
+	},
+	ChinaAQIStandard: {
+		{Level: GoodLevel, Name: "Excellent", MinIndex: 0, Color: "#00E400"},
+		{Level: ModerateLevel, Name: "Good", MinIndex: 51, Color: "#FFFF00"},
+		{Level: PossiblyUnhealthyLevel, Name: "Lightly polluted", MinIndex: 101, Color: "#FF7E00"},
+		{Level: UnhealthyLevel, Name: "Moderately polluted", MinIndex: 151, Color: "#FF0000"},
+		{Level: VeryUnhealthyLevel, Name: "Heavily polluted", MinIndex: 201, Color: "#99004C"},
+		{Level: HazardousLevel, Name: "Severely polluted", MinIndex: 301, Color: "#7E0023"},
+	},
+}
+
+// waqiScalePeriods contains averaging periods of US EPA scales WAQI sub-indices are calculated with
+// Scales are tried in order until sub-index fits one of them
+var waqiScalePeriods = map[Parameter][]time.Duration{
+	PM25Parameter: {24 * time.Hour},
+	PM10Parameter: {24 * time.Hour},
+	O3Parameter:   {8 * time.Hour, time.Hour},
+	NO2Parameter:  {time.Hour},
+	SO2Parameter:  {time.Hour},
+	COParameter:   {8 * time.Hour},
+}
+
+// Categories returns categories of an AQI standard sorted from best to worst
+func (s Standard) Categories() []*Category {
+	return standardCategories[s.OrDefault()]
+}
+
+// Category returns a category of an index value
+func (s Standard) Category(index float32) *Category {
+	categories := s.Categories()
+	if len(categories) == 0 {
+		return nil
+	}
+
+	category := categories[0]
+	for _, c := range categories {
+		if index >= c.MinIndex {
+			category = c
+		}
+	}
+
+	return category
+}
+
+// CalcLevel calculates a common air quality level of an index value
+func (s Standard) CalcLevel(index float32) Level {
+	category := s.Category(index)
+	if category == nil {
+		return CalcAQILevel(index)
+	}
+
+	return category.Level
+}
+
+// LevelName returns a name of a common air quality level as defined by the standard
+func (s Standard) LevelName(level Level) string {
+	for _, category := range s.Categories() {
+		if category.Level == level {
+			return category.Name
+		}
+	}
+
+	return level.String()
+}
+
+// ConvertIndex converts a US EPA sub-index reported by WAQI into a sub-index of the standard
+// Sub-index is converted into a concentration first, so the result is approximate if averaging periods differ.
+// Returns nil if pollutant is not covered by the standard.
+func (s Standard) ConvertIndex(parameter Parameter, index float32) *float32 {
+	if s.OrDefault() == USEPAStandard {
+		return &index
+	}
+
+	concentration, period, ok := getWAQIConcentration(parameter, index)
+	if !ok {
+		return nil
+	}
+
+	// Prefer a scale with the same averaging period
+	scales := make([]*PollutantScale, 0)
+	for _, scale := range s.Scales() {
+		if scale.Parameter != parameter {
+			continue
+		}
+
+		if scale.Period == period {
+			scales = append([]*PollutantScale{scale}, scales...)
+		} else {
+			scales = append(scales, scale)
+		}
+	}
+	if len(scales) == 0 {
+		return nil
+	}
+
+	for _, scale := range scales {
+		value, err := scale.ToIndex(concentration, MicrogramsPerCubicMeterUnit)
+		if err == nil {
+			return &value
+		}
+	}
+
+	// Concentration is above all scales of the standard
+	value := scales[0].topIndex()
+	return &value
+}
+
+// getWAQIConcentration converts a US EPA sub-index reported by WAQI into a concentration (in μg/m3)
+func getWAQIConcentration(parameter Parameter, index float32) (float32, time.Duration, bool) {
+	if index < 0 {
+		return 0, 0, false
+	}
+
+	periods := waqiScalePeriods[parameter]
+	for i, period := range periods {
+		scale, err := USEPAStandard.Scale(parameter, period)
+		if err != nil {
+			continue
+		}
+
+		// Values beyond the AQI are treated as the highest index of the last scale
+		if i == len(periods)-1 && index > scale.topIndex() {
+			index = scale.topIndex()
+		}
+
+		concentration, err := scale.ToConcentration(index, MicrogramsPerCubicMeterUnit)
+		if err == nil {
+			return concentration, period, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// Convert returns a copy of a status with AQI and pollutant values expressed in the standard
+// Overall index is the highest pollutant sub-index, as all supported standards define it.
+// Status is returned in US EPA standard if none of its pollutants is covered by the standard.
+// Statuses which have been already converted are returned as is.
+func (s Standard) Convert(status *Status) *Status {
+	s = s.OrDefault()
+	if status == nil || status.Standard.OrDefault() != USEPAStandard {
+		return status
+	}
+
+	original := *status
+	original.Standard = USEPAStandard
+	if s == USEPAStandard {
+		return &original
+	}
+
+	result := original
+
+	convert := func(parameter Parameter, value *float32) *float32 {
+		if value == nil {
+			return nil
+		}
+		return s.ConvertIndex(parameter, *value)
+	}
+
+	result.PM25 = convert(PM25Parameter, status.PM25)
+	result.PM10 = convert(PM10Parameter, status.PM10)
+	result.O3 = convert(O3Parameter, status.O3)
+	result.NO2 = convert(NO2Parameter, status.NO2)
+	result.SO2 = convert(SO2Parameter, status.SO2)
+	result.CO = convert(COParameter, status.CO)
+
+	var aqi *float32
+	for _, parameter := range Parameters {
+		value := result.Value(parameter)
+		if parameter != AQIParameter && value != nil && (aqi == nil || *value > *aqi) {
+			aqi = value
+		}
+	}
+	if aqi == nil {
+		return &original
+	}
+
+	result.Standard = s
+	result.AQI = *aqi
+	result.Level = s.CalcLevel(*aqi)
+	result.Forecast = s.convertForecast(status.Forecast)
+	return &result
+}
+
+// convertForecast returns a copy of a forecast with pollutant values expressed in the standard
+func (s Standard) convertForecast(forecast *Forecast) *Forecast {
+	if forecast == nil {
+		return nil
+	}
+
+	convert := func(parameter Parameter, value *ForecastValue) *ForecastValue {
+		if value == nil {
+			return nil
+		}
+
+		min, avg, max := s.ConvertIndex(parameter, value.Min), s.ConvertIndex(parameter, value.Avg), s.ConvertIndex(parameter, value.Max)
+		if min == nil || avg == nil || max == nil {
+			return nil
+		}
+		return &ForecastValue{Min: *min, Avg: *avg, Max: *max}
+	}
+
+	result := &Forecast{Days: make([]*ForecastDay, 0, len(forecast.Days))}
+	for _, day := range forecast.Days {
+		result.Days = append(result.Days, &ForecastDay{
+			Date: day.Date,
+			PM25: convert(PM25Parameter, day.PM25),
+			PM10: convert(PM10Parameter, day.PM10),
+			O3:   convert(O3Parameter, day.O3),
+			UVI:  day.UVI,
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,77 @@
+package waqi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandardCategories(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(Level(PossiblyUnhealthyLevel), USEPAStandard.CalcLevel(101))
+	a.Equal("Unhealthy for sensitive groups", USEPAStandard.LevelName(PossiblyUnhealthyLevel))
+
+	a.Equal(Level(ModerateLevel), EUCAQIStandard.CalcLevel(30))
+	a.Equal("Low", EUCAQIStandard.LevelName(ModerateLevel))
+	a.Equal(Level(VeryUnhealthyLevel), EUCAQIStandard.CalcLevel(125))
+
+	a.Equal(Level(UnhealthyLevel), IndiaNAQIStandard.CalcLevel(250))
+	a.Equal("Poor", IndiaNAQIStandard.LevelName(UnhealthyLevel))
+
+	a.Equal("Lightly polluted", ChinaAQIStandard.Category(107).Name)
+
+	// An empty standard is US EPA
+	a.Equal("Hazardous", Standard("").LevelName(HazardousLevel))
+}
+
+func TestCalcAQILevel(t *testing.T) {
+	tests := []struct {
+		aqi      float32
+		expected Level
+	}{
+		{0, GoodLevel},
+		{50, GoodLevel},
+		{51, ModerateLevel},
+		{100, ModerateLevel},
+		{101, PossiblyUnhealthyLevel},
+		{150, PossiblyUnhealthyLevel},
+		{151, UnhealthyLevel},
+		{200, UnhealthyLevel},
+		{201, VeryUnhealthyLevel},
+		{300, VeryUnhealthyLevel},
+		{301, HazardousLevel},
+		{500, HazardousLevel},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.aqi), func(t *testing.T) {
+			a := assert.New(t)
+			a.Equal(test.expected, CalcAQILevel(test.aqi))
+			a.Equal(USEPAStandard.CalcLevel(test.aqi), CalcAQILevel(test.aqi))
+		})
+	}
+}
+
+func TestStandardConvert(t *testing.T) {
+	a := assert.New(t)
+
+	pm25, pm10 := float32(102), float32(20)
+	status := &Status{AQI: 102, Level: PossiblyUnhealthyLevel, PM25: &pm25, PM10: &pm10}
+
+	// PM2.5 sub-index 102 is 35.9 μg/m3 which is "High" on CAQI 24-hour scale
+	converted := EUCAQIStandard.Convert(status)
+	a.Equal(EUCAQIStandard, converted.Standard)
+	a.InDelta(79.9, *converted.PM25, 0.1)
+	a.Equal(*converted.PM25, converted.AQI)
+	a.Equal(Level(UnhealthyLevel), converted.Level)
+
+	// Original status should not be modified, converted one should not be converted again
+	a.Equal(float32(102), status.AQI)
+	a.Equal(Standard(""), status.Standard)
+	a.Equal(converted, IndiaNAQIStandard.Convert(converted))
+
+	a.Equal(USEPAStandard, USEPAStandard.Convert(status).Standard)
+	a.Equal(float32(102), USEPAStandard.Convert(status).AQI)
+}
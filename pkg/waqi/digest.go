@@ -0,0 +1,119 @@
+package waqi
+
+import "time"
+
+// DigestPeriod is a time range summarized by a daily digest
+const DigestPeriod = 24 * time.Hour
+
+// Digest contains a summary of air quality over a period of time
+type Digest struct {
+	// Current air quality status
+	Status *Status `json:"status"`
+
+	// Beginning of summarized period
+	From time.Time `json:"from"`
+
+	// Ending of summarized period
+	To time.Time `json:"to"`
+
+	// Minimal AQI value within period
+	MinAQI float32 `json:"min_aqi"`
+
+	// Maximal AQI value within period
+	MaxAQI float32 `json:"max_aqi"`
+
+	// Average AQI value within period
+	AvgAQI float32 `json:"avg_aqi"`
+
+	// Pollutant with the highest average value within period
+	// Empty if there are no pollutant measurements
+	DominantPollutant Parameter `json:"dominant_pollutant"`
+
+	// Number of measurements within period
+	Samples int `json:"samples"`
+
+	// Summarized measurements, kept to express digest in another standard
+	statuses []*Status
+}
+
+// CalcDigest summarizes measurements within specified time range
+// Current status is always taken into account, so digest is available even if history is empty
+func CalcDigest(status *Status, history []*Status, from, to time.Time) *Digest {
+	statuses := make([]*Status, 0, len(history)+1)
+	for _, s := range history {
+		if !s.Time.Before(from) && !s.Time.After(to) && !s.Time.Equal(status.Time) {
+			statuses = append(statuses, s)
+		}
+	}
+	statuses = append(statuses, status)
+
+	digest := &Digest{
+		Status:   status,
+		From:     from,
+		To:       to,
+		MinAQI:   status.AQI,
+		MaxAQI:   status.AQI,
+		Samples:  len(statuses),
+		statuses: statuses,
+	}
+
+	var aqi historyAverage
+	pollutants := make(map[Parameter]*historyAverage)
+	for _, s := range statuses {
+		if s.AQI < digest.MinAQI {
+			digest.MinAQI = s.AQI
+		}
+		if s.AQI > digest.MaxAQI {
+			digest.MaxAQI = s.AQI
+		}
+		aqi.Add(&s.AQI, 1)
+
+		for _, parameter := range Parameters {
+			if parameter == AQIParameter {
+				continue
+			}
+
+			average, exists := pollutants[parameter]
+			if !exists {
+				average = &historyAverage{}
+				pollutants[parameter] = average
+			}
+			average.Add(s.Value(parameter), 1)
+		}
+	}
+
+	digest.AvgAQI = *aqi.Value()
+
+	// Pollutant values are sub-indices, so they can be compared to each other
+	var dominantValue float32
+	for _, parameter := range Parameters {
+		average, exists := pollutants[parameter]
+		if !exists {
+			continue
+		}
+
+		value := average.Value()
+		if value != nil && (digest.DominantPollutant == "" || *value > dominantValue) {
+			digest.DominantPollutant = parameter
+			dominantValue = *value
+		}
+	}
+
+	return digest
+}
+
+// ConvertDigest returns a digest with values expressed in the standard
+// Overall AQI values can't be converted on their own, so digest is recalculated from converted measurements.
+func (s Standard) ConvertDigest(digest *Digest) *Digest {
+	if digest == nil {
+		return nil
+	}
+
+	status := s.Convert(digest.Status)
+	history := make([]*Status, 0, len(digest.statuses))
+	for _, item := range digest.statuses {
+		history = append(history, s.Convert(item))
+	}
+
+	return CalcDigest(status, history, digest.From, digest.To)
+}
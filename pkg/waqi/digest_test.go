@@ -0,0 +1,77 @@
+package waqi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalcDigest(t *testing.T) {
+	a := assert.New(t)
+
+	to := time.Date(2021, 5, 2, 8, 0, 0, 0, time.UTC)
+	from := to.Add(-DigestPeriod)
+
+	pm10 := float32(70)
+	history := []*Status{
+		historyStatus(123, from.Add(-time.Hour), 300),
+		historyStatus(123, from.Add(time.Hour), 20),
+		historyStatus(123, from.Add(2*time.Hour), 100),
+		historyStatus(123, to, 60),
+	}
+	history[2].PM10 = &pm10
+
+	status := historyStatus(123, to, 60)
+	digest := CalcDigest(status, history, from, to)
+
+	// Measurements outside of period should be ignored, current status should be counted once
+	a.Equal(3, digest.Samples)
+	a.Equal(float32(20), digest.MinAQI)
+	a.Equal(float32(100), digest.MaxAQI)
+	a.Equal(float32(60), digest.AvgAQI)
+	a.Equal(PM10Parameter, digest.DominantPollutant)
+	a.Equal(status, digest.Status)
+
+	// Digest should be available without history
+	digest = CalcDigest(status, nil, from, to)
+	a.Equal(1, digest.Samples)
+	a.Equal(float32(60), digest.MinAQI)
+	a.Equal(float32(60), digest.MaxAQI)
+	a.Equal(float32(60), digest.AvgAQI)
+	a.Equal(PM25Parameter, digest.DominantPollutant)
+}
+
+func TestConvertDigest(t *testing.T) {
+	a := assert.New(t)
+
+	to := time.Date(2021, 5, 2, 8, 0, 0, 0, time.UTC)
+	from := to.Add(-DigestPeriod)
+
+	history := []*Status{
+		historyStatus(123, from.Add(time.Hour), 20),
+		historyStatus(123, from.Add(2*time.Hour), 102),
+	}
+	status := historyStatus(123, to, 60)
+	digest := EUCAQIStandard.ConvertDigest(CalcDigest(status, history, from, to))
+
+	// Min and max values should be recalculated from converted measurements
+	a.Equal(3, digest.Samples)
+	a.Equal(EUCAQIStandard, digest.Status.Standard)
+	a.Equal(*EUCAQIStandard.ConvertIndex(PM25Parameter, 20), digest.MinAQI)
+	a.InDelta(79.9, digest.MaxAQI, 0.1)
+	a.Equal(PM25Parameter, digest.DominantPollutant)
+
+	a.Nil(EUCAQIStandard.ConvertDigest(nil))
+}
+
+func TestNextDigestTime(t *testing.T) {
+	a := assert.New(t)
+
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	now := time.Date(2021, 5, 2, 4, 30, 0, 0, time.UTC) // 07:30 in UTC+3
+
+	a.True(time.Date(2021, 5, 2, 8, 0, 0, 0, loc).Equal(nextDigestTime(now, 8*60, loc)))
+	a.True(time.Date(2021, 5, 3, 7, 0, 0, 0, loc).Equal(nextDigestTime(now, 7*60, loc)))
+	a.True(time.Date(2021, 5, 3, 7, 30, 0, 0, loc).Equal(nextDigestTime(now, 7*60+30, loc)))
+}
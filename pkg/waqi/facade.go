@@ -1,7 +1,9 @@
 package waqi
 
 import (
+	"context"
 	"log"
+	"net/http"
 	"strings"
 	"time"
 )
@@ -12,19 +14,57 @@ const (
 
 	// DefaultCacheDuration is default WAQI service cache duration
 	DefaultCacheDuration = 15 * time.Minute
+
+	// DefaultCacheMaxAge is default max age of cached values which are served while WAQI service is being queried
+	DefaultCacheMaxAge = time.Hour
+
+	// DefaultCacheRetention is default max age of cached values which are served while WAQI service is unavailable
+	DefaultCacheRetention = 24 * time.Hour
+
+	// DefaultRequestTimeout is default timeout of WAQI service requests
+	DefaultRequestTimeout = 15 * time.Second
 )
 
 type options struct {
-	URL           string
-	Token         string
-	CachePath     string
-	CacheDuration time.Duration
-	Logger        *log.Logger
+	URL                    string
+	Token                  string
+	HTTPClient             *http.Client
+	RequestTimeout         time.Duration
+	RetryAttempts          int
+	RetryDelay             time.Duration
+	BreakerThreshold       int
+	BreakerCooldown        time.Duration
+	CachePath              string
+	CacheURL               string
+	CacheDuration          time.Duration
+	CacheMaxAge            time.Duration
+	CacheRetention         time.Duration
+	CacheMaxEntries        int
+	CacheMaxSize           int64
+	CacheMemoryEntries     int
+	CacheMemoryTTL         time.Duration
+	PollInterval           time.Duration
+	PollMinInterval        time.Duration
+	PollMaxInterval        time.Duration
+	FetchWorkers           int
+	FetchRate              float64
+	FetchBurst             int
+	StaleStationThreshold  time.Duration
+	HistoryPath            string
+	HistoryRetention       time.Duration
+	HistoryDownsampleAfter time.Duration
+	Logger                 *log.Logger
 }
 
 // Normalize normalizes options
 func (opts *options) Normalize() {
 	opts.URL = strings.TrimRight(opts.URL, "/")
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = DefaultRequestTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = newHTTPClient(opts.RequestTimeout)
+	}
 }
 
 // Option is a configuration option for NewServer function
@@ -44,6 +84,50 @@ func TokenOption(token string) Option {
 	}
 }
 
+// HTTPClientOption sets HTTP client for WAQI service requests
+// If not set, a client with RequestTimeoutOption timeout is created
+func HTTPClientOption(client *http.Client) Option {
+	return func(opts *options) {
+		opts.HTTPClient = client
+	}
+}
+
+// RequestTimeoutOption sets timeout of WAQI service requests
+func RequestTimeoutOption(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.RequestTimeout = timeout
+	}
+}
+
+// RetryAttemptsOption sets max count of attempts of a single WAQI service request
+func RetryAttemptsOption(attempts int) Option {
+	return func(opts *options) {
+		opts.RetryAttempts = attempts
+	}
+}
+
+// RetryDelayOption sets delay before the first retry of a failed request
+// Subsequent retries are delayed exponentially
+func RetryDelayOption(delay time.Duration) Option {
+	return func(opts *options) {
+		opts.RetryDelay = delay
+	}
+}
+
+// CircuitBreakerThresholdOption sets count of consecutive failures after which WAQI service calls are suspended
+func CircuitBreakerThresholdOption(threshold int) Option {
+	return func(opts *options) {
+		opts.BreakerThreshold = threshold
+	}
+}
+
+// CircuitBreakerCooldownOption sets duration for which WAQI service calls are suspended
+func CircuitBreakerCooldownOption(cooldown time.Duration) Option {
+	return func(opts *options) {
+		opts.BreakerCooldown = cooldown
+	}
+}
+
 // CachePathOption sets path to cache file
 func CachePathOption(path string) Option {
 	return func(opts *options) {
@@ -51,13 +135,137 @@ func CachePathOption(path string) Option {
 	}
 }
 
+// CacheURLOption sets cache store URL
+// Supported stores are "leveldb:<path>", "bbolt:<path>", "memory:" and "redis://[:password@]host[:port][/db][?prefix=...]"
+// It takes precedence over CachePathOption
+func CacheURLOption(url string) Option {
+	return func(opts *options) {
+		opts.CacheURL = url
+	}
+}
+
 // CacheDurationOption sets max cache duration
+// Older cached values are considered stale
 func CacheDurationOption(duration time.Duration) Option {
 	return func(opts *options) {
 		opts.CacheDuration = duration
 	}
 }
 
+// CacheMaxAgeOption sets max age of stale cached values which are served immediately while being refreshed in background
+// Older values are refreshed synchronously, and are served only if WAQI service is unavailable
+func CacheMaxAgeOption(duration time.Duration) Option {
+	return func(opts *options) {
+		opts.CacheMaxAge = duration
+	}
+}
+
+// CacheRetentionOption sets how long cached values are kept
+// Values older than max age are served only if WAQI service is unavailable, so they are kept for a while longer
+func CacheRetentionOption(duration time.Duration) Option {
+	return func(opts *options) {
+		opts.CacheRetention = duration
+	}
+}
+
+// CacheMaxEntriesOption sets max count of cached entries
+// Least recently used entries are evicted when limit is exceeded
+func CacheMaxEntriesOption(count int) Option {
+	return func(opts *options) {
+		opts.CacheMaxEntries = count
+	}
+}
+
+// CacheMaxSizeOption sets max total size of cached entries (in bytes)
+// Least recently used entries are evicted when limit is exceeded
+func CacheMaxSizeOption(size int64) Option {
+	return func(opts *options) {
+		opts.CacheMaxSize = size
+	}
+}
+
+// CacheMemoryEntriesOption sets max count of cached entries kept in memory
+// Zero value disables in-memory cache tier
+func CacheMemoryEntriesOption(count int) Option {
+	return func(opts *options) {
+		opts.CacheMemoryEntries = count
+	}
+}
+
+// CacheMemoryTTLOption sets time to live of cached entries kept in memory
+func CacheMemoryTTLOption(ttl time.Duration) Option {
+	return func(opts *options) {
+		opts.CacheMemoryTTL = ttl
+	}
+}
+
+// PollIntervalOption sets interval between station updates
+func PollIntervalOption(interval time.Duration) Option {
+	return func(opts *options) {
+		opts.PollInterval = interval
+	}
+}
+
+// PollMinIntervalOption sets interval between updates of stations which data changes rapidly or is close to change its level
+func PollMinIntervalOption(interval time.Duration) Option {
+	return func(opts *options) {
+		opts.PollMinInterval = interval
+	}
+}
+
+// PollMaxIntervalOption sets max interval between updates of stations which data is outdated
+func PollMaxIntervalOption(interval time.Duration) Option {
+	return func(opts *options) {
+		opts.PollMaxInterval = interval
+	}
+}
+
+// FetchWorkersOption sets count of stations which are updated concurrently
+func FetchWorkersOption(count int) Option {
+	return func(opts *options) {
+		opts.FetchWorkers = count
+	}
+}
+
+// FetchRateOption sets max rate of station updates (requests per second) and max size of a burst of updates
+// Zero rate disables limiting
+func FetchRateOption(rate float64, burst int) Option {
+	return func(opts *options) {
+		opts.FetchRate = rate
+		opts.FetchBurst = burst
+	}
+}
+
+// StaleStationThresholdOption sets age of station data after which subscribers are notified that station is offline
+// Zero value disables notifications
+func StaleStationThresholdOption(threshold time.Duration) Option {
+	return func(opts *options) {
+		opts.StaleStationThreshold = threshold
+	}
+}
+
+// HistoryPathOption sets path to history store
+// History is not recorded if path is empty
+func HistoryPathOption(path string) Option {
+	return func(opts *options) {
+		opts.HistoryPath = path
+	}
+}
+
+// HistoryRetentionOption sets retention period for historical measurements
+func HistoryRetentionOption(duration time.Duration) Option {
+	return func(opts *options) {
+		opts.HistoryRetention = duration
+	}
+}
+
+// HistoryDownsampleAfterOption sets age after which historical measurements are downsampled to hourly averages
+func HistoryDownsampleAfterOption(duration time.Duration) Option {
+	return func(opts *options) {
+		opts.HistoryDownsampleAfter = duration
+	}
+}
+
 // LoggerOption sets logger instance
 func LoggerOption(logger *log.Logger) Option {
 	return func(opts *options) {
@@ -68,9 +276,29 @@ func LoggerOption(logger *log.Logger) Option {
 // NewService creates new instance of Service
 func NewService(fn ...Option) (Service, error) {
 	opts := &options{
-		URL:           DefaultURL,
-		CacheDuration: DefaultCacheDuration,
-		Logger:        log.Default(),
+		URL:                    DefaultURL,
+		RequestTimeout:         DefaultRequestTimeout,
+		RetryAttempts:          DefaultRetryAttempts,
+		RetryDelay:             DefaultRetryDelay,
+		BreakerThreshold:       DefaultCircuitBreakerThreshold,
+		BreakerCooldown:        DefaultCircuitBreakerCooldown,
+		CacheDuration:          DefaultCacheDuration,
+		CacheMaxAge:            DefaultCacheMaxAge,
+		CacheRetention:         DefaultCacheRetention,
+		CacheMaxEntries:        DefaultCacheMaxEntries,
+		CacheMaxSize:           DefaultCacheMaxSize,
+		CacheMemoryEntries:     DefaultCacheMemoryEntries,
+		CacheMemoryTTL:         DefaultCacheMemoryTTL,
+		PollInterval:           DefaultPollInterval,
+		PollMinInterval:        DefaultMinPollInterval,
+		PollMaxInterval:        DefaultMaxPollInterval,
+		FetchWorkers:           DefaultFetchWorkers,
+		FetchRate:              DefaultFetchRate,
+		FetchBurst:             DefaultFetchBurst,
+		StaleStationThreshold:  DefaultStaleStationThreshold,
+		HistoryRetention:       DefaultHistoryRetention,
+		HistoryDownsampleAfter: DefaultHistoryDownsampleAfter,
+		Logger:                 log.Default(),
 	}
 	for _, f := range fn {
 		f(opts)
@@ -78,40 +306,107 @@ func NewService(fn ...Option) (Service, error) {
 
 	opts.Normalize()
 
-	adapter := newServiceAdapter(opts.URL, opts.Token, opts.Logger)
-	if opts.CachePath != "" {
+	adapter := newServiceAdapter(opts.URL, opts.Token, opts.HTTPClient, opts.Logger)
+	adapter = newResilientServiceAdapter(adapter, opts.RetryAttempts, opts.RetryDelay, opts.BreakerThreshold, opts.BreakerCooldown, opts.Logger)
+
+	var history historyStore
+	if opts.HistoryPath != "" {
+		var err error
+		history, err = newLevelDBHistoryStore(opts.HistoryPath, opts.HistoryRetention, opts.HistoryDownsampleAfter, opts.Logger)
+		if err != nil {
+			return nil, err
+		}
+
+		adapter = newRecordingServiceAdapter(adapter, history, opts.Logger)
+	}
+
+	var cache *cachingServiceAdapter
+	if opts.CacheURL != "" || opts.CachePath != "" {
+		url := opts.CacheURL
+		if url == "" {
+			url = "leveldb:" + opts.CachePath
+		}
+
 		var err error
-		adapter, err = newCachingServiceAdapter(adapter, opts.CachePath, opts.CacheDuration)
+		cache, err = newCachingServiceAdapter(adapter, cacheConfig{
+			URL:           url,
+			Source:        opts.URL,
+			SoftTTL:       opts.CacheDuration,
+			HardTTL:       opts.CacheMaxAge,
+			Retention:     opts.CacheRetention,
+			MaxEntries:    opts.CacheMaxEntries,
+			MaxSize:       opts.CacheMaxSize,
+			MemoryEntries: opts.CacheMemoryEntries,
+			MemoryTTL:     opts.CacheMemoryTTL,
+		}, opts.Logger)
 		if err != nil {
+			if history != nil {
+				_ = history.Close()
+			}
 			return nil, err
 		}
+
+		adapter = cache
 	}
 
+	policy := newPollPolicy(opts.PollInterval, opts.PollMinInterval, opts.PollMaxInterval)
+	limiter := newTokenBucket(opts.FetchRate, opts.FetchBurst)
+
 	s := &service{
-		adapter: adapter,
-		fetcher: newFetcher(adapter, opts.Logger),
+		adapter:   adapter,
+		cache:     cache,
+		history:   history,
+		fetcher:   newFetcher(adapter, policy, opts.FetchWorkers, limiter, opts.StaleStationThreshold, opts.Logger),
+		scheduler: newScheduler(adapter, history, opts.Logger),
 	}
 	return s, nil
 }
 
 type service struct {
-	adapter adapter
-	fetcher *fetcher
+	adapter   adapter
+	cache     *cachingServiceAdapter
+	history   historyStore
+	fetcher   *fetcher
+	scheduler *scheduler
 }
 
 // GetByCity fetches current measurements for city
-func (s *service) GetByCity(city string) (*Status, error) {
-	return s.adapter.GetByCity(city)
+func (s *service) GetByCity(ctx context.Context, city string) (*Status, error) {
+	return s.adapter.GetByCity(ctx, city)
 }
 
 // GetByStation fetches current measurements for station
-func (s *service) GetByStation(stationID int) (*Status, error) {
-	return s.adapter.GetByStation(stationID)
+func (s *service) GetByStation(ctx context.Context, stationID int) (*Status, error) {
+	return s.adapter.GetByStation(ctx, stationID)
 }
 
 // GetByGeo fetches current measurements for geo coordinates
-func (s *service) GetByGeo(lat, lon float32) (*Status, error) {
-	return s.adapter.GetByGeo(lat, lon)
+func (s *service) GetByGeo(ctx context.Context, lat, lon float32) (*Status, error) {
+	return s.adapter.GetByGeo(ctx, lat, lon)
+}
+
+// Search looks up stations by a keyword (e.g. city name)
+func (s *service) Search(ctx context.Context, keyword string) ([]*StationSummary, error) {
+	return s.adapter.Search(ctx, keyword)
+}
+
+// GetByBounds returns stations located within specified rectangle
+func (s *service) GetByBounds(ctx context.Context, bounds Bounds) ([]*StationSummary, error) {
+	return s.adapter.GetByBounds(ctx, bounds)
+}
+
+// GetNearby returns up to count stations nearest to specified geo coordinates, ordered by distance
+func (s *service) GetNearby(ctx context.Context, lat, lon float32, count int) ([]*NearbyStation, error) {
+	return getNearbyStations(ctx, s.adapter, lat, lon, count)
+}
+
+// GetHistory returns historical measurements of a station within specified time range, ordered by time
+func (s *service) GetHistory(stationID int, from, to time.Time) ([]*Status, error) {
+	if s.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+
+	return s.history.Query(stationID, from, to)
 }
 
 // Subscribe adds a listener to updates
@@ -124,17 +419,60 @@ func (s *service) Unsubscribe(stationID int, listener Listener) {
 	s.fetcher.Unsubscribe(stationID, listener)
 }
 
+// IsOffline returns true if a subscribed station is considered offline
+func (s *service) IsOffline(stationID int) bool {
+	return s.fetcher.IsOffline(stationID)
+}
+
+// ScheduleDigest schedules a daily digest of a station
+func (s *service) ScheduleDigest(key string, stationID int, timeOfDay int, loc *time.Location, listener DigestListener) {
+	s.scheduler.Schedule(key, stationID, timeOfDay, loc, listener)
+}
+
+// UnscheduleDigest removes a daily digest schedule
+func (s *service) UnscheduleDigest(key string) {
+	s.scheduler.Unschedule(key)
+}
+
 // StartUpdates starts background data updates
 func (s *service) StartUpdates() {
 	s.fetcher.StartUpdates()
+	s.scheduler.Start()
 }
 
 // StopUpdates stops background data updates
 func (s *service) StopUpdates() {
+	s.scheduler.Stop()
 	s.fetcher.StopUpdates()
 }
 
+// GetUpdateReport returns a report of the last run of background station updates
+func (s *service) GetUpdateReport() *UpdateReport {
+	return s.fetcher.GetLastReport()
+}
+
+// GetCacheStats returns cache usage statistics
+func (s *service) GetCacheStats() (*CacheStats, error) {
+	if s.cache == nil {
+		return &CacheStats{}, nil
+	}
+
+	return s.cache.GetStats()
+}
+
 // Close shuts down service
 func (s *service) Close() error {
-	return s.adapter.Close()
+	err := s.adapter.Close()
+	if err != nil {
+		return err
+	}
+
+	if s.history != nil {
+		err = s.history.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
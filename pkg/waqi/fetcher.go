@@ -1,11 +1,27 @@
 package waqi
 
 import (
+	"context"
 	"log"
+	"math/rand"
 	"sync"
 	"time"
 )
 
+const (
+	// DefaultFetchWorkers is default count of stations which are updated concurrently
+	DefaultFetchWorkers = 8
+
+	// DefaultStaleStationThreshold is default age of station data after which station is considered offline
+	DefaultStaleStationThreshold = 3 * time.Hour
+)
+
+// stationRefresher is implemented by adapters which are able to bypass their caches
+type stationRefresher interface {
+	// RefreshStation fetches current measurements for station regardless of cached ones
+	RefreshStation(ctx context.Context, stationID int) (*Status, error)
+}
+
 type fetcher struct {
 	adapter           adapter
 	logger            *log.Logger
@@ -13,19 +29,35 @@ type fetcher struct {
 	mutex             *sync.Mutex
 	isRunning         bool
 	areUpdatesRunning bool
-	sleepDuration     time.Duration
+	policy            pollPolicy
+	workers           int
+	limiter           *tokenBucket
+	staleThreshold    time.Duration
+	now               func() time.Time
+	spread            func(time.Duration) time.Duration
+	lastReport        *UpdateReport
 	ticker            *time.Ticker
+	cancel            context.CancelFunc
 	done              chan bool
 }
 
-func newFetcher(adapter adapter, logger *log.Logger) *fetcher {
+func newFetcher(adapter adapter, policy pollPolicy, workers int, limiter *tokenBucket, staleThreshold time.Duration, logger *log.Logger) *fetcher {
+	if workers < 1 {
+		workers = 1
+	}
+
 	f := &fetcher{
-		adapter:       adapter,
-		logger:        logger,
-		listeners:     make(map[int]*stationFetcher),
-		mutex:         &sync.Mutex{},
-		sleepDuration: 10 * time.Minute,
-		done:          make(chan bool),
+		adapter:        adapter,
+		logger:         logger,
+		listeners:      make(map[int]*stationFetcher),
+		mutex:          &sync.Mutex{},
+		policy:         policy,
+		workers:        workers,
+		limiter:        limiter,
+		staleThreshold: staleThreshold,
+		now:            time.Now,
+		spread:         randomSpread,
+		done:           make(chan bool),
 	}
 
 	return f
@@ -38,15 +70,27 @@ func (f *fetcher) Subscribe(stationID int, listener Listener) {
 
 	listeners, exists := f.listeners[stationID]
 	if !exists {
-		listeners = newStationFetcher(f.adapter, stationID)
+		listeners = newStationFetcher(f.adapter, stationID, f.policy, f.staleThreshold, f.now)
 		f.listeners[stationID] = listeners
 
+		// Stations subscribed at once (e.g. on startup) shouldn't be updated at once
+		listeners.SetNextUpdate(f.now().Add(f.spread(listeners.Delay())))
+
 		log.Printf("subscribed to station #%d", stationID)
 	}
 
 	listeners.Subscribe(listener)
 }
 
+// IsOffline returns true if a subscribed station is considered offline
+func (f *fetcher) IsOffline(stationID int) bool {
+	f.mutex.Lock()
+	listeners, exists := f.listeners[stationID]
+	f.mutex.Unlock()
+
+	return exists && listeners.IsOffline()
+}
+
 // Unsubscribe removes a listener
 func (f *fetcher) Unsubscribe(stationID int, listener Listener) {
 	f.mutex.Lock()
@@ -71,9 +115,11 @@ func (f *fetcher) StartUpdates() {
 	defer f.mutex.Unlock()
 
 	if f.ticker == nil {
-		f.ticker = time.NewTicker(f.sleepDuration)
-		log.Printf("starting background updates with period of %s", f.sleepDuration)
-		go f.UpdateLoop()
+		var ctx context.Context
+		ctx, f.cancel = context.WithCancel(context.Background())
+		f.ticker = time.NewTicker(f.policy.TickPeriod())
+		log.Printf("starting background updates with period of %s (%s to %s)", f.policy.Interval, f.policy.Min, f.policy.Max)
+		go f.UpdateLoop(ctx, f.ticker)
 	}
 }
 
@@ -86,28 +132,112 @@ func (f *fetcher) StopUpdates() {
 		f.ticker.Stop()
 		f.ticker = nil
 
+		// Abort pending requests so that update loop would stop promptly
+		f.cancel()
 		f.done <- true
 	}
 }
 
 // UpdateLoop runs background update loop
-func (f *fetcher) UpdateLoop() {
+func (f *fetcher) UpdateLoop(ctx context.Context, ticker *time.Ticker) {
 	for {
 		select {
 		case <-f.done:
 			return
-		case <-f.ticker.C:
-			f.UpdateOnce()
+		case <-ticker.C:
+			f.UpdateOnce(ctx)
 		}
 	}
 }
 
-// UpdateOnce runs a single update
-func (f *fetcher) UpdateOnce() {
+// UpdateOnce updates stations which are due to be updated
+// Stations are updated concurrently by a limited number of workers, and update rate is limited as well
+func (f *fetcher) UpdateOnce(ctx context.Context) *UpdateReport {
+	started := time.Now()
 	subscriptions := f.GetCurrentListeners()
+	report := &UpdateReport{
+		Time:           f.now(),
+		Stations:       len(subscriptions),
+		FailedStations: make([]int, 0),
+	}
+
+	queue := make(chan *stationFetcher, len(subscriptions))
 	for _, listeners := range subscriptions {
-		listeners.Update()
+		listeners.PushPendingAvailability()
+		if listeners.IsDue(report.Time) {
+			queue <- listeners
+			report.Due++
+		}
+	}
+	close(queue)
+
+	workers := f.workers
+	if workers > report.Due {
+		workers = report.Due
+	}
+
+	mutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for listeners := range queue {
+				// Stations which are left over when update loop is stopped are neither updated nor failed
+				err := f.limiter.Wait(ctx)
+				if err != nil {
+					return
+				}
+
+				err = listeners.Update(ctx)
+
+				mutex.Lock()
+				if err != nil {
+					report.Failed++
+					report.FailedStations = append(report.FailedStations, listeners.stationID)
+				} else {
+					report.Updated++
+				}
+				mutex.Unlock()
+			}
+		}()
 	}
+	wg.Wait()
+
+	report.Duration = time.Since(started)
+
+	// Most ticks have nothing to update, their reports would hide the last actual run
+	if report.Due > 0 {
+		f.SetLastReport(report)
+	}
+	return report
+}
+
+// SetLastReport stores and logs a report of an update run
+func (f *fetcher) SetLastReport(report *UpdateReport) {
+	f.mutex.Lock()
+	f.lastReport = report
+	f.mutex.Unlock()
+
+	log.Printf(
+		"updated %d of %d station(s) in %s, %d failed %v",
+		report.Updated, report.Due, report.Duration, report.Failed, report.FailedStations)
+
+	if period := f.policy.TickPeriod(); report.Duration > period {
+		log.Printf("update run took %s, which is longer than update period of %s", report.Duration, period)
+	}
+}
+
+// GetLastReport returns a report of the last update run
+func (f *fetcher) GetLastReport() *UpdateReport {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.lastReport == nil {
+		return &UpdateReport{FailedStations: make([]int, 0)}
+	}
+
+	return f.lastReport
 }
 
 // GetCurrentListeners returns a current set of listeners
@@ -124,22 +254,33 @@ func (f *fetcher) GetCurrentListeners() map[int]*stationFetcher {
 }
 
 type stationFetcher struct {
-	adapter    adapter
-	stationID  int
-	listeners  []Listener
-	mutex      *sync.Mutex
-	prevStatus *Status
+	adapter        adapter
+	stationID      int
+	listeners      []Listener
+	mutex          *sync.Mutex
+	prevStatus     *Status
+	policy         pollPolicy
+	staleThreshold time.Duration
+	now            func() time.Time
+	delay          time.Duration
+	nextUpdate     time.Time
+	offlineSince   time.Time
+	pending        []Listener
 }
 
-func newStationFetcher(adapter adapter, stationID int) *stationFetcher {
+func newStationFetcher(adapter adapter, stationID int, policy pollPolicy, staleThreshold time.Duration, now func() time.Time) *stationFetcher {
 	f := &stationFetcher{
-		adapter:   adapter,
-		stationID: stationID,
-		listeners: make([]Listener, 0),
-		mutex:     &sync.Mutex{},
+		adapter:        adapter,
+		stationID:      stationID,
+		listeners:      make([]Listener, 0),
+		mutex:          &sync.Mutex{},
+		policy:         policy,
+		staleThreshold: staleThreshold,
+		now:            now,
+		delay:          policy.Interval,
 	}
 
-	f.Update()
+	f.Update(context.Background())
 
 	return f
 }
@@ -153,11 +294,16 @@ func (f *stationFetcher) Empty() bool {
 }
 
 // Subscribe adds a listener
+// Listeners subscribed to an offline station (e.g. restored on startup) are notified about it on the next update run,
+// since the station went offline before they could receive the notification.
 func (f *stationFetcher) Subscribe(listener Listener) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
 	f.listeners = append(f.listeners, listener)
+	if _, ok := listener.(AvailabilityListener); ok && !f.offlineSince.IsZero() {
+		f.pending = append(f.pending, listener)
+	}
 }
 
 // Unsubscribe removes a listener
@@ -165,34 +311,170 @@ func (f *stationFetcher) Unsubscribe(listener Listener) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
+	for i := range f.pending {
+		if f.pending[i] == listener {
+			f.pending = append(f.pending[:i], f.pending[i+1:]...)
+			break
+		}
+	}
+
 	for i := range f.listeners {
 		if f.listeners[i] == listener {
-			for j := i; j < len(f.listeners); j++ {
-				f.listeners[j-1] = f.listeners[j]
-			}
+			f.listeners = append(f.listeners[:i], f.listeners[i+1:]...)
+			return
+		}
+	}
+}
 
-			f.listeners = f.listeners[0 : len(f.listeners)-1]
+// PushPendingAvailability notifies listeners subscribed to an offline station that it is offline
+func (f *stationFetcher) PushPendingAvailability() {
+	f.mutex.Lock()
+	pending, status, since := f.pending, f.prevStatus, f.offlineSince
+	f.pending = nil
+	f.mutex.Unlock()
+
+	for _, listener := range pending {
+		err := listener.(AvailabilityListener).StationOffline(status, since)
+		if err != nil {
+			log.Printf("unable to push availability of station #%d to listener: %s", f.stationID, err)
 		}
 	}
 }
 
+// SetNextUpdate changes time of the next update
+func (f *stationFetcher) SetNextUpdate(t time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.nextUpdate = t
+}
+
+// IsDue returns true if station should be updated
+func (f *stationFetcher) IsDue(now time.Time) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return !now.Before(f.nextUpdate)
+}
+
+// Delay returns current interval between station updates
+func (f *stationFetcher) Delay() time.Duration {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.delay
+}
+
 // Update fetches new value and pushes it to listeners
-func (f *stationFetcher) Update() {
-	status, err := f.adapter.GetByStation(f.stationID)
+func (f *stationFetcher) Update(ctx context.Context) error {
+	f.mutex.Lock()
+	prevStatus := f.prevStatus
+	f.mutex.Unlock()
+
+	status, err := f.Fetch(ctx, prevStatus)
+	f.Reschedule(prevStatus, status)
 	if err != nil {
 		log.Printf("unable to get data for station #%d: %s", f.stationID, err)
-		return
+		return err
 	}
 
-	prevStatus := f.prevStatus
+	f.mutex.Lock()
 	f.prevStatus = status
+	f.mutex.Unlock()
+	f.CheckAvailability(status)
 
-	if prevStatus == nil || prevStatus.Equal(status) || prevStatus.Level == status.Level {
-		return
+	if prevStatus == nil || prevStatus.Equal(status) {
+		return nil
 	}
 
 	log.Printf("data for station #%d has been updated", f.stationID)
 	f.PushToListeners(status, prevStatus)
+	return nil
+}
+
+// Fetch fetches current measurements
+// Initial value might be a cached one, but subsequent updates bypass cache if possible
+func (f *stationFetcher) Fetch(ctx context.Context, prevStatus *Status) (*Status, error) {
+	if refresher, ok := f.adapter.(stationRefresher); ok && prevStatus != nil {
+		return refresher.RefreshStation(ctx, f.stationID)
+	}
+
+	return f.adapter.GetByStation(ctx, f.stationID)
+}
+
+// Reschedule computes time of the next update
+func (f *stationFetcher) Reschedule(prevStatus, status *Status) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.now()
+	delay := f.policy.Next(prevStatus, status, f.delay, now)
+	if delay != f.delay {
+		log.Printf("station #%d will be updated every %s", f.stationID, delay)
+	}
+
+	f.delay = delay
+	f.nextUpdate = now.Add(delay)
+}
+
+// IsOffline returns true if station is considered offline
+func (f *stationFetcher) IsOffline() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return !f.offlineSince.IsZero()
+}
+
+// CheckAvailability notifies listeners when station stops reporting new data and when it resumes
+func (f *stationFetcher) CheckAvailability(status *Status) {
+	// Stale statuses come from a fallback, so they don't tell anything about the station
+	if f.staleThreshold <= 0 || status.Stale || status.Time.IsZero() {
+		return
+	}
+
+	isOffline := f.now().Sub(status.Time) >= f.staleThreshold
+
+	f.mutex.Lock()
+	since := f.offlineSince
+	switch {
+	case isOffline && since.IsZero():
+		f.offlineSince = status.Time
+	case !isOffline && !since.IsZero():
+		// Listeners which haven't been told station was offline are not told it is back online either
+		f.offlineSince = time.Time{}
+		f.pending = nil
+	default:
+		f.mutex.Unlock()
+		return
+	}
+	f.mutex.Unlock()
+
+	if isOffline {
+		log.Printf("station #%d is offline since %s", f.stationID, status.Time)
+		f.PushToAvailabilityListeners(func(listener AvailabilityListener) error {
+			return listener.StationOffline(status, status.Time)
+		})
+	} else {
+		log.Printf("station #%d is back online", f.stationID)
+		f.PushToAvailabilityListeners(func(listener AvailabilityListener) error {
+			return listener.StationOnline(status, since)
+		})
+	}
+}
+
+// PushToAvailabilityListeners pushes a station availability change to listeners which are interested in it
+func (f *stationFetcher) PushToAvailabilityListeners(fn func(listener AvailabilityListener) error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, listener := range f.listeners {
+		if listener, ok := listener.(AvailabilityListener); ok {
+			err := fn(listener)
+			if err != nil {
+				log.Printf("unable to push availability of station #%d to listener: %s", f.stationID, err)
+			}
+		}
+	}
 }
 
 // PushToListeners pushes new status to listeners
@@ -207,3 +489,12 @@ func (f *stationFetcher) PushToListeners(newStatus, prevStatus *Status) {
 		}
 	}
 }
+
+// randomSpread returns a random duration within [0, d)
+func randomSpread(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d)))
+}
@@ -0,0 +1,267 @@
+package waqi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetcherUpdateOnce(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &concurrentAdapter{mutex: &sync.Mutex{}, delay: 20 * time.Millisecond, failing: map[int]bool{}}
+	f := newFetcher(upstream, newPollPolicy(time.Minute, time.Minute, time.Minute), 4, newTokenBucket(0, 0), 0, nil)
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	f.spread = func(time.Duration) time.Duration { return 0 }
+
+	for stationID := 1; stationID <= 8; stationID++ {
+		f.Subscribe(stationID, &fakeListener{})
+	}
+	upstream.Reset()
+	upstream.failing[3] = true
+
+	report := f.UpdateOnce(context.Background())
+	a.Equal(8, report.Stations)
+	a.Equal(8, report.Due)
+	a.Equal(7, report.Updated)
+	a.Equal(1, report.Failed)
+	a.Equal([]int{3}, report.FailedStations)
+	a.Equal(4, upstream.MaxConcurrency())
+	a.Same(report, f.GetLastReport())
+
+	// 8 requests by 4 workers should take about 2 request durations
+	a.True(report.Duration >= 40*time.Millisecond)
+	a.True(report.Duration < 160*time.Millisecond)
+
+	// Stations are not due until they are rescheduled
+	// Empty runs should not replace the report of the last actual one
+	lastReport := report
+	report = f.UpdateOnce(context.Background())
+	a.Equal(0, report.Due)
+	a.Same(lastReport, f.GetLastReport())
+}
+
+func TestFetcherUpdateOnceRateLimit(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &concurrentAdapter{mutex: &sync.Mutex{}, failing: map[int]bool{}}
+	f := newFetcher(upstream, newPollPolicy(time.Minute, time.Minute, time.Minute), 4, newTokenBucket(100, 1), 0, nil)
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	f.spread = func(time.Duration) time.Duration { return 0 }
+
+	for stationID := 1; stationID <= 6; stationID++ {
+		f.Subscribe(stationID, &fakeListener{})
+	}
+
+	// 6 requests at 100 rps with no burst should take at least 50ms
+	report := f.UpdateOnce(context.Background())
+	a.Equal(6, report.Updated)
+	a.True(report.Duration >= 50*time.Millisecond)
+}
+
+// concurrentAdapter is a fake adapter which tracks concurrency of GetByStation calls
+type concurrentAdapter struct {
+	adapter
+	mutex          *sync.Mutex
+	delay          time.Duration
+	failing        map[int]bool
+	running        int
+	maxConcurrency int
+}
+
+func (s *concurrentAdapter) GetByStation(_ context.Context, stationID int) (*Status, error) {
+	s.mutex.Lock()
+	s.running++
+	if s.running > s.maxConcurrency {
+		s.maxConcurrency = s.running
+	}
+	failing := s.failing[stationID]
+	s.mutex.Unlock()
+
+	time.Sleep(s.delay)
+
+	s.mutex.Lock()
+	s.running--
+	s.mutex.Unlock()
+
+	if failing {
+		return nil, errors.New("station is unavailable")
+	}
+
+	return &Status{Station: &Station{ID: stationID}, Time: time.Now()}, nil
+}
+
+// MaxConcurrency returns max count of concurrent GetByStation calls
+func (s *concurrentAdapter) MaxConcurrency() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.maxConcurrency
+}
+
+// Reset resets concurrency statistics
+func (s *concurrentAdapter) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.maxConcurrency = 0
+}
+
+func TestFetcherStaleStations(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	reported := now.Add(-10 * time.Minute)
+	upstream := &timedAdapter{mutex: &sync.Mutex{}, status: &Status{Time: reported, AQI: 30}}
+	f := newFetcher(upstream, newPollPolicy(10*time.Minute, 10*time.Minute, 10*time.Minute), 1, newTokenBucket(0, 0), time.Hour, nil)
+	f.now = func() time.Time { return now }
+	f.spread = func(d time.Duration) time.Duration { return d }
+
+	listener := &availabilityListener{}
+	f.Subscribe(123, listener)
+	station := f.GetCurrentListeners()[123]
+	a.False(station.IsOffline())
+
+	// Station stops reporting new data
+	for i := 0; i < 6; i++ {
+		now = now.Add(10 * time.Minute)
+		f.UpdateOnce(context.Background())
+	}
+	a.True(station.IsOffline())
+	a.Equal([]string{"offline since 09:50"}, listener.events)
+
+	// Offline event should not be repeated
+	now = now.Add(10 * time.Minute)
+	f.UpdateOnce(context.Background())
+	a.Len(listener.events, 1)
+
+	// Fallback statuses shouldn't bring station back online
+	upstream.SetStatus(&Status{Time: now, AQI: 40, Stale: true})
+	now = now.Add(10 * time.Minute)
+	f.UpdateOnce(context.Background())
+	a.True(station.IsOffline())
+
+	upstream.SetStatus(&Status{Time: now, AQI: 40})
+	now = now.Add(10 * time.Minute)
+	f.UpdateOnce(context.Background())
+	a.False(station.IsOffline())
+	a.Equal([]string{"offline since 09:50", "online after 09:50"}, listener.events)
+}
+
+func TestFetcherSubscribeOfflineStation(t *testing.T) {
+	a := assert.New(t)
+
+	// Station is already offline when the first listener subscribes (e.g. on startup)
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	upstream := &timedAdapter{mutex: &sync.Mutex{}, status: &Status{Time: now.Add(-2 * time.Hour), AQI: 30}}
+	f := newFetcher(upstream, newPollPolicy(10*time.Minute, 10*time.Minute, 10*time.Minute), 1, newTokenBucket(0, 0), time.Hour, nil)
+	f.now = func() time.Time { return now }
+	f.spread = func(d time.Duration) time.Duration { return d }
+
+	// Listener should be notified on the next update run rather than while subscribing
+	listener := &availabilityListener{}
+	f.Subscribe(123, listener)
+	a.True(f.IsOffline(123))
+	a.False(f.IsOffline(124))
+	a.Len(listener.events, 0)
+
+	f.UpdateOnce(context.Background())
+	a.Equal([]string{"offline since 08:00"}, listener.events)
+
+	// Listeners subscribed later should be notified too, unless they unsubscribe in the meantime
+	another := &availabilityListener{}
+	gone := &availabilityListener{events: []string{"gone"}}
+	f.Subscribe(123, another)
+	f.Subscribe(123, gone)
+	f.Unsubscribe(123, gone)
+	f.UpdateOnce(context.Background())
+	a.Equal([]string{"offline since 08:00"}, another.events)
+	a.Equal([]string{"gone"}, gone.events)
+
+	// Offline event should not be repeated on updates
+	now = now.Add(10 * time.Minute)
+	f.UpdateOnce(context.Background())
+	a.Len(listener.events, 1)
+	a.Len(another.events, 1)
+}
+
+func TestFetcherConcurrentSubscribe(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &concurrentAdapter{mutex: &sync.Mutex{}, failing: map[int]bool{}}
+	f := newFetcher(upstream, newPollPolicy(time.Minute, time.Minute, time.Minute), 4, newTokenBucket(0, 0), time.Hour, nil)
+	f.spread = func(time.Duration) time.Duration { return 0 }
+	f.Subscribe(123, &availabilityListener{})
+
+	// Station state should be safe to access while station is being updated (run with -race)
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			f.Subscribe(123, &availabilityListener{})
+			f.IsOffline(123)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			f.GetCurrentListeners()[123].SetNextUpdate(time.Time{})
+			f.UpdateOnce(context.Background())
+		}
+	}()
+	wg.Wait()
+
+	a.Len(f.GetCurrentListeners()[123].listeners, 21)
+}
+
+func TestStationFetcherUnsubscribe(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &concurrentAdapter{mutex: &sync.Mutex{}, failing: map[int]bool{}}
+	f := newStationFetcher(upstream, 123, newPollPolicy(time.Minute, time.Minute, time.Minute), 0, time.Now)
+
+	// Listeners are told apart by their events
+	l1 := &availabilityListener{events: []string{"l1"}}
+	l2 := &availabilityListener{events: []string{"l2"}}
+	l3 := &availabilityListener{events: []string{"l3"}}
+	f.Subscribe(l1)
+	f.Subscribe(l2)
+	f.Subscribe(l3)
+
+	// Removing the first listener should keep the rest in order
+	f.Unsubscribe(l1)
+	a.Equal([]Listener{l2, l3}, f.listeners)
+
+	f.Unsubscribe(l3)
+	a.Equal([]Listener{l2}, f.listeners)
+
+	// Unknown listeners should be ignored
+	f.Unsubscribe(l1)
+	a.Equal([]Listener{l2}, f.listeners)
+
+	f.Unsubscribe(l2)
+	a.True(f.Empty())
+}
+
+// availabilityListener is a fake listener which records station availability events
+type availabilityListener struct {
+	fakeListener
+	events []string
+}
+
+func (l *availabilityListener) StationOffline(_ *Status, since time.Time) error {
+	l.events = append(l.events, "offline since "+since.Format("15:04"))
+	return nil
+}
+
+func (l *availabilityListener) StationOnline(_ *Status, since time.Time) error {
+	l.events = append(l.events, "online after "+since.Format("15:04"))
+	return nil
+}
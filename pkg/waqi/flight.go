@@ -0,0 +1,100 @@
+package waqi
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errFlightPanicked is returned to waiters of a call which has panicked
+var errFlightPanicked = errors.New("in-flight call panicked")
+
+// flightGroup deduplicates concurrent calls with the same key
+// Only the first caller performs a call, others wait for its result
+type flightGroup struct {
+	mutex *sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is an in-flight call
+type flightCall struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	waiters int
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{
+		mutex: &sync.Mutex{},
+		calls: make(map[string]*flightCall),
+	}
+}
+
+// Do calls fn unless a call with the same key is in flight already, in which case its result is returned
+func (g *flightGroup) Do(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	for {
+		g.mutex.Lock()
+		call, exists := g.calls[key]
+		if !exists {
+			call = &flightCall{done: make(chan struct{})}
+			g.calls[key] = call
+			g.mutex.Unlock()
+
+			g.Call(ctx, key, call, fn)
+			return call.value, call.err
+		}
+
+		call.waiters++
+		g.mutex.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		// The first caller might have been cancelled, that shouldn't affect others
+		if isContextError(call.err) && ctx.Err() == nil {
+			continue
+		}
+
+		return call.value, call.err
+	}
+}
+
+// Call performs an in-flight call and releases its waiters even if fn panics
+func (g *flightGroup) Call(ctx context.Context, key string, call *flightCall, fn func(context.Context) (interface{}, error)) {
+	completed := false
+	defer func() {
+		if !completed {
+			call.err = errFlightPanicked
+		}
+
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = fn(ctx)
+	completed = true
+}
+
+// Waiters returns count of callers waiting for an in-flight call
+func (g *flightGroup) Waiters(key string) int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	call, exists := g.calls[key]
+	if !exists {
+		return 0
+	}
+
+	return call.waiters
+}
+
+// isContextError returns true if err is caused by context cancellation
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
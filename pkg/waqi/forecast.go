@@ -0,0 +1,171 @@
+package waqi
+
+import (
+	"sort"
+	"time"
+)
+
+// ForecastDateLayout is a layout of forecast dates
+const ForecastDateLayout = "2006-01-02"
+
+// Forecast contains a daily air quality forecast of a station
+type Forecast struct {
+	// Forecast days sorted by date
+	Days []*ForecastDay `json:"days"`
+}
+
+// ForecastDay contains an air quality forecast for a single day
+// Pollutant values are AQI sub-indices (nil if pollutant is not forecasted).
+type ForecastDay struct {
+	// Forecast date (local date of a station, see ForecastDateLayout)
+	Date string `json:"date"`
+
+	// Particulate matter 2.5 forecast
+	PM25 *ForecastValue `json:"pm25"`
+
+	// Particulate matter 10 forecast
+	PM10 *ForecastValue `json:"pm10"`
+
+	// Ozone forecast
+	O3 *ForecastValue `json:"o3"`
+
+	// Ultraviolet index forecast
+	UVI *ForecastValue `json:"uvi"`
+}
+
+// ForecastValue contains forecasted values of a parameter within a day
+type ForecastValue struct {
+	Min float32 `json:"min"`
+	Avg float32 `json:"avg"`
+	Max float32 `json:"max"`
+}
+
+// Day returns a forecast for a date (only year, month and day of t are taken into account)
+// Returns nil if there is no forecast for the date.
+func (f *Forecast) Day(t time.Time) *ForecastDay {
+	if f == nil {
+		return nil
+	}
+
+	date := t.Format(ForecastDateLayout)
+	for _, day := range f.Days {
+		if day.Date == date {
+			return day
+		}
+	}
+
+	return nil
+}
+
+// Tomorrow returns a forecast for the next day in specified location
+// Returns nil if there is no forecast for the next day.
+func (f *Forecast) Tomorrow(now time.Time, loc *time.Location) *ForecastDay {
+	return f.Day(now.In(loc).AddDate(0, 0, 1))
+}
+
+// Value returns a forecast of specified parameter
+// Returns nil if parameter is not forecasted.
+func (d *ForecastDay) Value(parameter Parameter) *ForecastValue {
+	switch parameter {
+	case PM25Parameter:
+		return d.PM25
+	case PM10Parameter:
+		return d.PM10
+	case O3Parameter:
+		return d.O3
+	default:
+		return nil
+	}
+}
+
+// MaxAQI returns the highest forecasted AQI sub-index of the day and a pollutant which defines it
+// Returns zero and an empty parameter if no pollutants are forecasted.
+func (d *ForecastDay) MaxAQI() (float32, Parameter) {
+	var (
+		max       float32
+		dominant  Parameter
+		forecasts = []Parameter{PM25Parameter, PM10Parameter, O3Parameter}
+	)
+
+	for _, parameter := range forecasts {
+		value := d.Value(parameter)
+		if value != nil && (dominant == "" || value.Max > max) {
+			max = value.Max
+			dominant = parameter
+		}
+	}
+
+	return max, dominant
+}
+
+// forecastJSON is a model for "data.forecast" node in WAQI response
+type forecastJSON struct {
+	Daily *dailyForecastJSON `json:"daily"`
+}
+
+// dailyForecastJSON is a model for "data.forecast.daily" node in WAQI response
+type dailyForecastJSON struct {
+	PM25 []*forecastValueJSON `json:"pm25"`
+	PM10 []*forecastValueJSON `json:"pm10"`
+	O3   []*forecastValueJSON `json:"o3"`
+	UVI  []*forecastValueJSON `json:"uvi"`
+}
+
+// forecastValueJSON is a model for "data.forecast.daily.*[]" node in WAQI response
+type forecastValueJSON struct {
+	Day string  `json:"day"`
+	Min float32 `json:"min"`
+	Avg float32 `json:"avg"`
+	Max float32 `json:"max"`
+}
+
+// ToForecast converts a forecast node into internal object
+// Returns nil if there is no daily forecast.
+func (f *forecastJSON) ToForecast() *Forecast {
+	if f == nil || f.Daily == nil {
+		return nil
+	}
+
+	days := make(map[string]*ForecastDay)
+	getDay := func(date string) *ForecastDay {
+		day, exists := days[date]
+		if !exists {
+			day = &ForecastDay{Date: date}
+			days[date] = day
+		}
+		return day
+	}
+
+	merge := func(values []*forecastValueJSON, set func(day *ForecastDay, value *ForecastValue)) {
+		for _, value := range values {
+			if value == nil {
+				continue
+			}
+
+			if _, err := time.Parse(ForecastDateLayout, value.Day); err != nil {
+				continue
+			}
+
+			set(getDay(value.Day), &ForecastValue{Min: value.Min, Avg: value.Avg, Max: value.Max})
+		}
+	}
+
+	merge(f.Daily.PM25, func(day *ForecastDay, value *ForecastValue) { day.PM25 = value })
+	merge(f.Daily.PM10, func(day *ForecastDay, value *ForecastValue) { day.PM10 = value })
+	merge(f.Daily.O3, func(day *ForecastDay, value *ForecastValue) { day.O3 = value })
+	merge(f.Daily.UVI, func(day *ForecastDay, value *ForecastValue) { day.UVI = value })
+
+	if len(days) == 0 {
+		return nil
+	}
+
+	forecast := &Forecast{Days: make([]*ForecastDay, 0, len(days))}
+	for _, day := range days {
+		forecast.Days = append(forecast.Days, day)
+	}
+	sort.Slice(forecast.Days, func(i, j int) bool {
+		return forecast.Days[i].Date < forecast.Days[j].Date
+	})
+
+	return forecast
+}
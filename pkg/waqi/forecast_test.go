@@ -0,0 +1,48 @@
+package waqi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForecastTomorrow(t *testing.T) {
+	a := assert.New(t)
+
+	forecast := &Forecast{Days: []*ForecastDay{
+		{Date: "2021-05-01", PM25: &ForecastValue{Max: 40}},
+		{Date: "2021-05-02", PM25: &ForecastValue{Max: 90}},
+	}}
+
+	// It's still May 1st in UTC but already May 2nd in Vladivostok
+	now := time.Date(2021, 5, 1, 20, 0, 0, 0, time.UTC)
+	vladivostok := time.FixedZone("Asia/Vladivostok", 10*60*60)
+
+	a.Equal("2021-05-02", forecast.Tomorrow(now, time.UTC).Date)
+	a.Nil(forecast.Tomorrow(now, vladivostok))
+	a.Equal("2021-05-01", forecast.Day(now).Date)
+
+	var missing *Forecast
+	a.Nil(missing.Tomorrow(now, time.UTC))
+}
+
+func TestForecastDayMaxAQI(t *testing.T) {
+	a := assert.New(t)
+
+	day := &ForecastDay{
+		Date: "2021-05-01",
+		PM25: &ForecastValue{Min: 30, Avg: 50, Max: 76},
+		PM10: &ForecastValue{Min: 20, Avg: 60, Max: 120},
+		O3:   &ForecastValue{Min: 5, Avg: 10, Max: 15},
+		UVI:  &ForecastValue{Min: 0, Avg: 3, Max: 200},
+	}
+
+	max, parameter := day.MaxAQI()
+	a.Equal(float32(120), max)
+	a.Equal(PM10Parameter, parameter)
+
+	max, parameter = (&ForecastDay{Date: "2021-05-01"}).MaxAQI()
+	a.Equal(float32(0), max)
+	a.Equal(Parameter(""), parameter)
+}
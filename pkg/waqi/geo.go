@@ -0,0 +1,91 @@
+package waqi
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+const (
+	// EarthRadius is a mean radius of Earth (in kilometers)
+	EarthRadius = 6371.0088
+
+	// kilometersPerDegree is a length of one degree of latitude (in kilometers)
+	kilometersPerDegree = math.Pi * EarthRadius / 180
+)
+
+// nearbySearchRadii contains radii (in kilometers) which are tried one by one while looking for nearby stations
+var nearbySearchRadii = []float64{10, 50, 200}
+
+// Bounds is a rectangle defined by geo coordinates of its corners
+type Bounds struct {
+	MinLat float32 `json:"min_lat"`
+	MinLon float32 `json:"min_lon"`
+	MaxLat float32 `json:"max_lat"`
+	MaxLon float32 `json:"max_lon"`
+}
+
+// BoundsAround returns a rectangle which contains a circle of specified radius (in kilometers)
+func BoundsAround(lat, lon float32, radius float64) Bounds {
+	dLat := radius / kilometersPerDegree
+	dLon := 180.0
+	if cos := math.Cos(float64(lat) * math.Pi / 180); cos > 1e-6 {
+		dLon = math.Min(dLat/cos, 180)
+	}
+
+	return Bounds{
+		MinLat: float32(math.Max(float64(lat)-dLat, -90)),
+		MinLon: float32(math.Max(float64(lon)-dLon, -180)),
+		MaxLat: float32(math.Min(float64(lat)+dLat, 90)),
+		MaxLon: float32(math.Min(float64(lon)+dLon, 180)),
+	}
+}
+
+// Distance returns a great-circle distance (in kilometers) between two points
+func Distance(lat1, lon1, lat2, lon2 float32) float64 {
+	const rad = math.Pi / 180
+	phi1 := float64(lat1) * rad
+	phi2 := float64(lat2) * rad
+	dPhi := float64(lat2-lat1) * rad
+	dLambda := float64(lon2-lon1) * rad
+
+	// Haversine formula
+	h := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// getNearbyStations returns up to count stations nearest to specified geo coordinates, ordered by distance
+// Search area is extended until enough stations are found
+func getNearbyStations(ctx context.Context, adapter adapter, lat, lon float32, count int) ([]*NearbyStation, error) {
+	var summaries []*StationSummary
+	for _, radius := range nearbySearchRadii {
+		var err error
+		summaries, err = adapter.GetByBounds(ctx, BoundsAround(lat, lon, radius))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(summaries) >= count {
+			break
+		}
+	}
+
+	stations := make([]*NearbyStation, 0, len(summaries))
+	for _, summary := range summaries {
+		stations = append(stations, &NearbyStation{
+			StationSummary: summary,
+			Distance:       Distance(lat, lon, summary.Station.Lat, summary.Station.Lon),
+		})
+	}
+
+	sort.SliceStable(stations, func(i, j int) bool {
+		return stations[i].Distance < stations[j].Distance
+	})
+
+	if len(stations) > count {
+		stations = stations[:count]
+	}
+
+	return stations, nil
+}
@@ -0,0 +1,89 @@
+package waqi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	a := assert.New(t)
+
+	// Paris to London
+	a.InDelta(343.5, Distance(48.8566, 2.3522, 51.5074, -0.1278), 1)
+
+	// One degree along equator
+	a.InDelta(111.195, Distance(0, 0, 0, 1), 0.001)
+
+	a.Equal(0.0, Distance(10, 20, 10, 20))
+}
+
+func TestBoundsAround(t *testing.T) {
+	a := assert.New(t)
+
+	bounds := BoundsAround(60, 30, 10)
+	a.InDelta(60-0.09, bounds.MinLat, 0.001)
+	a.InDelta(60+0.09, bounds.MaxLat, 0.001)
+
+	// A degree of longitude is twice shorter at 60th parallel
+	a.InDelta(30-0.18, bounds.MinLon, 0.001)
+	a.InDelta(30+0.18, bounds.MaxLon, 0.001)
+
+	// Bounds should be clamped near poles
+	bounds = BoundsAround(89.99, 0, 100)
+	a.Equal(float32(90), bounds.MaxLat)
+	a.Equal(float32(-180), bounds.MinLon)
+	a.Equal(float32(180), bounds.MaxLon)
+}
+
+func TestGetNearbyStations(t *testing.T) {
+	a := assert.New(t)
+
+	adapter := &boundsAdapter{
+		stations: []*StationSummary{
+			{Station: &Station{ID: 1, Lat: 55.80, Lon: 37.60}},
+			{Station: &Station{ID: 2, Lat: 55.76, Lon: 37.62}},
+			{Station: &Station{ID: 3, Lat: 56.50, Lon: 37.60}},
+			{Station: &Station{ID: 4, Lat: 55.70, Lon: 37.60}},
+		},
+	}
+
+	stations, err := getNearbyStations(context.Background(), adapter, 55.7558, 37.6173, 3)
+	a.Nil(err)
+	a.Len(stations, 3)
+	a.Equal(2, stations[0].Station.ID)
+	a.Equal(1, stations[1].Station.ID)
+	a.Equal(4, stations[2].Station.ID)
+	a.True(stations[0].Distance < stations[1].Distance)
+	a.Equal(1, adapter.calls)
+
+	// Search area should be extended until enough stations are found
+	adapter.calls = 0
+	stations, err = getNearbyStations(context.Background(), adapter, 55.7558, 37.6173, 5)
+	a.Nil(err)
+	a.Len(stations, 4)
+	a.Equal(3, stations[3].Station.ID)
+	a.Equal(3, adapter.calls)
+}
+
+// boundsAdapter is a fake adapter which returns stations within requested bounds
+type boundsAdapter struct {
+	adapter
+	stations []*StationSummary
+	calls    int
+}
+
+func (s *boundsAdapter) GetByBounds(_ context.Context, bounds Bounds) ([]*StationSummary, error) {
+	s.calls++
+
+	results := make([]*StationSummary, 0)
+	for _, station := range s.stations {
+		if station.Station.Lat >= bounds.MinLat && station.Station.Lat <= bounds.MaxLat &&
+			station.Station.Lon >= bounds.MinLon && station.Station.Lon <= bounds.MaxLon {
+			results = append(results, station)
+		}
+	}
+
+	return results, nil
+}
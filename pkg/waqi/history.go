@@ -0,0 +1,463 @@
+package waqi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// DefaultHistoryRetention is default retention period for historical measurements
+	DefaultHistoryRetention = 90 * 24 * time.Hour
+
+	// DefaultHistoryDownsampleAfter is default age after which historical measurements are downsampled to hourly averages
+	DefaultHistoryDownsampleAfter = 7 * 24 * time.Hour
+
+	historyCompactionPeriod = time.Hour
+	historyRecordPrefix     = "h/"
+	historyStationPrefix    = "s/"
+)
+
+// historyStore is an append-only store of historical measurements
+type historyStore interface {
+	// Append stores a measurement
+	// Measurements that have been already stored are ignored
+	Append(status *Status) error
+
+	// Query returns measurements of a station within specified time range, ordered by time
+	Query(stationID int, from, to time.Time) ([]*Status, error)
+
+	// Compact applies retention policy and downsampling to stored measurements
+	Compact(now time.Time) error
+
+	// Close shuts down store
+	Close() error
+}
+
+// historyRecord is a stored measurement (or an average of several measurements)
+type historyRecord struct {
+	Time    time.Time `json:"time"`
+	AQI     float32   `json:"aqi"`
+	PM25    *float32  `json:"pm25,omitempty"`
+	PM10    *float32  `json:"pm10,omitempty"`
+	O3      *float32  `json:"o3,omitempty"`
+	NO2     *float32  `json:"no2,omitempty"`
+	SO2     *float32  `json:"so2,omitempty"`
+	CO      *float32  `json:"co,omitempty"`
+	Samples int       `json:"samples"`
+}
+
+type levelDBHistoryStore struct {
+	db              *leveldb.DB
+	retention       time.Duration
+	downsampleAfter time.Duration
+	logger          *log.Logger
+	mutex           *sync.Mutex
+	ticker          *time.Ticker
+	done            chan bool
+}
+
+func newLevelDBHistoryStore(path string, retention, downsampleAfter time.Duration, logger *log.Logger) (historyStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &levelDBHistoryStore{
+		db:              db,
+		retention:       retention,
+		downsampleAfter: downsampleAfter,
+		logger:          logger,
+		mutex:           &sync.Mutex{},
+		ticker:          time.NewTicker(historyCompactionPeriod),
+		done:            make(chan bool),
+	}
+	go s.CompactionLoop()
+
+	return s, nil
+}
+
+// Append stores a measurement
+// Measurements that have been already stored are ignored
+func (s *levelDBHistoryStore) Append(status *Status) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := []byte(s.GetRecordKey(status.Station.ID, status.Time))
+	exists, err := s.db.Has(key, nil)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	record := &historyRecord{
+		Time:    status.Time.UTC(),
+		AQI:     status.AQI,
+		PM25:    status.PM25,
+		PM10:    status.PM10,
+		O3:      status.O3,
+		NO2:     status.NO2,
+		SO2:     status.SO2,
+		CO:      status.CO,
+		Samples: 1,
+	}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	stationBytes, err := json.Marshal(status.Station)
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(key, recordBytes)
+	batch.Put([]byte(s.GetStationKey(status.Station.ID)), stationBytes)
+	return s.db.Write(batch, nil)
+}
+
+// Query returns measurements of a station within specified time range, ordered by time
+func (s *levelDBHistoryStore) Query(stationID int, from, to time.Time) ([]*Status, error) {
+	station := &Station{ID: stationID}
+	raw, err := s.db.Get([]byte(s.GetStationKey(stationID)), nil)
+	if err != nil && err != leveldb.ErrNotFound {
+		return nil, err
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, station)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := &util.Range{
+		Start: []byte(s.GetRecordKey(stationID, from)),
+		Limit: []byte(s.GetRecordKey(stationID, to.Add(time.Second))),
+	}
+	iter := s.db.NewIterator(r, nil)
+	defer iter.Release()
+
+	statuses := make([]*Status, 0)
+	for iter.Next() {
+		var record historyRecord
+		err = json.Unmarshal(iter.Value(), &record)
+		if err != nil {
+			s.logger.Printf("malformed history record \"%s\": %s", iter.Key(), err)
+			continue
+		}
+
+		statuses = append(statuses, &Status{
+			Station: station,
+			Time:    record.Time,
+			AQI:     record.AQI,
+			Level:   CalcAQILevel(record.AQI),
+			PM25:    record.PM25,
+			PM10:    record.PM10,
+			O3:      record.O3,
+			NO2:     record.NO2,
+			SO2:     record.SO2,
+			CO:      record.CO,
+		})
+	}
+
+	err = iter.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// Compact applies retention policy and downsampling to stored measurements
+// Measurements older than retention period are removed
+// Measurements older than downsampling age are replaced with hourly averages
+func (s *levelDBHistoryStore) Compact(now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	retentionLimit := now.Add(-s.retention)
+	downsampleLimit := now.Add(-s.downsampleAfter)
+
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(historyRecordPrefix)), nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	removed := 0
+	var bucket *historyBucket
+	flush := func() error {
+		if bucket != nil && bucket.NeedsDownsampling() {
+			err := bucket.Write(batch)
+			if err != nil {
+				return err
+			}
+		}
+		bucket = nil
+		return nil
+	}
+
+	for iter.Next() {
+		key := string(iter.Key())
+		stationID, t, err := s.ParseRecordKey(key)
+		if err != nil {
+			s.logger.Printf("malformed history key \"%s\": %s", key, err)
+			batch.Delete(iter.Key())
+			continue
+		}
+
+		if t.Before(retentionLimit) {
+			batch.Delete(iter.Key())
+			removed++
+			continue
+		}
+
+		if !t.Before(downsampleLimit) {
+			continue
+		}
+
+		var record historyRecord
+		err = json.Unmarshal(iter.Value(), &record)
+		if err != nil {
+			s.logger.Printf("malformed history record \"%s\": %s", key, err)
+			batch.Delete(iter.Key())
+			continue
+		}
+
+		hour := t.Truncate(time.Hour)
+		if bucket == nil || bucket.stationID != stationID || !bucket.time.Equal(hour) {
+			err = flush()
+			if err != nil {
+				return err
+			}
+			bucket = &historyBucket{store: s, stationID: stationID, time: hour}
+		}
+		bucket.Add(key, &record)
+	}
+
+	err := iter.Error()
+	if err != nil {
+		return err
+	}
+
+	err = flush()
+	if err != nil {
+		return err
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	s.logger.Printf("history compaction: %d record(s) removed, %d change(s) in total", removed, batch.Len())
+	return s.db.Write(batch, nil)
+}
+
+// CompactionLoop runs background compaction loop
+func (s *levelDBHistoryStore) CompactionLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.ticker.C:
+			err := s.Compact(time.Now().UTC())
+			if err != nil {
+				s.logger.Printf("history compaction failed: %s", err)
+			}
+		}
+	}
+}
+
+// GetRecordKey returns key of a measurement
+func (s *levelDBHistoryStore) GetRecordKey(stationID int, t time.Time) string {
+	return fmt.Sprintf("%s%010d/%016d", historyRecordPrefix, stationID, t.Unix())
+}
+
+// ParseRecordKey extracts station ID and measurement time from a key
+func (s *levelDBHistoryStore) ParseRecordKey(key string) (int, time.Time, error) {
+	var stationID int
+	var unix int64
+	_, err := fmt.Sscanf(key, historyRecordPrefix+"%10d/%16d", &stationID, &unix)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return stationID, time.Unix(unix, 0).UTC(), nil
+}
+
+// GetStationKey returns key of a station information
+func (s *levelDBHistoryStore) GetStationKey(stationID int) string {
+	return fmt.Sprintf("%s%010d", historyStationPrefix, stationID)
+}
+
+// Close shuts down store
+func (s *levelDBHistoryStore) Close() error {
+	s.ticker.Stop()
+	s.done <- true
+
+	return s.db.Close()
+}
+
+// historyBucket collects measurements of a station within one hour
+type historyBucket struct {
+	store     *levelDBHistoryStore
+	stationID int
+	time      time.Time
+	keys      []string
+	records   []*historyRecord
+}
+
+// Add adds a measurement into bucket
+func (b *historyBucket) Add(key string, record *historyRecord) {
+	b.keys = append(b.keys, key)
+	b.records = append(b.records, record)
+}
+
+// NeedsDownsampling returns false if bucket has been already downsampled
+func (b *historyBucket) NeedsDownsampling() bool {
+	if len(b.records) != 1 {
+		return true
+	}
+
+	return !b.records[0].Time.Equal(b.time)
+}
+
+// Write replaces bucket's measurements with their average
+func (b *historyBucket) Write(batch *leveldb.Batch) error {
+	var aqi, pm25, pm10, o3, no2, so2, co historyAverage
+	samples := 0
+	for _, record := range b.records {
+		weight := record.Samples
+		if weight < 1 {
+			weight = 1
+		}
+		samples += weight
+
+		aqi.Add(&record.AQI, weight)
+		pm25.Add(record.PM25, weight)
+		pm10.Add(record.PM10, weight)
+		o3.Add(record.O3, weight)
+		no2.Add(record.NO2, weight)
+		so2.Add(record.SO2, weight)
+		co.Add(record.CO, weight)
+	}
+
+	average := &historyRecord{
+		Time:    b.time,
+		PM25:    pm25.Value(),
+		PM10:    pm10.Value(),
+		O3:      o3.Value(),
+		NO2:     no2.Value(),
+		SO2:     so2.Value(),
+		CO:      co.Value(),
+		Samples: samples,
+	}
+	if value := aqi.Value(); value != nil {
+		average.AQI = *value
+	}
+
+	bytes, err := json.Marshal(average)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range b.keys {
+		batch.Delete([]byte(key))
+	}
+	batch.Put([]byte(b.store.GetRecordKey(b.stationID, b.time)), bytes)
+	return nil
+}
+
+// historyAverage calculates a weighted average of optional values
+type historyAverage struct {
+	sum    float64
+	weight int
+}
+
+// Add adds a value
+func (a *historyAverage) Add(value *float32, weight int) {
+	if value == nil {
+		return
+	}
+
+	a.sum += float64(*value) * float64(weight)
+	a.weight += weight
+}
+
+// Value returns an average value or nil if there were no values
+func (a *historyAverage) Value() *float32 {
+	if a.weight == 0 {
+		return nil
+	}
+
+	value := float32(a.sum / float64(a.weight))
+	return &value
+}
+
+// recordingServiceAdapter records every received measurement into history store
+type recordingServiceAdapter struct {
+	adapter adapter
+	history historyStore
+	logger  *log.Logger
+}
+
+func newRecordingServiceAdapter(adapter adapter, history historyStore, logger *log.Logger) adapter {
+	return &recordingServiceAdapter{adapter, history, logger}
+}
+
+// GetByCity fetches current measurements for city
+func (s *recordingServiceAdapter) GetByCity(ctx context.Context, city string) (*Status, error) {
+	return s.Record(s.adapter.GetByCity(ctx, city))
+}
+
+// GetByStation fetches current measurements for station
+func (s *recordingServiceAdapter) GetByStation(ctx context.Context, stationID int) (*Status, error) {
+	return s.Record(s.adapter.GetByStation(ctx, stationID))
+}
+
+// GetByGeo fetches current measurements for geo coordinates
+func (s *recordingServiceAdapter) GetByGeo(ctx context.Context, lat, lon float32) (*Status, error) {
+	return s.Record(s.adapter.GetByGeo(ctx, lat, lon))
+}
+
+// Search looks up stations by a keyword
+func (s *recordingServiceAdapter) Search(ctx context.Context, keyword string) ([]*StationSummary, error) {
+	return s.adapter.Search(ctx, keyword)
+}
+
+// GetByBounds returns stations located within specified rectangle
+func (s *recordingServiceAdapter) GetByBounds(ctx context.Context, bounds Bounds) ([]*StationSummary, error) {
+	return s.adapter.GetByBounds(ctx, bounds)
+}
+
+// Record stores a measurement into history store
+// Failures are logged but not returned since history is not essential
+func (s *recordingServiceAdapter) Record(status *Status, err error) (*Status, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	// Stale statuses have been recorded already
+	if status.Stale {
+		return status, nil
+	}
+
+	err = s.history.Append(status)
+	if err != nil {
+		s.logger.Printf("unable to record measurement for station #%d: %s", status.Station.ID, err)
+	}
+
+	return status, nil
+}
+
+// Close shuts down adapter
+func (s *recordingServiceAdapter) Close() error {
+	return s.adapter.Close()
+}
@@ -0,0 +1,119 @@
+package waqi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryAppendAndQuery(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	store, err := newLevelDBHistoryStore(dir, DefaultHistoryRetention, DefaultHistoryDownsampleAfter, log.Default())
+	a.Nil(err)
+	defer func() {
+		a.Nil(store.Close())
+	}()
+
+	t0 := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	a.Nil(store.Append(historyStatus(123, t0, 40)))
+	a.Nil(store.Append(historyStatus(123, t0.Add(time.Hour), 60)))
+	a.Nil(store.Append(historyStatus(123, t0.Add(2*time.Hour), 80)))
+	a.Nil(store.Append(historyStatus(124, t0.Add(time.Hour), 100)))
+
+	// Measurements are append-only
+	a.Nil(store.Append(historyStatus(123, t0, 999)))
+
+	statuses, err := store.Query(123, t0, t0.Add(time.Hour))
+	a.Nil(err)
+	a.Len(statuses, 2)
+	a.Equal(float32(40), statuses[0].AQI)
+	a.Equal(float32(60), statuses[1].AQI)
+	a.Equal(ModerateLevel, statuses[1].Level)
+	a.Equal(123, statuses[0].Station.ID)
+	a.Equal("Station #123", statuses[0].Station.Name)
+	a.True(t0.Equal(statuses[0].Time))
+
+	statuses, err = store.Query(124, t0, t0.Add(24*time.Hour))
+	a.Nil(err)
+	a.Len(statuses, 1)
+
+	statuses, err = store.Query(125, t0, t0.Add(24*time.Hour))
+	a.Nil(err)
+	a.Len(statuses, 0)
+}
+
+func TestHistoryCompact(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp(os.TempDir(), "*")
+	a.Nil(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	store, err := newLevelDBHistoryStore(dir, 30*24*time.Hour, 7*24*time.Hour, log.Default())
+	a.Nil(err)
+	defer func() {
+		a.Nil(store.Close())
+	}()
+
+	now := time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC)
+	expired := now.Add(-31 * 24 * time.Hour)
+	old := now.Add(-10 * 24 * time.Hour)
+	recent := now.Add(-24 * time.Hour)
+
+	a.Nil(store.Append(historyStatus(123, expired, 10)))
+	a.Nil(store.Append(historyStatus(123, old.Add(10*time.Minute), 20)))
+	a.Nil(store.Append(historyStatus(123, old.Add(20*time.Minute), 40)))
+	a.Nil(store.Append(historyStatus(123, old.Add(70*time.Minute), 60)))
+	a.Nil(store.Append(historyStatus(123, recent, 80)))
+	a.Nil(store.Append(historyStatus(123, recent.Add(10*time.Minute), 90)))
+
+	a.Nil(store.Compact(now))
+
+	statuses, err := store.Query(123, expired.Add(-time.Hour), now)
+	a.Nil(err)
+	a.Len(statuses, 4)
+
+	// Old measurements should be replaced with hourly averages
+	a.True(old.Equal(statuses[0].Time))
+	a.Equal(float32(30), statuses[0].AQI)
+	a.Equal(float32(30), *statuses[0].PM25)
+	a.True(old.Add(time.Hour).Equal(statuses[1].Time))
+	a.Equal(float32(60), statuses[1].AQI)
+
+	// Recent measurements should be kept as is
+	a.True(recent.Equal(statuses[2].Time))
+	a.Equal(float32(80), statuses[2].AQI)
+	a.Equal(float32(90), statuses[3].AQI)
+
+	// Repeated compaction should not change anything
+	a.Nil(store.Append(historyStatus(123, old.Add(30*time.Minute), 90)))
+	a.Nil(store.Compact(now))
+	a.Nil(store.Compact(now))
+
+	statuses, err = store.Query(123, old, old)
+	a.Nil(err)
+	a.Len(statuses, 1)
+	a.Equal(float32(50), statuses[0].AQI)
+}
+
+func historyStatus(stationID int, t time.Time, aqi float32) *Status {
+	pm25 := aqi
+	return &Status{
+		Station: &Station{ID: stationID, Name: fmt.Sprintf("Station #%d", stationID)},
+		Time:    t,
+		AQI:     aqi,
+		Level:   CalcAQILevel(aqi),
+		PM25:    &pm25,
+	}
+}
@@ -1,12 +1,38 @@
 package waqi
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 const (
 	responseStatusOK    = "ok"
 	responseStatusError = "error"
 )
 
+// envelopeJSON is a common root model for all JSON responses
+type envelopeJSON struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// ErrorMessage extracts an error message from an error response
+// WAQI puts error message either into "message" or into "data" node
+func (r envelopeJSON) ErrorMessage() string {
+	if r.Message != "" {
+		return r.Message
+	}
+
+	var message string
+	err := json.Unmarshal(r.Data, &message)
+	if err == nil && message != "" {
+		return message
+	}
+
+	return "unknown error"
+}
+
 // responseJSON is a root model for JSON response
 type responseJSON struct {
 	Status  string    `json:"status"`
@@ -16,14 +42,24 @@ type responseJSON struct {
 
 // dataJSON is a model for "data" node in WAQI response
 type dataJSON struct {
-	AQI  float32   `json:"aqi"`
-	ID   int       `json:"idx"`
-	City *cityJSON `json:"city"`
-	IAQI *iaqiJSON `json:"iaqi"`
-	Time *timeJSON `json:"time"`
+	AQI          float32            `json:"aqi"`
+	ID           int                `json:"idx"`
+	City         *cityJSON          `json:"city"`
+	IAQI         *iaqiJSON          `json:"iaqi"`
+	Time         *timeJSON          `json:"time"`
+	DominentPol  string             `json:"dominentpol"`
+	Attributions []*attributionJSON `json:"attributions"`
+	Forecast     *forecastJSON      `json:"forecast"`
+}
+
+// attributionJSON is a model for "data.attributions[]" node in WAQI response
+type attributionJSON struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
 }
 
 // cityJSON is a model for "data.city" node in WAQI response
+// Geo coordinates are reported as [latitude, longitude]
 type cityJSON struct {
 	Geo  []float32 `json:"geo"`
 	Name string    `json:"name"`
@@ -69,8 +105,8 @@ func (r responseJSON) ToStatus() *Status {
 			ID:   r.Data.ID,
 			Name: r.Data.City.Name,
 			URL:  r.Data.City.URL,
-			Lon:  r.Data.City.Geo[0],
-			Lat:  r.Data.City.Geo[1],
+			Lat:  r.Data.City.Geo[0],
+			Lon:  r.Data.City.Geo[1],
 		},
 		Time:  t,
 		AQI:   r.Data.AQI,
@@ -81,10 +117,117 @@ func (r responseJSON) ToStatus() *Status {
 		NO2:   extractValueFromJSON(r.Data.IAQI.NO2),
 		SO2:   extractValueFromJSON(r.Data.IAQI.SO2),
 		CO:    extractValueFromJSON(r.Data.IAQI.CO),
+		Weather: &Weather{
+			Temperature: extractValueFromJSON(r.Data.IAQI.T),
+			Humidity:    extractValueFromJSON(r.Data.IAQI.H),
+			Pressure:    extractValueFromJSON(r.Data.IAQI.P),
+			Wind:        extractValueFromJSON(r.Data.IAQI.W),
+			WindGust:    extractValueFromJSON(r.Data.IAQI.WG),
+			DewPoint:    extractValueFromJSON(r.Data.IAQI.Dew),
+		},
+		Attributions: make([]*Attribution, 0, len(r.Data.Attributions)),
+		Forecast:     r.Data.Forecast.ToForecast(),
 	}
+
+	if status.Weather.IsEmpty() {
+		status.Weather = nil
+	}
+
+	// WAQI misspells "dominant" and might report pollutants we don't know about
+	if parameter := Parameter(r.Data.DominentPol); isKnownParameter(parameter) {
+		status.DominantPollutant = parameter
+	}
+
+	for _, attribution := range r.Data.Attributions {
+		if attribution != nil && attribution.Name != "" {
+			status.Attributions = append(status.Attributions, &Attribution{Name: attribution.Name, URL: attribution.URL})
+		}
+	}
+
 	return status
 }
 
+// searchResultJSON is a model for "data[]" node in WAQI search response
+type searchResultJSON struct {
+	UID     int                `json:"uid"`
+	AQI     string             `json:"aqi"`
+	Time    *searchTimeJSON    `json:"time"`
+	Station *searchStationJSON `json:"station"`
+}
+
+// searchTimeJSON is a model for "data[].time" node in WAQI search response
+type searchTimeJSON struct {
+	VTime int64 `json:"vtime"`
+}
+
+// searchStationJSON is a model for "data[].station" node in WAQI search response
+// Geo coordinates are reported as [latitude, longitude]
+type searchStationJSON struct {
+	Name string    `json:"name"`
+	Geo  []float32 `json:"geo"`
+	URL  string    `json:"url"`
+}
+
+// ToStationSummary converts a search result into internal object
+func (r searchResultJSON) ToStationSummary() *StationSummary {
+	summary := &StationSummary{
+		Station: &Station{
+			ID:   r.UID,
+			Name: r.Station.Name,
+			URL:  r.Station.URL,
+		},
+	}
+
+	if len(r.Station.Geo) == 2 {
+		summary.Station.Lat = r.Station.Geo[0]
+		summary.Station.Lon = r.Station.Geo[1]
+	}
+
+	if r.Time != nil && r.Time.VTime != 0 {
+		summary.Time = time.Unix(r.Time.VTime, 0).UTC()
+	}
+
+	summary.SetAQI(r.AQI)
+	return summary
+}
+
+// boundsResultJSON is a model for "data[]" node in WAQI map bounds response
+type boundsResultJSON struct {
+	UID     int                `json:"uid"`
+	Lat     float32            `json:"lat"`
+	Lon     float32            `json:"lon"`
+	AQI     string             `json:"aqi"`
+	Station *boundsStationJSON `json:"station"`
+}
+
+// boundsStationJSON is a model for "data[].station" node in WAQI map bounds response
+type boundsStationJSON struct {
+	Name string `json:"name"`
+	Time string `json:"time"`
+}
+
+// ToStationSummary converts a map bounds result into internal object
+func (r boundsResultJSON) ToStationSummary() *StationSummary {
+	summary := &StationSummary{
+		Station: &Station{
+			ID:  r.UID,
+			Lat: r.Lat,
+			Lon: r.Lon,
+		},
+	}
+
+	if r.Station != nil {
+		summary.Station.Name = r.Station.Name
+		t, err := time.Parse(time.RFC3339, r.Station.Time)
+		if err == nil {
+			summary.Time = t.UTC()
+		}
+	}
+
+	summary.SetAQI(r.AQI)
+	return summary
+}
+
 func extractValueFromJSON(value *valueJSON) *float32 {
 	if value == nil {
 		return nil
@@ -0,0 +1,39 @@
+package waqi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseJSONGeo(t *testing.T) {
+	a := assert.New(t)
+
+	// Moscow is at 55.75N 37.62E, so latitude comes first
+	var r responseJSON
+	err := json.Unmarshal([]byte(`{"status":"ok","data":{
+		"aqi":75,"idx":6251,
+		"city":{"geo":[55.75,37.62],"name":"Moscow"},
+		"iaqi":{"pm25":{"v":75}},
+		"time":{"iso":"2021-05-01T10:00:00+03:00"}
+	}}`), &r)
+	a.Nil(err)
+
+	status := r.ToStatus()
+	a.Equal(float32(55.75), status.Station.Lat)
+	a.Equal(float32(37.62), status.Station.Lon)
+}
+
+func TestSearchResultJSONGeo(t *testing.T) {
+	a := assert.New(t)
+
+	var r searchResultJSON
+	err := json.Unmarshal([]byte(`{"uid":6251,"aqi":"75","time":{"vtime":1619852400},
+		"station":{"name":"Moscow","geo":[55.75,37.62]}}`), &r)
+	a.Nil(err)
+
+	summary := r.ToStationSummary()
+	a.Equal(float32(55.75), summary.Station.Lat)
+	a.Equal(float32(37.62), summary.Station.Lon)
+}
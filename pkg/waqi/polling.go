@@ -0,0 +1,124 @@
+package waqi
+
+import (
+	"time"
+)
+
+const (
+	// DefaultPollInterval is default interval between station updates
+	DefaultPollInterval = 10 * time.Minute
+
+	// DefaultMinPollInterval is default interval between updates of stations with rapidly changing data
+	DefaultMinPollInterval = 3 * time.Minute
+
+	// DefaultMaxPollInterval is default interval between updates of stations with outdated data
+	DefaultMaxPollInterval = time.Hour
+
+	// pollLevelMargin is a distance to AQI level boundary within which station is polled more often
+	pollLevelMargin float32 = 10
+
+	// pollFastChangeRate is an AQI change rate (per hour) above which station is polled more often
+	pollFastChangeRate float32 = 20
+
+	// pollOutdatedAge is an age of station data after which station is polled with max interval
+	pollOutdatedAge = 3 * time.Hour
+
+	// maxPollTickPeriod is max period of checking whether stations are due to be updated
+	maxPollTickPeriod = time.Minute
+)
+
+// pollPolicy defines how often stations are updated
+type pollPolicy struct {
+	Interval time.Duration
+	Min      time.Duration
+	Max      time.Duration
+}
+
+func newPollPolicy(interval, min, max time.Duration) pollPolicy {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if min <= 0 || min > interval {
+		min = interval
+	}
+	if max < interval {
+		max = interval
+	}
+
+	return pollPolicy{Interval: interval, Min: min, Max: max}
+}
+
+// TickPeriod returns a period of checking whether stations are due to be updated
+func (p pollPolicy) TickPeriod() time.Duration {
+	if p.Min < maxPollTickPeriod {
+		return p.Min
+	}
+	return maxPollTickPeriod
+}
+
+// Next returns a delay until the next update of a station
+// Status is nil if station update has failed, delay is the current delay of the station.
+func (p pollPolicy) Next(prevStatus, status *Status, delay time.Duration, now time.Time) time.Duration {
+	if status == nil {
+		return p.Interval
+	}
+
+	// Station hasn't reported anything for hours, there is no point in polling it frequently
+	if !status.Time.IsZero() && now.Sub(status.Time) >= pollOutdatedAge {
+		return p.Max
+	}
+
+	// Station hasn't reported anything since previous update, so back off
+	if prevStatus != nil && !status.Time.After(prevStatus.Time) {
+		if delay < p.Interval {
+			delay = p.Interval
+		}
+
+		delay *= 2
+		if delay > p.Max {
+			delay = p.Max
+		}
+		return delay
+	}
+
+	if isNearAQILevelBoundary(status.AQI) || getAQIChangeRate(prevStatus, status) >= pollFastChangeRate {
+		return p.Min
+	}
+
+	return p.Interval
+}
+
+// isNearAQILevelBoundary returns true if AQI value is close to change its level
+func isNearAQILevelBoundary(aqi float32) bool {
+	for _, category := range USEPAStandard.Categories()[1:] {
+		distance := aqi - category.MinIndex
+		if distance < 0 {
+			distance = -distance
+		}
+
+		if distance <= pollLevelMargin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getAQIChangeRate returns an absolute AQI change rate (per hour) between two statuses
+func getAQIChangeRate(prevStatus, status *Status) float32 {
+	if prevStatus == nil {
+		return 0
+	}
+
+	elapsed := status.Time.Sub(prevStatus.Time).Hours()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	delta := status.AQI - prevStatus.AQI
+	if delta < 0 {
+		delta = -delta
+	}
+
+	return delta / float32(elapsed)
+}
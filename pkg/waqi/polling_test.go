@@ -0,0 +1,133 @@
+package waqi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollPolicy(t *testing.T) {
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	policy := newPollPolicy(10*time.Minute, 3*time.Minute, time.Hour)
+	status := func(age time.Duration, aqi float32) *Status {
+		return &Status{Time: now.Add(-age), AQI: aqi}
+	}
+
+	tests := []struct {
+		name       string
+		prevStatus *Status
+		status     *Status
+		delay      time.Duration
+		expected   time.Duration
+	}{
+		{"failed update", status(time.Hour, 30), nil, 40 * time.Minute, 10 * time.Minute},
+		{"first update", nil, status(10*time.Minute, 30), 10 * time.Minute, 10 * time.Minute},
+		{"steady value", status(time.Hour, 30), status(10*time.Minute, 32), 10 * time.Minute, 10 * time.Minute},
+		{"near level boundary", status(time.Hour, 30), status(10*time.Minute, 95), 10 * time.Minute, 3 * time.Minute},
+		{"fast change", status(time.Hour, 10), status(10*time.Minute, 40), 10 * time.Minute, 3 * time.Minute},
+		{"not advanced", status(10*time.Minute, 30), status(10*time.Minute, 30), 10 * time.Minute, 20 * time.Minute},
+		{"not advanced after fast update", status(10*time.Minute, 95), status(10*time.Minute, 95), 3 * time.Minute, 20 * time.Minute},
+		{"not advanced for long", status(20*time.Minute, 30), status(20*time.Minute, 30), 40 * time.Minute, time.Hour},
+		{"outdated", status(5*time.Hour, 95), status(4*time.Hour, 120), 10 * time.Minute, time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, policy.Next(test.prevStatus, test.status, test.delay, now))
+		})
+	}
+}
+
+func TestNewPollPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	policy := newPollPolicy(0, time.Hour, time.Minute)
+	a.Equal(DefaultPollInterval, policy.Interval)
+	a.Equal(DefaultPollInterval, policy.Min)
+	a.Equal(DefaultPollInterval, policy.Max)
+	a.Equal(time.Minute, policy.TickPeriod())
+
+	policy = newPollPolicy(10*time.Minute, 30*time.Second, time.Hour)
+	a.Equal(30*time.Second, policy.TickPeriod())
+}
+
+func TestFetcherAdaptivePolling(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	upstream := &timedAdapter{mutex: &sync.Mutex{}, status: &Status{Time: now.Add(-5 * time.Minute), AQI: 30}}
+	f := newFetcher(upstream, newPollPolicy(10*time.Minute, 3*time.Minute, time.Hour), 1, newTokenBucket(0, 0), 0, nil)
+	f.now = func() time.Time { return now }
+	f.spread = func(d time.Duration) time.Duration { return d }
+
+	f.Subscribe(123, &fakeListener{})
+	station := f.GetCurrentListeners()[123]
+	a.Equal(1, upstream.Calls())
+	a.Equal(10*time.Minute, station.Delay())
+
+	// Station shouldn't be updated until it's due
+	now = now.Add(5 * time.Minute)
+	f.UpdateOnce(context.Background())
+	a.Equal(1, upstream.Calls())
+
+	// Station hasn't reported anything new, so it should be polled less often
+	now = now.Add(5 * time.Minute)
+	f.UpdateOnce(context.Background())
+	a.Equal(2, upstream.Calls())
+	a.Equal(20*time.Minute, station.Delay())
+
+	// Value close to level boundary should be polled more often
+	now = now.Add(20 * time.Minute)
+	upstream.SetStatus(&Status{Time: now.Add(-5 * time.Minute), AQI: 48})
+	f.UpdateOnce(context.Background())
+	a.Equal(3, upstream.Calls())
+	a.Equal(3*time.Minute, station.Delay())
+
+	now = now.Add(3 * time.Minute)
+	f.UpdateOnce(context.Background())
+	a.Equal(4, upstream.Calls())
+}
+
+// timedAdapter is a fake adapter which returns a preset status
+type timedAdapter struct {
+	adapter
+	mutex  *sync.Mutex
+	status *Status
+	calls  int
+}
+
+func (s *timedAdapter) GetByStation(_ context.Context, stationID int) (*Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls++
+	status := *s.status
+	status.Station = &Station{ID: stationID}
+	return &status, nil
+}
+
+// Calls returns count of GetByStation calls
+func (s *timedAdapter) Calls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls
+}
+
+// SetStatus changes status returned by GetByStation
+func (s *timedAdapter) SetStatus(status *Status) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status = status
+}
+
+// fakeListener is a listener which ignores updates
+type fakeListener struct{}
+
+func (l *fakeListener) Update(_, _ *Status) error {
+	return nil
+}
@@ -0,0 +1,81 @@
+package waqi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFetchRate is default max rate of station updates (requests per second)
+	DefaultFetchRate = 10
+
+	// DefaultFetchBurst is default max count of station updates which are allowed to be run at once
+	DefaultFetchBurst = 10
+)
+
+// tokenBucket is a rate limiter which allows bursts of up to burst requests and rate requests per second on average
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	mutex  *sync.Mutex
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+		mutex:  &sync.Mutex{},
+	}
+}
+
+// Wait blocks until a request is allowed or context is over
+// Non-positive rate disables limiting.
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		delay := b.Reserve()
+		if delay <= 0 {
+			return nil
+		}
+
+		err := sleepContext(ctx, delay)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Reserve takes a token if there is one, otherwise it returns a delay until the next token is available
+func (b *tokenBucket) Reserve() time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,52 @@
+package waqi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(2, 3)
+	bucket.now = func() time.Time { return now }
+
+	// Burst is allowed at once
+	for i := 0; i < 3; i++ {
+		a.Equal(time.Duration(0), bucket.Reserve())
+	}
+	a.Equal(500*time.Millisecond, bucket.Reserve())
+
+	// Tokens are refilled with specified rate
+	now = now.Add(500 * time.Millisecond)
+	a.Equal(time.Duration(0), bucket.Reserve())
+	a.Equal(500*time.Millisecond, bucket.Reserve())
+
+	// Bucket never holds more than burst tokens
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		a.Equal(time.Duration(0), bucket.Reserve())
+	}
+	a.True(bucket.Reserve() > 0)
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	a := assert.New(t)
+
+	bucket := newTokenBucket(0.001, 1)
+	a.Nil(bucket.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	a.Equal(context.DeadlineExceeded, bucket.Wait(ctx))
+
+	// Zero rate disables limiting
+	bucket = newTokenBucket(0, 0)
+	for i := 0; i < 100; i++ {
+		a.Nil(bucket.Wait(context.Background()))
+	}
+}
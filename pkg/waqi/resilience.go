@@ -0,0 +1,366 @@
+package waqi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetryAttempts is default max count of attempts of a single WAQI service request
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryDelay is default delay before the first retry of a failed WAQI service request
+	DefaultRetryDelay = time.Second
+
+	// DefaultCircuitBreakerThreshold is default count of consecutive failures which opens circuit breaker
+	DefaultCircuitBreakerThreshold = 5
+
+	// DefaultCircuitBreakerCooldown is default duration of open circuit breaker state
+	DefaultCircuitBreakerCooldown = time.Minute
+
+	// maxRetryDelay is max delay between retries
+	// Requests are not retried if server asks to wait longer
+	maxRetryDelay = 30 * time.Second
+
+	// maxFallbackStatuses is max count of statuses kept as a fallback
+	maxFallbackStatuses = 1024
+)
+
+// ErrServiceUnavailable is returned when circuit breaker is open and there is no fallback value
+var ErrServiceUnavailable = errors.New("WAQI service is temporarily unavailable")
+
+// resilientServiceAdapter retries failed requests and stops calling WAQI service after repeated failures
+type resilientServiceAdapter struct {
+	adapter    adapter
+	logger     *log.Logger
+	attempts   int
+	delay      time.Duration
+	breaker    *circuitBreaker
+	sleep      func(ctx context.Context, d time.Duration) error
+	fallback   map[string]*Status
+	fallbackMu *sync.Mutex
+}
+
+func newResilientServiceAdapter(adapter adapter, attempts int, delay time.Duration, threshold int, cooldown time.Duration, logger *log.Logger) *resilientServiceAdapter {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return &resilientServiceAdapter{
+		adapter:    adapter,
+		logger:     logger,
+		attempts:   attempts,
+		delay:      delay,
+		breaker:    newCircuitBreaker(threshold, cooldown, time.Now),
+		sleep:      sleepContext,
+		fallback:   make(map[string]*Status),
+		fallbackMu: &sync.Mutex{},
+	}
+}
+
+// GetByCity fetches current measurements for city
+func (s *resilientServiceAdapter) GetByCity(ctx context.Context, city string) (*Status, error) {
+	return s.GetStatus(ctx, fmt.Sprintf("city/%s", strings.ToLower(city)), func() (*Status, error) {
+		return s.adapter.GetByCity(ctx, city)
+	})
+}
+
+// GetByStation fetches current measurements for station
+func (s *resilientServiceAdapter) GetByStation(ctx context.Context, stationID int) (*Status, error) {
+	return s.GetStatus(ctx, fmt.Sprintf("station/%d", stationID), func() (*Status, error) {
+		return s.adapter.GetByStation(ctx, stationID)
+	})
+}
+
+// GetByGeo fetches current measurements for geo coordinates
+func (s *resilientServiceAdapter) GetByGeo(ctx context.Context, lat, lon float32) (*Status, error) {
+	return s.GetStatus(ctx, fmt.Sprintf("geo/%0.2f/%0.2f", lat, lon), func() (*Status, error) {
+		return s.adapter.GetByGeo(ctx, lat, lon)
+	})
+}
+
+// Search looks up stations by a keyword
+func (s *resilientServiceAdapter) Search(ctx context.Context, keyword string) ([]*StationSummary, error) {
+	var results []*StationSummary
+	err := s.Do(ctx, func() error {
+		var err error
+		results, err = s.adapter.Search(ctx, keyword)
+		return err
+	})
+	return results, err
+}
+
+// GetByBounds returns stations located within specified rectangle
+func (s *resilientServiceAdapter) GetByBounds(ctx context.Context, bounds Bounds) ([]*StationSummary, error) {
+	var results []*StationSummary
+	err := s.Do(ctx, func() error {
+		var err error
+		results, err = s.adapter.GetByBounds(ctx, bounds)
+		return err
+	})
+	return results, err
+}
+
+// GetStatus fetches a status and remembers it as a fallback
+// If circuit breaker is open, the last received status is returned and marked as stale
+func (s *resilientServiceAdapter) GetStatus(ctx context.Context, key string, fn func() (*Status, error)) (*Status, error) {
+	var status *Status
+	err := s.Do(ctx, func() error {
+		var err error
+		status, err = fn()
+		return err
+	})
+	if err == nil {
+		s.PutFallback(key, status)
+		return status, nil
+	}
+
+	if s.breaker.IsOpen() {
+		if fallback := s.GetFallback(key); fallback != nil {
+			s.logger.Printf("using stale data for \"%s\" since WAQI service is unavailable", key)
+			return fallback, nil
+		}
+	}
+
+	return nil, err
+}
+
+// Do calls fn with retries unless circuit breaker is open
+func (s *resilientServiceAdapter) Do(ctx context.Context, fn func() error) error {
+	if !s.breaker.Allow() {
+		return ErrServiceUnavailable
+	}
+
+	var err error
+	for attempt := 0; attempt < s.attempts; attempt++ {
+		if attempt > 0 {
+			delay, ok := s.GetRetryDelay(attempt, err)
+			if !ok {
+				break
+			}
+
+			if sleepErr := s.sleep(ctx, delay); sleepErr != nil {
+				s.breaker.Release()
+				return err
+			}
+		}
+
+		err = fn()
+		if err == nil {
+			s.breaker.Success()
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			// Request was cancelled by caller, so it tells nothing about service health
+			s.breaker.Release()
+			return err
+		}
+
+		if !isTransientError(err) {
+			// Service is up but has rejected the request
+			s.breaker.Success()
+			return err
+		}
+	}
+
+	var respErr *responseError
+	if errors.As(err, &respErr) && respErr.RetryAfter > maxRetryDelay {
+		s.breaker.OpenFor(respErr.RetryAfter)
+	} else {
+		s.breaker.Failure()
+	}
+
+	if s.breaker.IsOpen() {
+		s.logger.Printf("circuit breaker is open: %s", err)
+	}
+
+	return err
+}
+
+// GetRetryDelay returns a delay before specified attempt
+// Server-provided delay is preferred, otherwise jittered exponential backoff is used
+func (s *resilientServiceAdapter) GetRetryDelay(attempt int, err error) (time.Duration, bool) {
+	var respErr *responseError
+	if errors.As(err, &respErr) && respErr.RetryAfter > 0 {
+		if respErr.RetryAfter > maxRetryDelay {
+			return 0, false
+		}
+
+		return respErr.RetryAfter, true
+	}
+
+	delay := s.delay << uint(attempt-1)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	// Pick a random delay from [delay/2, delay) so that clients wouldn't retry in sync
+	half := delay / 2
+	if half > 0 {
+		delay = half + time.Duration(rand.Int63n(int64(half)))
+	}
+
+	return delay, true
+}
+
+// PutFallback remembers a status as a fallback value
+func (s *resilientServiceAdapter) PutFallback(key string, status *Status) {
+	s.fallbackMu.Lock()
+	defer s.fallbackMu.Unlock()
+
+	if _, exists := s.fallback[key]; !exists && len(s.fallback) >= maxFallbackStatuses {
+		for k := range s.fallback {
+			delete(s.fallback, k)
+			break
+		}
+	}
+
+	s.fallback[key] = status
+}
+
+// GetFallback returns a copy of the last received status marked as stale
+func (s *resilientServiceAdapter) GetFallback(key string) *Status {
+	s.fallbackMu.Lock()
+	defer s.fallbackMu.Unlock()
+
+	status, exists := s.fallback[key]
+	if !exists {
+		return nil
+	}
+
+	stale := *status
+	stale.Stale = true
+	return &stale
+}
+
+// Close shuts down adapter
+func (s *resilientServiceAdapter) Close() error {
+	return s.adapter.Close()
+}
+
+// isTransientError returns true if a failed request might succeed if retried
+func isTransientError(err error) bool {
+	var respErr *responseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode == 429 || respErr.StatusCode >= 500
+	}
+
+	// Service has responded with an error message
+	if _, ok := err.(Error); ok {
+		return false
+	}
+
+	return true
+}
+
+// sleepContext waits for specified duration or until context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// circuitBreaker tracks consecutive failures of a service
+// Once failure count reaches threshold, breaker opens and rejects calls until cooldown is over.
+// After that, a single probe call is allowed: it either closes breaker or opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	mutex     *sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, now func() time.Time) *circuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       now,
+		mutex:     &sync.Mutex{},
+	}
+}
+
+// Allow returns true if a call is allowed
+func (b *circuitBreaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	if b.now().Before(b.openUntil) || b.probing {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// IsOpen returns true if calls are being rejected
+func (b *circuitBreaker) IsOpen() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.failures >= b.threshold
+}
+
+// Success records a successful call and closes breaker
+func (b *circuitBreaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a failed call and opens breaker if threshold is reached
+func (b *circuitBreaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// OpenFor opens breaker for specified duration regardless of failure count
+func (b *circuitBreaker) OpenFor(d time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.failures < b.threshold {
+		b.failures = b.threshold
+	}
+	b.probing = false
+	b.openUntil = b.now().Add(d)
+}
+
+// Release completes a call which outcome tells nothing about service health
+func (b *circuitBreaker) Release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probing = false
+}
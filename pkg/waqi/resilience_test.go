@@ -0,0 +1,146 @@
+package waqi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResilientServiceAdapterRetry(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &flakyAdapter{
+		status: &Status{Station: &Station{ID: 123}, AQI: 42},
+		errs:   []error{errors.New("connection reset"), &responseError{StatusCode: 503}},
+	}
+	adapter, delays := newTestResilientServiceAdapter(upstream, 5)
+
+	// Transient errors should be retried with growing delays
+	status, err := adapter.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal(float32(42), status.AQI)
+	a.False(status.Stale)
+	a.Equal(3, upstream.calls)
+	a.Len(*delays, 2)
+	a.True((*delays)[0] >= 50*time.Millisecond && (*delays)[0] < 100*time.Millisecond)
+	a.True((*delays)[1] >= 100*time.Millisecond && (*delays)[1] < 200*time.Millisecond)
+
+	// Server errors should not be retried
+	upstream.calls = 0
+	upstream.errs = []error{Error("server error: Unknown station")}
+	_, err = adapter.GetByStation(context.Background(), 123)
+	a.Equal(Error("server error: Unknown station"), err)
+	a.Equal(1, upstream.calls)
+	a.False(adapter.breaker.IsOpen())
+}
+
+func TestResilientServiceAdapterRetryAfter(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &flakyAdapter{
+		status: &Status{Station: &Station{ID: 123}},
+		errs:   []error{&responseError{StatusCode: 429, RetryAfter: 7 * time.Second}},
+	}
+	adapter, delays := newTestResilientServiceAdapter(upstream, 5)
+
+	_, err := adapter.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.Equal([]time.Duration{7 * time.Second}, *delays)
+
+	// Requests should be suspended if server asks to wait too long
+	upstream.calls = 0
+	upstream.errs = []error{&responseError{StatusCode: 429, RetryAfter: time.Hour}}
+	_, err = adapter.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.True(adapter.breaker.IsOpen())
+	a.Equal(1, upstream.calls)
+}
+
+func TestResilientServiceAdapterCircuitBreaker(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := &flakyAdapter{status: &Status{Station: &Station{ID: 123}, AQI: 42}}
+	adapter, _ := newTestResilientServiceAdapter(upstream, 2)
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	adapter.breaker.now = func() time.Time { return now }
+
+	_, err := adapter.GetByStation(context.Background(), 123)
+	a.Nil(err)
+
+	// Breaker should open after repeated failures
+	failure := &responseError{StatusCode: 502}
+	upstream.calls = 0
+	upstream.errs = []error{failure, failure, failure, failure, failure, failure}
+	_, err = adapter.GetByStation(context.Background(), 456)
+	a.Equal(failure, err)
+	a.False(adapter.breaker.IsOpen())
+
+	status, err := adapter.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.True(adapter.breaker.IsOpen())
+	a.True(status.Stale)
+	a.Equal(float32(42), status.AQI)
+	a.Equal(6, upstream.calls)
+
+	// Service should not be called while breaker is open
+	status, err = adapter.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.True(status.Stale)
+	_, err = adapter.GetByStation(context.Background(), 456)
+	a.Equal(ErrServiceUnavailable, err)
+	a.Equal(6, upstream.calls)
+
+	// A probe call should be allowed after cooldown
+	now = now.Add(DefaultCircuitBreakerCooldown)
+	upstream.calls = 0
+	upstream.errs = nil
+	status, err = adapter.GetByStation(context.Background(), 123)
+	a.Nil(err)
+	a.False(status.Stale)
+	a.False(adapter.breaker.IsOpen())
+	a.Equal(1, upstream.calls)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	a.Equal(120*time.Second, parseRetryAfter("120", now))
+	a.Equal(30*time.Second, parseRetryAfter("Sat, 01 May 2021 10:00:30 GMT", now))
+	a.Equal(time.Duration(0), parseRetryAfter("Sat, 01 May 2021 09:00:00 GMT", now))
+	a.Equal(time.Duration(0), parseRetryAfter("soon", now))
+	a.Equal(time.Duration(0), parseRetryAfter("", now))
+}
+
+func newTestResilientServiceAdapter(upstream adapter, threshold int) (*resilientServiceAdapter, *[]time.Duration) {
+	adapter := newResilientServiceAdapter(upstream, 3, 100*time.Millisecond, threshold, DefaultCircuitBreakerCooldown, log.Default())
+
+	delays := make([]time.Duration, 0)
+	adapter.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	return adapter, &delays
+}
+
+// flakyAdapter is a fake adapter which fails with specified errors before returning a status
+type flakyAdapter struct {
+	adapter
+	status *Status
+	errs   []error
+	calls  int
+}
+
+func (s *flakyAdapter) GetByStation(_ context.Context, _ int) (*Status, error) {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return nil, s.errs[s.calls-1]
+	}
+
+	return s.status, nil
+}
@@ -0,0 +1,174 @@
+package waqi
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const digestCheckPeriod = time.Minute
+
+// scheduler delivers daily digests at scheduled times
+type scheduler struct {
+	adapter adapter
+	history historyStore
+	logger  *log.Logger
+	entries map[string]*scheduleEntry
+	mutex   *sync.Mutex
+	ticker  *time.Ticker
+	cancel  context.CancelFunc
+	done    chan bool
+}
+
+// scheduleEntry is a daily digest schedule
+type scheduleEntry struct {
+	key       string
+	stationID int
+	timeOfDay int
+	loc       *time.Location
+	listener  DigestListener
+	next      time.Time
+}
+
+func newScheduler(adapter adapter, history historyStore, logger *log.Logger) *scheduler {
+	return &scheduler{
+		adapter: adapter,
+		history: history,
+		logger:  logger,
+		entries: make(map[string]*scheduleEntry),
+		mutex:   &sync.Mutex{},
+		done:    make(chan bool),
+	}
+}
+
+// Schedule adds a daily digest schedule
+// Existing schedule with the same key is replaced
+func (s *scheduler) Schedule(key string, stationID int, timeOfDay int, loc *time.Location, listener DigestListener) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := &scheduleEntry{
+		key:       key,
+		stationID: stationID,
+		timeOfDay: timeOfDay,
+		loc:       loc,
+		listener:  listener,
+		next:      nextDigestTime(time.Now(), timeOfDay, loc),
+	}
+	s.entries[key] = entry
+
+	s.logger.Printf("scheduled digest \"%s\" for station #%d at %s", key, stationID, entry.next)
+}
+
+// Unschedule removes a daily digest schedule
+func (s *scheduler) Unschedule(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.entries[key]
+	if !exists {
+		return
+	}
+
+	delete(s.entries, key)
+	s.logger.Printf("unscheduled digest \"%s\"", key)
+}
+
+// Start starts background digest delivery
+func (s *scheduler) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ticker == nil {
+		var ctx context.Context
+		ctx, s.cancel = context.WithCancel(context.Background())
+		s.ticker = time.NewTicker(digestCheckPeriod)
+		go s.ScheduleLoop(ctx, s.ticker)
+	}
+}
+
+// Stop stops background digest delivery
+func (s *scheduler) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ticker != nil {
+		s.ticker.Stop()
+		s.ticker = nil
+
+		s.cancel()
+		s.done <- true
+	}
+}
+
+// ScheduleLoop runs background digest delivery loop
+func (s *scheduler) ScheduleLoop(ctx context.Context, ticker *time.Ticker) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.RunOnce(ctx, time.Now())
+		}
+	}
+}
+
+// RunOnce delivers all digests that are due at specified moment
+func (s *scheduler) RunOnce(ctx context.Context, now time.Time) {
+	for _, entry := range s.GetDueEntries(now) {
+		err := s.Deliver(ctx, entry, now)
+		if err != nil {
+			s.logger.Printf("unable to deliver digest \"%s\": %s", entry.key, err)
+		}
+	}
+}
+
+// GetDueEntries returns schedules that are due at specified moment and moves them to their next occurrence
+func (s *scheduler) GetDueEntries(now time.Time) []*scheduleEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := make([]*scheduleEntry, 0)
+	for _, entry := range s.entries {
+		if entry.next.After(now) {
+			continue
+		}
+
+		entries = append(entries, entry)
+		entry.next = nextDigestTime(now, entry.timeOfDay, entry.loc)
+	}
+
+	return entries
+}
+
+// Deliver generates a digest and pushes it to listener
+func (s *scheduler) Deliver(ctx context.Context, entry *scheduleEntry, now time.Time) error {
+	status, err := s.adapter.GetByStation(ctx, entry.stationID)
+	if err != nil {
+		return err
+	}
+
+	from := now.Add(-DigestPeriod)
+	var history []*Status
+	if s.history != nil {
+		history, err = s.history.Query(entry.stationID, from, now)
+		if err != nil {
+			s.logger.Printf("unable to query history for station #%d: %s", entry.stationID, err)
+		}
+	}
+
+	digest := CalcDigest(status, history, from, now)
+	return entry.listener.Digest(entry.key, digest)
+}
+
+// nextDigestTime returns the nearest moment after now which matches specified time of day in specified location
+func nextDigestTime(now time.Time, timeOfDay int, loc *time.Location) time.Time {
+	local := now.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), timeOfDay/60, timeOfDay%60, 0, 0, loc)
+	if !next.After(now) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, timeOfDay/60, timeOfDay%60, 0, 0, loc)
+	}
+
+	return next
+}
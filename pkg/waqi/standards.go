@@ -0,0 +1,593 @@
+package waqi
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Standard is an air quality index standard
+type Standard string
+
+const (
+	// USEPAStandard is US EPA Air Quality Index (2024 revision)
+	// WAQI reports pollutant values as sub-indices of this standard.
+	USEPAStandard Standard = "us_epa"
+
+	// EUCAQIStandard is European Common Air Quality Index (CAQI, background grid)
+	EUCAQIStandard Standard = "eu_caqi"
+
+	// IndiaNAQIStandard is India National Air Quality Index (CPCB, 2014)
+	IndiaNAQIStandard Standard = "in_naqi"
+
+	// ChinaAQIStandard is China Air Quality Index (HJ 633-2012)
+	ChinaAQIStandard Standard = "cn_aqi"
+)
+
+// Standards contains all known AQI standards
+var Standards = []Standard{
+	USEPAStandard,
+	EUCAQIStandard,
+	IndiaNAQIStandard,
+	ChinaAQIStandard,
+}
+
+// String converts a value of Standard into string
+func (s Standard) String() string {
+	switch s {
+	case USEPAStandard:
+		return "US EPA AQI"
+	case EUCAQIStandard:
+		return "EU CAQI"
+	case IndiaNAQIStandard:
+		return "India NAQI"
+	case ChinaAQIStandard:
+		return "China AQI"
+	default:
+		return string(s)
+	}
+}
+
+// OrDefault returns US EPA standard for an empty value
+func (s Standard) OrDefault() Standard {
+	if s == "" {
+		return USEPAStandard
+	}
+	return s
+}
+
+// ParseStandard parses an AQI standard from its string representation
+// Both identifiers (e.g. "us_epa") and short names (e.g. "epa", "caqi") are accepted
+func ParseStandard(str string) (Standard, error) {
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case string(USEPAStandard), "epa", "us":
+		return USEPAStandard, nil
+	case string(EUCAQIStandard), "caqi", "eu":
+		return EUCAQIStandard, nil
+	case string(IndiaNAQIStandard), "naqi", "in":
+		return IndiaNAQIStandard, nil
+	case string(ChinaAQIStandard), "cn", "china":
+		return ChinaAQIStandard, nil
+	default:
+		return "", Error(fmt.Sprintf("unknown AQI standard: \"%s\"", str))
+	}
+}
+
+// Unit is a unit of pollutant concentration
+type Unit string
+
+const (
+	// MicrogramsPerCubicMeterUnit is micrograms per cubic meter
+	MicrogramsPerCubicMeterUnit Unit = "μg/m3"
+
+	// MilligramsPerCubicMeterUnit is milligrams per cubic meter
+	MilligramsPerCubicMeterUnit Unit = "mg/m3"
+
+	// PartsPerBillionUnit is parts per billion (gases only)
+	PartsPerBillionUnit Unit = "ppb"
+
+	// PartsPerMillionUnit is parts per million (gases only)
+	PartsPerMillionUnit Unit = "ppm"
+)
+
+const (
+	// ErrUnknownScale is returned when AQI standard doesn't define a scale for a pollutant and an averaging period
+	ErrUnknownScale = Error("pollutant is not covered by AQI standard")
+
+	// ErrOutOfScale is returned when a value is beyond the range of AQI scale
+	ErrOutOfScale = Error("value is out of AQI scale")
+
+	// ErrIncompatibleUnit is returned when a concentration can't be converted into specified unit
+	ErrIncompatibleUnit = Error("incompatible concentration unit")
+)
+
+// molarVolume is a volume (in liters) of one mole of gas at 25°C and 1 atm
+const molarVolume = 24.45
+
+// molecularWeights contains molecular weights (in g/mol) of gaseous pollutants
+var molecularWeights = map[Parameter]float64{
+	O3Parameter:  48.00,
+	NO2Parameter: 46.01,
+	SO2Parameter: 64.07,
+	COParameter:  28.01,
+}
+
+// ConvertConcentration converts a pollutant concentration from one unit into another
+// Conversion between mass and volume units is available for gases only and assumes 25°C and 1 atm
+func ConvertConcentration(parameter Parameter, value float32, from, to Unit) (float32, error) {
+	if from == to {
+		return value, nil
+	}
+
+	fromFactor, err := getConcentrationFactor(parameter, from)
+	if err != nil {
+		return 0, err
+	}
+
+	toFactor, err := getConcentrationFactor(parameter, to)
+	if err != nil {
+		return 0, err
+	}
+
+	return float32(float64(value) * fromFactor / toFactor), nil
+}
+
+// getConcentrationFactor returns a multiplier which converts concentration in specified unit into μg/m3
+func getConcentrationFactor(parameter Parameter, unit Unit) (float64, error) {
+	switch unit {
+	case MicrogramsPerCubicMeterUnit:
+		return 1, nil
+	case MilligramsPerCubicMeterUnit:
+		return 1000, nil
+	case PartsPerBillionUnit, PartsPerMillionUnit:
+		weight, exists := molecularWeights[parameter]
+		if !exists {
+			return 0, ErrIncompatibleUnit
+		}
+
+		factor := weight / molarVolume
+		if unit == PartsPerMillionUnit {
+			factor *= 1000
+		}
+		return factor, nil
+	default:
+		return 0, ErrIncompatibleUnit
+	}
+}
+
+// breakpoint is a row of AQI breakpoint table
+// Concentrations within [cLow, cHigh] map linearly to indices within [iLow, iHigh]
+type breakpoint struct {
+	cLow  float64
+	cHigh float64
+	iLow  float64
+	iHigh float64
+}
+
+// PollutantScale is a breakpoint table of a pollutant within an AQI standard
+type PollutantScale struct {
+	// AQI standard
+	Standard Standard
+
+	// Pollutant
+	Parameter Parameter
+
+	// Averaging period of concentrations
+	Period time.Duration
+
+	// Unit of concentrations
+	Unit Unit
+
+	breakpoints []breakpoint
+
+	// Number of decimals concentrations are truncated to (negative value disables truncation)
+	precision int
+
+	// Index rounding function (nil disables rounding)
+	round func(float64) float64
+
+	// If set, concentrations above the highest breakpoint are extrapolated using the slope of the highest band
+	open bool
+
+	// Max index value of extrapolated concentrations (zero means no limit)
+	maxIndex float64
+}
+
+// ToIndex converts a concentration into an index value
+// Returns ErrOutOfScale if concentration is beyond the range of the scale
+func (p *PollutantScale) ToIndex(concentration float32, unit Unit) (float32, error) {
+	value, err := ConvertConcentration(p.Parameter, concentration, unit, p.Unit)
+	if err != nil {
+		return 0, err
+	}
+
+	c := float64(value)
+	if p.precision >= 0 {
+		scale := math.Pow(10, float64(p.precision))
+		c = math.Floor(c*scale+1e-6) / scale
+	}
+
+	if c < 0 || c < p.breakpoints[0].cLow {
+		return 0, ErrOutOfScale
+	}
+
+	bp, ok := p.findBand(func(bp breakpoint) bool { return c <= bp.cHigh })
+	if !ok {
+		return 0, ErrOutOfScale
+	}
+
+	index := (bp.iHigh-bp.iLow)/(bp.cHigh-bp.cLow)*(c-bp.cLow) + bp.iLow
+	if p.maxIndex > 0 && index > p.maxIndex {
+		index = p.maxIndex
+	}
+	if p.round != nil {
+		index = p.round(index)
+	}
+
+	return float32(index), nil
+}
+
+// ToConcentration converts an index value into the lowest concentration which has this index
+// Returns ErrOutOfScale if index is beyond the range of the scale
+func (p *PollutantScale) ToConcentration(index float32, unit Unit) (float32, error) {
+	i := float64(index)
+	if i < p.breakpoints[0].iLow || (p.maxIndex > 0 && i > p.maxIndex) {
+		return 0, ErrOutOfScale
+	}
+
+	bp, ok := p.findBand(func(bp breakpoint) bool { return i <= bp.iHigh })
+	if !ok {
+		return 0, ErrOutOfScale
+	}
+
+	c := (i-bp.iLow)*(bp.cHigh-bp.cLow)/(bp.iHigh-bp.iLow) + bp.cLow
+	return ConvertConcentration(p.Parameter, float32(c), p.Unit, unit)
+}
+
+// findBand returns the first band matching a predicate
+// Open scales fall back to their highest band
+func (p *PollutantScale) findBand(match func(bp breakpoint) bool) (breakpoint, bool) {
+	for _, bp := range p.breakpoints {
+		if match(bp) {
+			return bp, true
+		}
+	}
+
+	if p.open {
+		return p.breakpoints[len(p.breakpoints)-1], true
+	}
+
+	return breakpoint{}, false
+}
+
+// Scales returns all pollutant scales of an AQI standard
+func (s Standard) Scales() []*PollutantScale {
+	result := make([]*PollutantScale, 0)
+	for _, scale := range pollutantScales {
+		if scale.Standard == s {
+			result = append(result, scale)
+		}
+	}
+
+	return result
+}
+
+// Scale returns a scale of a pollutant for specified averaging period
+// Returns ErrUnknownScale if standard doesn't define such scale
+func (s Standard) Scale(parameter Parameter, period time.Duration) (*PollutantScale, error) {
+	for _, scale := range pollutantScales {
+		if scale.Standard == s && scale.Parameter == parameter && scale.Period == period {
+			return scale, nil
+		}
+	}
+
+	return nil, ErrUnknownScale
+}
+
+// ToIndex converts a pollutant concentration averaged over specified period into an index value
+func (s Standard) ToIndex(parameter Parameter, period time.Duration, concentration float32, unit Unit) (float32, error) {
+	scale, err := s.Scale(parameter, period)
+	if err != nil {
+		return 0, err
+	}
+
+	return scale.ToIndex(concentration, unit)
+}
+
+// ToConcentration converts an index value into a pollutant concentration averaged over specified period
+func (s Standard) ToConcentration(parameter Parameter, period time.Duration, index float32, unit Unit) (float32, error) {
+	scale, err := s.Scale(parameter, period)
+	if err != nil {
+		return 0, err
+	}
+
+	return scale.ToConcentration(index, unit)
+}
+
+// topIndex returns the highest index value of a scale
+func (p *PollutantScale) topIndex() float32 {
+	if p.maxIndex > 0 {
+		return float32(p.maxIndex)
+	}
+	return float32(p.breakpoints[len(p.breakpoints)-1].iHigh)
+}
+
+// grid builds a breakpoint table with adjacent bands from (concentration, index) points
+func grid(points ...[2]float64) []breakpoint {
+	breakpoints := make([]breakpoint, 0, len(points)-1)
+	for i := 1; i < len(points); i++ {
+		breakpoints = append(breakpoints, breakpoint{
+			cLow:  points[i-1][0],
+			cHigh: points[i][0],
+			iLow:  points[i-1][1],
+			iHigh: points[i][1],
+		})
+	}
+	return breakpoints
+}
+
+// pollutantScales contains breakpoint tables of all known AQI standards
+var pollutantScales = []*PollutantScale{
+	// US EPA, Technical Assistance Document for the Reporting of Daily Air Quality (2024)
+	// Concentrations are truncated, indices are rounded to the nearest integer
+	{
+		Standard: USEPAStandard, Parameter: PM25Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: 1, round: math.Round,
+		breakpoints: []breakpoint{
+			{0.0, 9.0, 0, 50},
+			{9.1, 35.4, 51, 100},
+			{35.5, 55.4, 101, 150},
+			{55.5, 125.4, 151, 200},
+			{125.5, 225.4, 201, 300},
+			{225.5, 325.4, 301, 500},
+		},
+	},
+	{
+		Standard: USEPAStandard, Parameter: PM10Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: 0, round: math.Round,
+		breakpoints: []breakpoint{
+			{0, 54, 0, 50},
+			{55, 154, 51, 100},
+			{155, 254, 101, 150},
+			{255, 354, 151, 200},
+			{355, 424, 201, 300},
+			{425, 604, 301, 500},
+		},
+	},
+	{
+		// 8-hour ozone is not defined above 0.200 ppm, 1-hour scale should be used instead
+		Standard: USEPAStandard, Parameter: O3Parameter, Period: 8 * time.Hour, Unit: PartsPerMillionUnit,
+		precision: 3, round: math.Round,
+		breakpoints: []breakpoint{
+			{0.000, 0.054, 0, 50},
+			{0.055, 0.070, 51, 100},
+			{0.071, 0.085, 101, 150},
+			{0.086, 0.105, 151, 200},
+			{0.106, 0.200, 201, 300},
+		},
+	},
+	{
+		// 1-hour ozone is not defined below 0.125 ppm, 8-hour scale should be used instead
+		Standard: USEPAStandard, Parameter: O3Parameter, Period: time.Hour, Unit: PartsPerMillionUnit,
+		precision: 3, round: math.Round,
+		breakpoints: []breakpoint{
+			{0.125, 0.164, 101, 150},
+			{0.165, 0.204, 151, 200},
+			{0.205, 0.404, 201, 300},
+			{0.405, 0.604, 301, 500},
+		},
+	},
+	{
+		Standard: USEPAStandard, Parameter: COParameter, Period: 8 * time.Hour, Unit: PartsPerMillionUnit,
+		precision: 1, round: math.Round,
+		breakpoints: []breakpoint{
+			{0.0, 4.4, 0, 50},
+			{4.5, 9.4, 51, 100},
+			{9.5, 12.4, 101, 150},
+			{12.5, 15.4, 151, 200},
+			{15.5, 30.4, 201, 300},
+			{30.5, 50.4, 301, 500},
+		},
+	},
+	{
+		Standard: USEPAStandard, Parameter: SO2Parameter, Period: time.Hour, Unit: PartsPerBillionUnit,
+		precision: 0, round: math.Round,
+		breakpoints: []breakpoint{
+			{0, 35, 0, 50},
+			{36, 75, 51, 100},
+			{76, 185, 101, 150},
+			{186, 304, 151, 200},
+			{305, 604, 201, 300},
+			{605, 1004, 301, 500},
+		},
+	},
+	{
+		Standard: USEPAStandard, Parameter: NO2Parameter, Period: time.Hour, Unit: PartsPerBillionUnit,
+		precision: 0, round: math.Round,
+		breakpoints: []breakpoint{
+			{0, 53, 0, 50},
+			{54, 100, 51, 100},
+			{101, 360, 101, 150},
+			{361, 649, 151, 200},
+			{650, 1249, 201, 300},
+			{1250, 2049, 301, 500},
+		},
+	},
+
+	// EU CAQI, background hourly and daily grids
+	// Index is continuous, values above 100 ("very high") are extrapolated
+	{
+		Standard: EUCAQIStandard, Parameter: NO2Parameter, Period: time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, open: true,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{50, 25}, [2]float64{100, 50}, [2]float64{200, 75}, [2]float64{400, 100}),
+	},
+	{
+		Standard: EUCAQIStandard, Parameter: PM10Parameter, Period: time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, open: true,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{25, 25}, [2]float64{50, 50}, [2]float64{90, 75}, [2]float64{180, 100}),
+	},
+	{
+		Standard: EUCAQIStandard, Parameter: PM10Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, open: true,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{15, 25}, [2]float64{30, 50}, [2]float64{50, 75}, [2]float64{100, 100}),
+	},
+	{
+		Standard: EUCAQIStandard, Parameter: PM25Parameter, Period: time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, open: true,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{15, 25}, [2]float64{30, 50}, [2]float64{55, 75}, [2]float64{110, 100}),
+	},
+	{
+		Standard: EUCAQIStandard, Parameter: PM25Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, open: true,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{10, 25}, [2]float64{20, 50}, [2]float64{30, 75}, [2]float64{60, 100}),
+	},
+	{
+		Standard: EUCAQIStandard, Parameter: O3Parameter, Period: time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, open: true,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{60, 25}, [2]float64{120, 50}, [2]float64{180, 75}, [2]float64{240, 100}),
+	},
+	{
+		Standard: EUCAQIStandard, Parameter: COParameter, Period: 8 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, open: true,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{5000, 25}, [2]float64{7500, 50}, [2]float64{10000, 75}, [2]float64{20000, 100}),
+	},
+	{
+		Standard: EUCAQIStandard, Parameter: SO2Parameter, Period: time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, open: true,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{50, 25}, [2]float64{100, 50}, [2]float64{350, 75}, [2]float64{500, 100}),
+	},
+
+	// India NAQI, CPCB National Air Quality Index (2014)
+	// Standard doesn't define an upper concentration of "severe" band, so it's extrapolated up to 500
+	{
+		Standard: IndiaNAQIStandard, Parameter: PM10Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Round, open: true, maxIndex: 500,
+		breakpoints: []breakpoint{
+			{0, 50, 0, 50},
+			{51, 100, 51, 100},
+			{101, 250, 101, 200},
+			{251, 350, 201, 300},
+			{351, 430, 301, 400},
+		},
+	},
+	{
+		Standard: IndiaNAQIStandard, Parameter: PM25Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Round, open: true, maxIndex: 500,
+		breakpoints: []breakpoint{
+			{0, 30, 0, 50},
+			{31, 60, 51, 100},
+			{61, 90, 101, 200},
+			{91, 120, 201, 300},
+			{121, 250, 301, 400},
+		},
+	},
+	{
+		Standard: IndiaNAQIStandard, Parameter: NO2Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Round, open: true, maxIndex: 500,
+		breakpoints: []breakpoint{
+			{0, 40, 0, 50},
+			{41, 80, 51, 100},
+			{81, 180, 101, 200},
+			{181, 280, 201, 300},
+			{281, 400, 301, 400},
+		},
+	},
+	{
+		Standard: IndiaNAQIStandard, Parameter: O3Parameter, Period: 8 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Round, open: true, maxIndex: 500,
+		breakpoints: []breakpoint{
+			{0, 50, 0, 50},
+			{51, 100, 51, 100},
+			{101, 168, 101, 200},
+			{169, 208, 201, 300},
+			{209, 748, 301, 400},
+		},
+	},
+	{
+		Standard: IndiaNAQIStandard, Parameter: COParameter, Period: 8 * time.Hour, Unit: MilligramsPerCubicMeterUnit,
+		precision: -1, round: math.Round, open: true, maxIndex: 500,
+		breakpoints: []breakpoint{
+			{0, 1.0, 0, 50},
+			{1.1, 2.0, 51, 100},
+			{2.1, 10, 101, 200},
+			{10.1, 17, 201, 300},
+			{17.1, 34, 301, 400},
+		},
+	},
+	{
+		Standard: IndiaNAQIStandard, Parameter: SO2Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Round, open: true, maxIndex: 500,
+		breakpoints: []breakpoint{
+			{0, 40, 0, 50},
+			{41, 80, 51, 100},
+			{81, 380, 101, 200},
+			{381, 800, 201, 300},
+			{801, 1600, 301, 400},
+		},
+	},
+
+	// China AQI, HJ 633-2012 Technical Regulation on Ambient Air Quality Index
+	// Indices are rounded up to an integer
+	{
+		Standard: ChinaAQIStandard, Parameter: SO2Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{50, 50}, [2]float64{150, 100}, [2]float64{475, 150},
+			[2]float64{800, 200}, [2]float64{1600, 300}, [2]float64{2100, 400}, [2]float64{2620, 500}),
+	},
+	{
+		// 1-hour SO2 is not defined above 800 μg/m3, 24-hour scale should be used instead
+		Standard: ChinaAQIStandard, Parameter: SO2Parameter, Period: time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{150, 50}, [2]float64{500, 100}, [2]float64{650, 150}, [2]float64{800, 200}),
+	},
+	{
+		Standard: ChinaAQIStandard, Parameter: NO2Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{40, 50}, [2]float64{80, 100}, [2]float64{180, 150},
+			[2]float64{280, 200}, [2]float64{565, 300}, [2]float64{750, 400}, [2]float64{940, 500}),
+	},
+	{
+		Standard: ChinaAQIStandard, Parameter: NO2Parameter, Period: time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{100, 50}, [2]float64{200, 100}, [2]float64{700, 150},
+			[2]float64{1200, 200}, [2]float64{2340, 300}, [2]float64{3090, 400}, [2]float64{3840, 500}),
+	},
+	{
+		Standard: ChinaAQIStandard, Parameter: PM10Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{50, 50}, [2]float64{150, 100}, [2]float64{250, 150},
+			[2]float64{350, 200}, [2]float64{420, 300}, [2]float64{500, 400}, [2]float64{600, 500}),
+	},
+	{
+		Standard: ChinaAQIStandard, Parameter: COParameter, Period: 24 * time.Hour, Unit: MilligramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{2, 50}, [2]float64{4, 100}, [2]float64{14, 150},
+			[2]float64{24, 200}, [2]float64{36, 300}, [2]float64{48, 400}, [2]float64{60, 500}),
+	},
+	{
+		Standard: ChinaAQIStandard, Parameter: COParameter, Period: time.Hour, Unit: MilligramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{5, 50}, [2]float64{10, 100}, [2]float64{35, 150},
+			[2]float64{60, 200}, [2]float64{90, 300}, [2]float64{120, 400}, [2]float64{150, 500}),
+	},
+	{
+		Standard: ChinaAQIStandard, Parameter: O3Parameter, Period: time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{160, 50}, [2]float64{200, 100}, [2]float64{300, 150},
+			[2]float64{400, 200}, [2]float64{800, 300}, [2]float64{1000, 400}, [2]float64{1200, 500}),
+	},
+	{
+		// 8-hour ozone is not defined above 800 μg/m3, 1-hour scale should be used instead
+		Standard: ChinaAQIStandard, Parameter: O3Parameter, Period: 8 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{100, 50}, [2]float64{160, 100}, [2]float64{215, 150},
+			[2]float64{265, 200}, [2]float64{800, 300}),
+	},
+	{
+		Standard: ChinaAQIStandard, Parameter: PM25Parameter, Period: 24 * time.Hour, Unit: MicrogramsPerCubicMeterUnit,
+		precision: -1, round: math.Ceil,
+		breakpoints: grid([2]float64{0, 0}, [2]float64{35, 50}, [2]float64{75, 100}, [2]float64{115, 150},
+			[2]float64{150, 200}, [2]float64{250, 300}, [2]float64{350, 400}, [2]float64{500, 500}),
+	},
+}
@@ -0,0 +1,173 @@
+package waqi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUSEPAStandard(t *testing.T) {
+	a := assert.New(t)
+
+	// Examples from EPA Technical Assistance Document for the Reporting of Daily Air Quality
+	index, err := USEPAStandard.ToIndex(PM25Parameter, 24*time.Hour, 35.9, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(102), index)
+
+	// Ozone concentration is truncated to 0.078 ppm before calculation
+	index, err = USEPAStandard.ToIndex(O3Parameter, 8*time.Hour, 0.07853, PartsPerMillionUnit)
+	a.Nil(err)
+	a.Equal(float32(126), index)
+
+	index, err = USEPAStandard.ToIndex(O3Parameter, 8*time.Hour, 78.53, PartsPerBillionUnit)
+	a.Nil(err)
+	a.Equal(float32(126), index)
+
+	// Band boundaries
+	index, err = USEPAStandard.ToIndex(PM25Parameter, 24*time.Hour, 9.0, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(50), index)
+	index, err = USEPAStandard.ToIndex(O3Parameter, 8*time.Hour, 0.055, PartsPerMillionUnit)
+	a.Nil(err)
+	a.Equal(float32(51), index)
+	index, err = USEPAStandard.ToIndex(PM10Parameter, 24*time.Hour, 604, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(500), index)
+
+	concentration, err := USEPAStandard.ToConcentration(PM25Parameter, 24*time.Hour, 100, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(35.4, concentration, 0.001)
+
+	concentration, err = USEPAStandard.ToConcentration(COParameter, 8*time.Hour, 51, PartsPerMillionUnit)
+	a.Nil(err)
+	a.InDelta(4.5, concentration, 0.001)
+
+	// Values beyond the AQI and ozone below 1-hour scale
+	_, err = USEPAStandard.ToIndex(PM25Parameter, 24*time.Hour, 400, MicrogramsPerCubicMeterUnit)
+	a.Equal(ErrOutOfScale, err)
+	_, err = USEPAStandard.ToIndex(O3Parameter, time.Hour, 0.1, PartsPerMillionUnit)
+	a.Equal(ErrOutOfScale, err)
+	_, err = USEPAStandard.ToConcentration(PM25Parameter, 24*time.Hour, 501, MicrogramsPerCubicMeterUnit)
+	a.Equal(ErrOutOfScale, err)
+}
+
+func TestEUCAQIStandard(t *testing.T) {
+	a := assert.New(t)
+
+	index, err := EUCAQIStandard.ToIndex(NO2Parameter, time.Hour, 150, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(62.5, index, 0.001)
+
+	index, err = EUCAQIStandard.ToIndex(PM10Parameter, 24*time.Hour, 40, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(62.5, index, 0.001)
+
+	// "Very high" values are above 100
+	index, err = EUCAQIStandard.ToIndex(PM25Parameter, time.Hour, 165, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(125, index, 0.001)
+
+	concentration, err := EUCAQIStandard.ToConcentration(O3Parameter, time.Hour, 75, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(180, concentration, 0.001)
+
+	concentration, err = EUCAQIStandard.ToConcentration(COParameter, 8*time.Hour, 50, MilligramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(7.5, concentration, 0.001)
+}
+
+func TestIndiaNAQIStandard(t *testing.T) {
+	a := assert.New(t)
+
+	index, err := IndiaNAQIStandard.ToIndex(PM10Parameter, 24*time.Hour, 150, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(134), index)
+
+	index, err = IndiaNAQIStandard.ToIndex(COParameter, 8*time.Hour, 1500, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(73), index)
+
+	// "Severe" band has no upper concentration and is capped at 500
+	index, err = IndiaNAQIStandard.ToIndex(PM25Parameter, 24*time.Hour, 300, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(438), index)
+
+	index, err = IndiaNAQIStandard.ToIndex(PM25Parameter, 24*time.Hour, 1000, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(500), index)
+
+	_, err = IndiaNAQIStandard.ToConcentration(PM25Parameter, 24*time.Hour, 501, MicrogramsPerCubicMeterUnit)
+	a.Equal(ErrOutOfScale, err)
+}
+
+func TestChinaAQIStandard(t *testing.T) {
+	a := assert.New(t)
+
+	// Indices are rounded up
+	index, err := ChinaAQIStandard.ToIndex(PM25Parameter, 24*time.Hour, 80, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(107), index)
+
+	index, err = ChinaAQIStandard.ToIndex(COParameter, time.Hour, 12, MilligramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(104), index)
+
+	index, err = ChinaAQIStandard.ToIndex(PM10Parameter, 24*time.Hour, 600, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.Equal(float32(500), index)
+
+	// 1-hour SO2 above 800 μg/m3 should be reported using 24-hour scale
+	_, err = ChinaAQIStandard.ToIndex(SO2Parameter, time.Hour, 900, MicrogramsPerCubicMeterUnit)
+	a.Equal(ErrOutOfScale, err)
+
+	concentration, err := ChinaAQIStandard.ToConcentration(O3Parameter, 8*time.Hour, 100, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(160, concentration, 0.001)
+}
+
+func TestStandardScales(t *testing.T) {
+	a := assert.New(t)
+
+	for _, standard := range Standards {
+		scales := standard.Scales()
+		a.NotEmpty(scales, standard.String())
+
+		for _, scale := range scales {
+			for i := 1; i < len(scale.breakpoints); i++ {
+				prev, bp := scale.breakpoints[i-1], scale.breakpoints[i]
+				a.True(bp.cLow >= prev.cHigh && bp.iLow >= prev.iHigh, "%s %s %s: band #%d overlaps", standard, scale.Parameter, scale.Period, i)
+			}
+		}
+	}
+
+	_, err := USEPAStandard.Scale(NO2Parameter, 24*time.Hour)
+	a.Equal(ErrUnknownScale, err)
+	_, err = Standard("unknown").Scale(PM25Parameter, 24*time.Hour)
+	a.Equal(ErrUnknownScale, err)
+
+	standard, err := ParseStandard(" CAQI ")
+	a.Nil(err)
+	a.Equal(EUCAQIStandard, standard)
+	_, err = ParseStandard("who")
+	a.EqualError(err, "unknown AQI standard: \"who\"")
+}
+
+func TestConvertConcentration(t *testing.T) {
+	a := assert.New(t)
+
+	value, err := ConvertConcentration(NO2Parameter, 100, PartsPerBillionUnit, MicrogramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(188.18, value, 0.01)
+
+	value, err = ConvertConcentration(COParameter, 1, PartsPerMillionUnit, MilligramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(1.1456, value, 0.0001)
+
+	value, err = ConvertConcentration(PM25Parameter, 1500, MicrogramsPerCubicMeterUnit, MilligramsPerCubicMeterUnit)
+	a.Nil(err)
+	a.InDelta(1.5, value, 0.0001)
+
+	_, err = ConvertConcentration(PM25Parameter, 10, PartsPerBillionUnit, MicrogramsPerCubicMeterUnit)
+	a.Equal(ErrIncompatibleUnit, err)
+}
@@ -1,7 +1,9 @@
 package waqi
 
 import (
+	"context"
 	"encoding/json"
+	"strconv"
 	"time"
 )
 
@@ -33,7 +35,7 @@ const (
 	VeryUnhealthyLevel Level = "very_unhealthy"
 
 	// HazardousLevel means a health alert.
-	// Maps to AQI from 300 and above.
+	// Maps to AQI from 301 and above.
 	HazardousLevel Level = "hazardous"
 )
 
@@ -57,27 +59,39 @@ func (level Level) String() string {
 	}
 }
 
-// CalcAQILevel calculates an air quality level for raw AQI value
-func CalcAQILevel(value float32) Level {
-	if value < 51 {
-		return GoodLevel
-	}
-	if value < 101 {
-		return ModerateLevel
-	}
-	if value < 151 {
-		return PossiblyUnhealthyLevel
-	}
-	if value < 201 {
-		return UnhealthyLevel
-	}
-	if value < 300 {
-		return VeryUnhealthyLevel
+// levelOrder contains all known levels sorted from best to worst
+var levelOrder = []Level{
+	GoodLevel,
+	ModerateLevel,
+	PossiblyUnhealthyLevel,
+	UnhealthyLevel,
+	VeryUnhealthyLevel,
+	HazardousLevel,
+}
+
+// Ordinal returns an ordinal number of a level (0 is the best one)
+// Returns -1 for unknown levels
+func (level Level) Ordinal() int {
+	for i, l := range levelOrder {
+		if l == level {
+			return i
+		}
 	}
-	return HazardousLevel
+	return -1
 }
 
-// CalcPM10Level calculates a PM10 level for raw PM10 value
+// CalcAQILevel calculates an air quality level for raw AQI value (see USEPAStandard categories)
+func CalcAQILevel(value float32) Level {
+	return USEPAStandard.CalcLevel(value)
+}
+
+// Pollutant level functions below use India NAQI concentration breakpoints.
+// WAQI reports pollutants as US EPA sub-indices rather than concentrations,
+// so CalcAQILevel should be used for WAQI values instead.
+
+// CalcPM10Level calculates a PM10 level for raw PM10 concentration (μg/m3, 24-hour average)
+//
+// Deprecated: use IndiaNAQIStandard.ToIndex(PM10Parameter, 24*time.Hour, value, MicrogramsPerCubicMeterUnit) with IndiaNAQIStandard.CalcLevel.
 func CalcPM10Level(value float32) Level {
 	if value < 51 {
 		return GoodLevel
@@ -97,7 +111,9 @@ func CalcPM10Level(value float32) Level {
 	return HazardousLevel
 }
 
-// CalcPM25Level calculates a PM2.5 level for raw PM2.5 value
+// CalcPM25Level calculates a PM2.5 level for raw PM2.5 concentration (μg/m3, 24-hour average)
+//
+// Deprecated: use IndiaNAQIStandard.ToIndex(PM25Parameter, 24*time.Hour, value, MicrogramsPerCubicMeterUnit) with IndiaNAQIStandard.CalcLevel.
 func CalcPM25Level(value float32) Level {
 	if value < 31 {
 		return GoodLevel
@@ -117,7 +133,9 @@ func CalcPM25Level(value float32) Level {
 	return HazardousLevel
 }
 
-// CalcNO2Level calculates a NO2 level for raw NO2 value
+// CalcNO2Level calculates a NO2 level for raw NO2 concentration (μg/m3, 24-hour average)
+//
+// Deprecated: use IndiaNAQIStandard.ToIndex(NO2Parameter, 24*time.Hour, value, MicrogramsPerCubicMeterUnit) with IndiaNAQIStandard.CalcLevel.
 func CalcNO2Level(value float32) Level {
 	if value < 41 {
 		return GoodLevel
@@ -137,7 +155,9 @@ func CalcNO2Level(value float32) Level {
 	return HazardousLevel
 }
 
-// CalcO3Level calculates an O3 level for raw O3 value
+// CalcO3Level calculates an O3 level for raw O3 concentration (μg/m3, 8-hour average)
+//
+// Deprecated: use IndiaNAQIStandard.ToIndex(O3Parameter, 8*time.Hour, value, MicrogramsPerCubicMeterUnit) with IndiaNAQIStandard.CalcLevel.
 func CalcO3Level(value float32) Level {
 	if value < 51 {
 		return GoodLevel
@@ -157,7 +177,9 @@ func CalcO3Level(value float32) Level {
 	return HazardousLevel
 }
 
-// CalcCOLevel calculates a CO level for raw CO value
+// CalcCOLevel calculates a CO level for raw CO concentration (mg/m3, 8-hour average)
+//
+// Deprecated: use IndiaNAQIStandard.ToIndex(COParameter, 8*time.Hour, value, MilligramsPerCubicMeterUnit) with IndiaNAQIStandard.CalcLevel.
 func CalcCOLevel(value float32) Level {
 	if value < 1.1 {
 		return GoodLevel
@@ -177,7 +199,9 @@ func CalcCOLevel(value float32) Level {
 	return HazardousLevel
 }
 
-// CalcSO2Level calculates a SO2 level for raw SO2 value
+// CalcSO2Level calculates a SO2 level for raw SO2 concentration (μg/m3, 24-hour average)
+//
+// Deprecated: use IndiaNAQIStandard.ToIndex(SO2Parameter, 24*time.Hour, value, MicrogramsPerCubicMeterUnit) with IndiaNAQIStandard.CalcLevel.
 func CalcSO2Level(value float32) Level {
 	if value < 41 {
 		return GoodLevel
@@ -197,6 +221,65 @@ func CalcSO2Level(value float32) Level {
 	return HazardousLevel
 }
 
+// Parameter is an air quality parameter
+type Parameter string
+
+const (
+	// AQIParameter is an air quality index
+	AQIParameter Parameter = "aqi"
+
+	// PM25Parameter is a particulate matter 2.5 measurement
+	PM25Parameter Parameter = "pm25"
+
+	// PM10Parameter is a particulate matter 10 measurement
+	PM10Parameter Parameter = "pm10"
+
+	// O3Parameter is an ozone measurement
+	O3Parameter Parameter = "o3"
+
+	// NO2Parameter is a nitrogen dioxide measurement
+	NO2Parameter Parameter = "no2"
+
+	// SO2Parameter is a sulfur dioxide measurement
+	SO2Parameter Parameter = "so2"
+
+	// COParameter is a carbon monoxide measurement
+	COParameter Parameter = "co"
+)
+
+// Parameters contains all known parameters
+var Parameters = []Parameter{
+	AQIParameter,
+	PM25Parameter,
+	PM10Parameter,
+	O3Parameter,
+	NO2Parameter,
+	SO2Parameter,
+	COParameter,
+}
+
+// String converts a value of Parameter into string
+func (p Parameter) String() string {
+	switch p {
+	case AQIParameter:
+		return "AQI"
+	case PM25Parameter:
+		return "PM2.5"
+	case PM10Parameter:
+		return "PM10"
+	case O3Parameter:
+		return "O3"
+	case NO2Parameter:
+		return "NO2"
+	case SO2Parameter:
+		return "SO2"
+	case COParameter:
+		return "CO"
+	default:
+		return string(p)
+	}
+}
+
 // Error represents a service-specific error
 type Error string
 
@@ -210,6 +293,9 @@ func (e Error) Error() string {
 	return string(e)
 }
 
+// ErrHistoryDisabled is returned when history is queried but history store is not configured
+const ErrHistoryDisabled = Error("history is disabled")
+
 // Status contains aggregated air quality status
 type Status struct {
 	Station *Station `json:"station"`
@@ -223,6 +309,9 @@ type Status struct {
 	// Air quality index level
 	Level Level `json:"level"`
 
+	// AQI standard of index values (empty means US EPA, as reported by WAQI, see Standard.Convert)
+	Standard Standard `json:"standard,omitempty"`
+
 	// Particulate matter 2.5 measurement
 	PM25 *float32 `json:"pm25"`
 
@@ -240,6 +329,57 @@ type Status struct {
 
 	// Carbon monoxide level measurement
 	CO *float32 `json:"co"`
+
+	// Weather conditions (nil if station doesn't report weather)
+	Weather *Weather `json:"weather"`
+
+	// Pollutant which defines AQI value (empty if unknown)
+	DominantPollutant Parameter `json:"dominant_pollutant"`
+
+	// Data sources which should be credited when status is displayed
+	Attributions []*Attribution `json:"attributions"`
+
+	// Daily air quality forecast (nil if station doesn't provide a forecast)
+	Forecast *Forecast `json:"forecast"`
+
+	// Stale is set if WAQI service is unavailable and a previously received status is returned instead
+	Stale bool `json:"stale"`
+}
+
+// Weather contains weather conditions reported by a station
+type Weather struct {
+	// Temperature (in °C)
+	Temperature *float32 `json:"temperature"`
+
+	// Relative humidity (in %)
+	Humidity *float32 `json:"humidity"`
+
+	// Atmospheric pressure (in hPa)
+	Pressure *float32 `json:"pressure"`
+
+	// Wind speed (in m/s)
+	Wind *float32 `json:"wind"`
+
+	// Wind gust speed (in m/s)
+	WindGust *float32 `json:"wind_gust"`
+
+	// Dew point (in °C)
+	DewPoint *float32 `json:"dew_point"`
+}
+
+// IsEmpty returns true if no weather conditions are reported
+func (w *Weather) IsEmpty() bool {
+	return w.Temperature == nil && w.Humidity == nil && w.Pressure == nil &&
+		w.Wind == nil && w.WindGust == nil && w.DewPoint == nil
+}
+
+// Attribution is a data source which should be credited
+type Attribution struct {
+	// Data source name
+	Name string `json:"name"`
+
+	// Data source website
+	URL string `json:"url"`
 }
 
 // String converts Status to string
@@ -254,7 +394,11 @@ func (s *Status) Equal(other *Status) bool {
 		return false
 	}
 
-	if s.Level != other.Level {
+	if s.Level != other.Level || s.Standard != other.Standard {
+		return false
+	}
+
+	if s.AQI != other.AQI {
 		return false
 	}
 
@@ -285,6 +429,29 @@ func (s *Status) Equal(other *Status) bool {
 	return true
 }
 
+// Value returns a value of specified parameter
+// Returns nil if parameter is not measured
+func (s *Status) Value(parameter Parameter) *float32 {
+	switch parameter {
+	case AQIParameter:
+		return &s.AQI
+	case PM25Parameter:
+		return s.PM25
+	case PM10Parameter:
+		return s.PM10
+	case O3Parameter:
+		return s.O3
+	case NO2Parameter:
+		return s.NO2
+	case SO2Parameter:
+		return s.SO2
+	case COParameter:
+		return s.CO
+	default:
+		return nil
+	}
+}
+
 func areValuesEqual(x, y *float32) bool {
 	if x == nil && y == nil {
 		return true
@@ -308,23 +475,130 @@ type Station struct {
 	// URL of the monitoring station website
 	URL string `json:"url"`
 
-	// Latitude of the monitoring station
+	// Longitude of the monitoring station
 	Lon float32 `json:"lon"`
 
-	// Longitude of the monitoring station
+	// Latitude of the monitoring station
 	Lat float32 `json:"lat"`
 }
 
+// StationSummary contains brief information about a station (e.g. a search result)
+type StationSummary struct {
+	Station *Station `json:"station"`
+
+	// Measurement time
+	Time time.Time `json:"time"`
+
+	// Air quality index value (nil if unknown)
+	AQI *float32 `json:"aqi"`
+
+	// Air quality index level (empty if unknown)
+	Level Level `json:"level"`
+}
+
+// SetAQI parses AQI value from its string representation
+// WAQI returns "-" if station has no recent data, such values are ignored
+func (s *StationSummary) SetAQI(str string) {
+	aqi, err := strconv.ParseFloat(str, 32)
+	if err != nil {
+		return
+	}
+
+	value := float32(aqi)
+	s.AQI = &value
+	s.Level = CalcAQILevel(value)
+}
+
+// NearbyStation is a station located near some point
+type NearbyStation struct {
+	*StationSummary
+
+	// Distance to station (in kilometers)
+	Distance float64 `json:"distance"`
+}
+
+// CacheStats contains cache usage statistics
+type CacheStats struct {
+	// True if cache is enabled
+	Enabled bool `json:"enabled"`
+
+	// Count of lookups served from cache
+	Hits uint64 `json:"hits"`
+
+	// Count of lookups that required a request to WAQI service
+	Misses uint64 `json:"misses"`
+
+	// Count of entries removed since cache was over its limits
+	Evictions uint64 `json:"evictions"`
+
+	// Count of entries removed since they were too old
+	Expirations uint64 `json:"expirations"`
+
+	// Current count of entries
+	Entries int `json:"entries"`
+
+	// Current total size of entries (in bytes)
+	Size int64 `json:"size"`
+
+	// Count of lookups served from in-memory tier
+	MemoryHits uint64 `json:"memory_hits"`
+
+	// Current count of entries kept in memory
+	MemoryEntries int `json:"memory_entries"`
+}
+
+// UpdateReport describes a run of background station updates
+type UpdateReport struct {
+	// Run start time
+	Time time.Time `json:"time"`
+
+	// Run duration
+	Duration time.Duration `json:"duration"`
+
+	// Count of subscribed stations
+	Stations int `json:"stations"`
+
+	// Count of stations which were due to be updated
+	Due int `json:"due"`
+
+	// Count of successfully updated stations
+	Updated int `json:"updated"`
+
+	// Count of stations which failed to update
+	Failed int `json:"failed"`
+
+	// IDs of stations which failed to update
+	FailedStations []int `json:"failed_stations"`
+}
+
 // Service is an entry point for WAQI service
 type Service interface {
 	// GetByCity fetches current measurements for city
-	GetByCity(city string) (*Status, error)
+	GetByCity(ctx context.Context, city string) (*Status, error)
 
 	// GetByStation fetches current measurements for station
-	GetByStation(stationID int) (*Status, error)
+	GetByStation(ctx context.Context, stationID int) (*Status, error)
 
 	// GetByGeo fetches current measurements for geo coordinates
-	GetByGeo(lat, lon float32) (*Status, error)
+	GetByGeo(ctx context.Context, lat, lon float32) (*Status, error)
+
+	// Search looks up stations by a keyword (e.g. city name)
+	Search(ctx context.Context, keyword string) ([]*StationSummary, error)
+
+	// GetByBounds returns stations located within specified rectangle
+	GetByBounds(ctx context.Context, bounds Bounds) ([]*StationSummary, error)
+
+	// GetNearby returns up to count stations nearest to specified geo coordinates, ordered by distance
+	GetNearby(ctx context.Context, lat, lon float32, count int) ([]*NearbyStation, error)
+
+	// GetHistory returns historical measurements of a station within specified time range, ordered by time
+	GetHistory(stationID int, from, to time.Time) ([]*Status, error)
+
+	// GetCacheStats returns cache usage statistics
+	GetCacheStats() (*CacheStats, error)
+
+	// GetUpdateReport returns a report of the last run of background station updates
+	GetUpdateReport() *UpdateReport
 
 	// Subscribe adds a listener to updates
 	Subscribe(stationID int, listener Listener)
@@ -332,6 +606,17 @@ type Service interface {
 	// Unsubscribe removes a listener
 	Unsubscribe(stationID int, listener Listener)
 
+	// IsOffline returns true if a subscribed station is considered offline
+	IsOffline(stationID int) bool
+
+	// ScheduleDigest schedules a daily digest of a station
+	// Digest is delivered every day at specified time of day (in minutes since midnight) in specified location
+	// Existing schedule with the same key is replaced
+	ScheduleDigest(key string, stationID int, timeOfDay int, loc *time.Location, listener DigestListener)
+
+	// UnscheduleDigest removes a daily digest schedule
+	UnscheduleDigest(key string)
+
 	// StartUpdates starts background data updates
 	StartUpdates()
 
@@ -349,3 +634,20 @@ type Listener interface {
 	// and not nil - on subsequent ones
 	Update(status *Status, prevStatus *Status) error
 }
+
+// AvailabilityListener receives notifications about stations which stop reporting data
+// Listeners passed to Service.Subscribe might optionally implement it
+type AvailabilityListener interface {
+	// StationOffline handles a station which hasn't reported new data since specified time
+	StationOffline(status *Status, since time.Time) error
+
+	// StationOnline handles a station which has resumed reporting data after being offline since specified time
+	StationOnline(status *Status, since time.Time) error
+}
+
+// DigestListener receives daily digests
+type DigestListener interface {
+	// Digest handles a scheduled daily digest
+	// key is a key of digest schedule
+	Digest(key string, digest *Digest) error
+}
@@ -28,8 +28,47 @@ const ResultPresenter = {
             <v-result-row name="Nitrogen dioxide" v-bind:value="result?.no2"></v-result-row>
             <v-result-row name="Sulfur dioxide" v-bind:value="result?.so2"></v-result-row>
             <v-result-row name="Carbon monoxide" v-bind:value="result?.co"></v-result-row>
+            <v-result-row name="Dominant pollutant" v-bind:value="getPollutantName(result?.dominant_pollutant)"></v-result-row>
             <v-result-row name="Last updated" v-bind:value="result?.time"></v-result-row>
         </ul>
+        <ul class="list-unstyled m-3" v-if="!!result?.weather">
+            <v-result-row name="Temperature" v-bind:value="withUnit(result?.weather?.temperature, '°C')"></v-result-row>
+            <v-result-row name="Dew point" v-bind:value="withUnit(result?.weather?.dew_point, '°C')"></v-result-row>
+            <v-result-row name="Humidity" v-bind:value="withUnit(result?.weather?.humidity, '%')"></v-result-row>
+            <v-result-row name="Pressure" v-bind:value="withUnit(result?.weather?.pressure, 'hPa')"></v-result-row>
+            <v-result-row name="Wind" v-bind:value="withUnit(result?.weather?.wind, 'm/s')"></v-result-row>
+            <v-result-row name="Wind gusts" v-bind:value="withUnit(result?.weather?.wind_gust, 'm/s')"></v-result-row>
+        </ul>
+        <div class="m-3" v-if="result?.forecast?.days?.length">
+            <strong>Forecast:</strong>
+            <table class="table table-sm mt-2">
+                <thead>
+                    <tr>
+                        <th>Date</th>
+                        <th>PM2.5</th>
+                        <th>PM10</th>
+                        <th>Ozone</th>
+                        <th>UV index</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    <tr v-for="day in result.forecast.days">
+                        <td>{{ day.date }}</td>
+                        <td>{{ getForecastRange(day.pm25) }}</td>
+                        <td>{{ getForecastRange(day.pm10) }}</td>
+                        <td>{{ getForecastRange(day.o3) }}</td>
+                        <td>{{ getForecastRange(day.uvi) }}</td>
+                    </tr>
+                </tbody>
+            </table>
+        </div>
+        <div class="card-footer text-muted small" v-if="result?.attributions?.length">
+            Data:
+            <span v-for="(attribution, index) in result.attributions">
+                <a v-if="!!attribution.url" :href="attribution.url" target="_blank" rel="noopener">{{ attribution.name }}</a>
+                <span v-else>{{ attribution.name }}</span><span v-if="index < result.attributions.length - 1">, </span>
+            </span>
+        </div>
     </div>
 
     <div class="mt-4" v-if="!!loading">
@@ -55,6 +94,33 @@ const ResultPresenter = {
             this.$parent.clearError();
         },
 
+        withUnit(value, unit) {
+            return value === null || value === undefined ? null : `${value} ${unit}`;
+        },
+
+        getForecastRange(value) {
+            return !value ? '—' : `${value.min}–${value.max}`;
+        },
+
+        getPollutantName(pollutant) {
+            switch (pollutant) {
+                case 'pm25':
+                    return 'PM2.5';
+                case 'pm10':
+                    return 'PM10';
+                case 'o3':
+                    return 'Ozone';
+                case 'no2':
+                    return 'Nitrogen dioxide';
+                case 'so2':
+                    return 'Sulfur dioxide';
+                case 'co':
+                    return 'Carbon monoxide';
+                default:
+                    return pollutant;
+            }
+        },
+
         getCardCssClass() {
             return `card mt-4 border-${this.getCss()}`;
         },